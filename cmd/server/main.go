@@ -10,11 +10,14 @@ import (
 	"time"
 
 	"github.com/fluxo/export-middleware/pkg/config"
+	"github.com/fluxo/export-middleware/pkg/download"
 	grpcserver "github.com/fluxo/export-middleware/pkg/grpc"
 	"github.com/fluxo/export-middleware/pkg/logger"
+	"github.com/fluxo/export-middleware/pkg/notifier"
 	"github.com/fluxo/export-middleware/pkg/oss"
 	"github.com/fluxo/export-middleware/pkg/storage"
 	"github.com/fluxo/export-middleware/pkg/taskmanager"
+	"github.com/fluxo/export-middleware/pkg/webhook"
 )
 
 var (
@@ -76,15 +79,41 @@ func main() {
 		"bucket":   cfg.OSS.Bucket,
 	})
 
+	// Initialize webhook notifier
+	webhookNotifier := webhook.NewNotifier(&cfg.Webhook, log)
+
+	// Initialize event notifier
+	var eventDispatcher *notifier.Dispatcher
+	eventSink, err := notifier.New(&cfg.Notifications)
+	if err != nil {
+		log.Fatal("Failed to initialize event notifier", logger.Fields{"error": err.Error()})
+	}
+	if eventSink != nil {
+		eventDispatcher = notifier.NewDispatcher(eventSink, cfg.Notifications.BufferSize, cfg.Notifications.Timeout, log)
+		log.Info("Event notifier initialized", logger.Fields{"sink": cfg.Notifications.Sink})
+	}
+
 	// Initialize task manager
-	taskMgr := taskmanager.NewManager(cfg, log, storageMgr, ossUploader)
+	taskMgr := taskmanager.NewManager(cfg, log, storageMgr, ossUploader, webhookNotifier, eventDispatcher)
 	log.Info("Task manager initialized", logger.Fields{
 		"max_concurrent": cfg.Concurrency.MaxConcurrentTasks,
 		"queue_size":     cfg.Concurrency.TaskQueueSize,
 	})
+	if resumed := taskMgr.ResumeInterrupted(); resumed > 0 {
+		log.Info("Resuming interrupted uploads", logger.Fields{"tasks": resumed})
+	}
+
+	// Serve single-use download links if enabled
+	var downloadLinks *download.Links
+	if cfg.Downloads.LinkPort > 0 {
+		downloadLinks = download.NewLinks(&cfg.Downloads, cfg.Storage.TempDirectory, taskMgr, ossUploader, log)
+		if err := downloadLinks.Start(); err != nil {
+			log.Fatal("Failed to start download link server", logger.Fields{"error": err.Error()})
+		}
+	}
 
 	// Initialize gRPC server
-	grpcServer := grpcserver.NewServer(cfg, log, taskMgr)
+	grpcServer := grpcserver.NewServer(cfg, log, taskMgr, downloadLinks)
 	if err := grpcServer.Start(); err != nil {
 		log.Fatal("Failed to start gRPC server", logger.Fields{"error": err.Error()})
 	}
@@ -100,13 +129,35 @@ func main() {
 		"metrics_port": cfg.Monitoring.MetricsPort,
 	})
 
+	// Reload configuration on SIGHUP and, if enabled, on file change
+	reloader := newConfigReloader(*configPath, cfg, log, storageMgr, ossUploader, taskMgr)
+	reloadCtx, stopReload := context.WithCancel(context.Background())
+	if cfg.Server.ConfigWatch > 0 {
+		go reloader.watch(reloadCtx, cfg.Server.ConfigWatch)
+	}
+
 	// Wait for shutdown signal
 	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
-	<-sigChan
+	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM, syscall.SIGHUP)
+	for sig := range sigChan {
+		if sig != syscall.SIGHUP {
+			break
+		}
+		log.Info("SIGHUP received, reloading configuration")
+		reloader.reload("sighup")
+	}
+	stopReload()
 
 	log.Info("Shutdown signal received, initiating graceful shutdown...")
 
+	// Stop taking new exports and let running ones finish
+	grpcServer.Drain()
+	drainCtx, cancelDrain := context.WithTimeout(context.Background(), cfg.Server.DrainTimeout)
+	if err := taskMgr.Drain(drainCtx); err != nil {
+		log.Warn("Drain did not complete", logger.Fields{"error": err.Error()})
+	}
+	cancelDrain()
+
 	// Stop gRPC server
 	grpcServer.Stop()
 
@@ -114,11 +165,25 @@ func main() {
 	shutdownCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
+	// Stop serving download links
+	if downloadLinks != nil {
+		if err := downloadLinks.Stop(shutdownCtx); err != nil {
+			log.Error("Error stopping download link server", logger.Fields{"error": err.Error()})
+		}
+	}
+
 	// Shutdown task manager
 	if err := taskMgr.Shutdown(shutdownCtx); err != nil {
 		log.Error("Error during task manager shutdown", logger.Fields{"error": err.Error()})
 	}
 
+	// Flush pending task events
+	if eventDispatcher != nil {
+		if err := eventDispatcher.Close(); err != nil {
+			log.Error("Error closing event notifier", logger.Fields{"error": err.Error()})
+		}
+	}
+
 	// Close storage manager
 	if err := storageMgr.Close(); err != nil {
 		log.Error("Error closing storage manager", logger.Fields{"error": err.Error()})
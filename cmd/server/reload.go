@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/fluxo/export-middleware/pkg/config"
+	"github.com/fluxo/export-middleware/pkg/logger"
+	"github.com/fluxo/export-middleware/pkg/oss"
+	"github.com/fluxo/export-middleware/pkg/storage"
+	"github.com/fluxo/export-middleware/pkg/taskmanager"
+)
+
+// configReloader re-reads the configuration file on SIGHUP or when the file
+// changes and applies the settings that can change at runtime
+type configReloader struct {
+	path        string
+	log         *logger.Logger
+	storageMgr  *storage.Manager
+	ossUploader *oss.Uploader
+	taskMgr     *taskmanager.Manager
+
+	mu      sync.Mutex
+	current *config.Config
+	modTime time.Time
+}
+
+// newConfigReloader creates a reloader for the configuration loaded from path
+func newConfigReloader(path string, cfg *config.Config, log *logger.Logger, storageMgr *storage.Manager, ossUploader *oss.Uploader, taskMgr *taskmanager.Manager) *configReloader {
+	r := &configReloader{
+		path:        path,
+		log:         log,
+		storageMgr:  storageMgr,
+		ossUploader: ossUploader,
+		taskMgr:     taskMgr,
+		current:     cfg,
+	}
+	if info, err := os.Stat(path); err == nil {
+		r.modTime = info.ModTime()
+	}
+	return r
+}
+
+// reload loads, validates and applies the configuration file. An invalid
+// file leaves the running configuration untouched.
+func (r *configReloader) reload(trigger string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	info, err := os.Stat(r.path)
+	if err != nil {
+		r.log.Error("Config reload failed", logger.Fields{"trigger": trigger, "error": err.Error()})
+		return
+	}
+	r.modTime = info.ModTime()
+
+	// LoadConfig applies environment overrides and validates the file
+	next, err := config.LoadConfig(r.path)
+	if err != nil {
+		r.log.Error("Config reload failed", logger.Fields{"trigger": trigger, "error": err.Error()})
+		return
+	}
+
+	merged, restart := r.current.Reload(next)
+	if err := merged.Validate(); err != nil {
+		r.log.Error("Config reload failed", logger.Fields{"trigger": trigger, "error": err.Error()})
+		return
+	}
+
+	r.log.SetLevel(merged.Logging.Level)
+	r.storageMgr.SetRetention(merged.Storage.TempRetention)
+	r.ossUploader.SetSignedURLExpiry(merged.OSS.SignedURLExpiry)
+	r.taskMgr.ApplyConfig(merged)
+	r.current = merged
+
+	fields := logger.Fields{
+		"trigger":           trigger,
+		"max_concurrent":    merged.Concurrency.MaxConcurrentTasks,
+		"queue_timeout":     merged.Concurrency.QueueTimeout.String(),
+		"log_level":         merged.Logging.Level,
+		"temp_retention":    merged.Storage.TempRetention.String(),
+		"signed_url_expiry": merged.OSS.SignedURLExpiry.String(),
+		"quotas_enabled":    merged.Quotas.Enabled,
+	}
+	if len(restart) > 0 {
+		fields["restart_required"] = restart
+		r.log.Warn("Configuration reloaded; some changes need a restart", fields)
+		return
+	}
+	r.log.Info("Configuration reloaded", fields)
+}
+
+// watch polls the configuration file and reloads it when it changes
+func (r *configReloader) watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			info, err := os.Stat(r.path)
+			if err != nil {
+				continue
+			}
+			r.mu.Lock()
+			changed := !info.ModTime().Equal(r.modTime)
+			r.mu.Unlock()
+			if changed {
+				r.reload("file_change")
+			}
+		}
+	}
+}
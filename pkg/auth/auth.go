@@ -0,0 +1,257 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fluxo/export-middleware/pkg/config"
+)
+
+// Authentication errors
+var (
+	ErrUnauthenticated  = errors.New("missing or invalid credentials")
+	ErrClientNotAllowed = errors.New("client is not allowed")
+)
+
+// Credentials are the authentication values supplied with a request
+type Credentials struct {
+	APIKey      string // Static API key
+	BearerToken string // JWT from the authorization header
+	ClientID    string // Client ID for HMAC-signed tokens
+	Timestamp   string // Unix timestamp for HMAC-signed tokens, may have a fractional part
+	Signature   string // Hex HMAC-SHA256 of "<client_id>:<timestamp>:<method>"
+	Method      string // Full method name of the call, signed by HMAC tokens
+	Certificate string // Subject common name of a verified client certificate
+}
+
+// Authenticator maps request credentials to a client identity
+type Authenticator struct {
+	config *config.SecurityConfig
+	now    func() time.Time
+
+	// HMAC tokens seen within the allowed skew, by client and timestamp,
+	// so a captured token cannot be replayed
+	mu        sync.Mutex
+	seen      map[string]time.Time
+	lastPrune time.Time
+}
+
+// NewAuthenticator creates a new authenticator
+func NewAuthenticator(cfg *config.SecurityConfig) *Authenticator {
+	return &Authenticator{
+		config: cfg,
+		now:    time.Now,
+		seen:   make(map[string]time.Time),
+	}
+}
+
+// Authenticate verifies the credentials and returns the client identity.
+// API keys are checked first, then JWTs, then HMAC-signed tokens, then
+// the client certificate verified during the TLS handshake.
+func (a *Authenticator) Authenticate(creds Credentials) (string, error) {
+	var clientID string
+	var err error
+
+	switch {
+	case creds.APIKey != "" && len(a.config.APIKeys) > 0:
+		clientID, err = a.verifyAPIKey(creds.APIKey)
+	case creds.BearerToken != "" && a.config.JWT.Secret != "":
+		clientID, err = a.verifyJWT(creds.BearerToken)
+	case creds.Signature != "" && len(a.config.HMACSecrets) > 0:
+		clientID, err = a.verifyHMAC(creds.ClientID, creds.Timestamp, creds.Method, creds.Signature)
+	case creds.Certificate != "":
+		clientID = creds.Certificate
+	default:
+		return "", ErrUnauthenticated
+	}
+	if err != nil {
+		return "", err
+	}
+
+	if !a.allowed(clientID) {
+		return "", fmt.Errorf("%w: %s", ErrClientNotAllowed, clientID)
+	}
+	return clientID, nil
+}
+
+// IsAdmin reports whether a client may access tasks of other clients
+func (a *Authenticator) IsAdmin(clientID string) bool {
+	for _, admin := range a.config.AdminClients {
+		if admin == clientID {
+			return true
+		}
+	}
+	return false
+}
+
+// allowed checks the client against the allow list. An empty list allows
+// every authenticated client.
+func (a *Authenticator) allowed(clientID string) bool {
+	if len(a.config.AllowedClients) == 0 {
+		return true
+	}
+	for _, allowed := range a.config.AllowedClients {
+		if allowed == clientID {
+			return true
+		}
+	}
+	return false
+}
+
+// verifyAPIKey looks up the client owning a static API key
+func (a *Authenticator) verifyAPIKey(key string) (string, error) {
+	for candidate, clientID := range a.config.APIKeys {
+		if subtle.ConstantTimeCompare([]byte(candidate), []byte(key)) == 1 {
+			return clientID, nil
+		}
+	}
+	return "", ErrUnauthenticated
+}
+
+// SignHMAC computes the signature of an HMAC token for a client's call to
+// a method
+func SignHMAC(secret string, clientID string, timestamp string, method string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(clientID + ":" + timestamp + ":" + method))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// verifyHMAC checks an HMAC-signed token, its timestamp skew and that the
+// client has not used the timestamp before
+func (a *Authenticator) verifyHMAC(clientID string, timestamp string, method string, signature string) (string, error) {
+	secret, exists := a.config.HMACSecrets[clientID]
+	if !exists || clientID == "" {
+		return "", ErrUnauthenticated
+	}
+
+	ts, err := strconv.ParseFloat(timestamp, 64)
+	if err != nil {
+		return "", fmt.Errorf("%w: invalid timestamp", ErrUnauthenticated)
+	}
+	now := a.now()
+	skew := now.Sub(time.UnixMilli(int64(ts * 1000)))
+	if skew < 0 {
+		skew = -skew
+	}
+	if skew > a.config.HMACMaxSkew {
+		return "", fmt.Errorf("%w: timestamp outside allowed skew", ErrUnauthenticated)
+	}
+
+	expected := SignHMAC(secret, clientID, timestamp, method)
+	if !hmac.Equal([]byte(expected), []byte(strings.ToLower(signature))) {
+		return "", ErrUnauthenticated
+	}
+	if !a.firstUse(clientID+":"+timestamp, now) {
+		return "", fmt.Errorf("%w: token already used", ErrUnauthenticated)
+	}
+	return clientID, nil
+}
+
+// firstUse records an HMAC token and reports whether it was not seen
+// before. Tokens are forgotten once their timestamp is outside the allowed
+// skew, after which they are rejected anyway.
+func (a *Authenticator) firstUse(key string, now time.Time) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	window := 2 * a.config.HMACMaxSkew
+	if now.Sub(a.lastPrune) > a.config.HMACMaxSkew {
+		for seenKey, at := range a.seen {
+			if now.Sub(at) > window {
+				delete(a.seen, seenKey)
+			}
+		}
+		a.lastPrune = now
+	}
+
+	if at, exists := a.seen[key]; exists && now.Sub(at) <= window {
+		return false
+	}
+	a.seen[key] = now
+	return true
+}
+
+// verifyJWT validates an HS256 JWT and returns the configured client claim
+func (a *Authenticator) verifyJWT(token string) (string, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return "", fmt.Errorf("%w: malformed token", ErrUnauthenticated)
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil || header.Alg != "HS256" {
+		return "", fmt.Errorf("%w: unsupported token algorithm", ErrUnauthenticated)
+	}
+
+	mac := hmac.New(sha256.New, []byte(a.config.JWT.Secret))
+	mac.Write([]byte(parts[0] + "." + parts[1]))
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil || !hmac.Equal(signature, mac.Sum(nil)) {
+		return "", fmt.Errorf("%w: invalid token signature", ErrUnauthenticated)
+	}
+
+	var claims map[string]interface{}
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return "", fmt.Errorf("%w: malformed token claims", ErrUnauthenticated)
+	}
+
+	now := a.now().Unix()
+	exp, ok := claims["exp"].(float64)
+	if !ok {
+		return "", fmt.Errorf("%w: token has no exp claim", ErrUnauthenticated)
+	}
+	if now >= int64(exp) {
+		return "", fmt.Errorf("%w: token expired", ErrUnauthenticated)
+	}
+	if nbf, ok := claims["nbf"].(float64); ok && now < int64(nbf) {
+		return "", fmt.Errorf("%w: token not yet valid", ErrUnauthenticated)
+	}
+	if a.config.JWT.Issuer != "" && claims["iss"] != a.config.JWT.Issuer {
+		return "", fmt.Errorf("%w: unexpected token issuer", ErrUnauthenticated)
+	}
+	if a.config.JWT.Audience != "" && !hasAudience(claims["aud"], a.config.JWT.Audience) {
+		return "", fmt.Errorf("%w: unexpected token audience", ErrUnauthenticated)
+	}
+
+	clientID, _ := claims[a.config.JWT.ClientClaim].(string)
+	if clientID == "" {
+		return "", fmt.Errorf("%w: token has no %s claim", ErrUnauthenticated, a.config.JWT.ClientClaim)
+	}
+	return clientID, nil
+}
+
+// decodeSegment decodes a base64url JSON token segment
+func decodeSegment(segment string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// hasAudience checks a string or array aud claim
+func hasAudience(aud interface{}, expected string) bool {
+	switch v := aud.(type) {
+	case string:
+		return v == expected
+	case []interface{}:
+		for _, item := range v {
+			if item == expected {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,111 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/fluxo/export-middleware/pkg/config"
+)
+
+func signJWT(secret string, claims string) string {
+	header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`))
+	payload := base64.RawURLEncoding.EncodeToString([]byte(claims))
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(header + "." + payload))
+	return header + "." + payload + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func newTestAuthenticator() *Authenticator {
+	return NewAuthenticator(&config.SecurityConfig{
+		AuthEnabled:    true,
+		AllowedClients: []string{"billing", "reports"},
+		AdminClients:   []string{"reports"},
+		APIKeys:        map[string]string{"key-1": "billing", "key-2": "intruder"},
+		HMACSecrets:    map[string]string{"billing": "hmac-secret"},
+		HMACMaxSkew:    time.Minute,
+		JWT: config.JWTConfig{
+			Secret:      "jwt-secret",
+			Issuer:      "issuer",
+			ClientClaim: "sub",
+		},
+	})
+}
+
+func TestAuthenticate(t *testing.T) {
+	a := newTestAuthenticator()
+	now := time.Now()
+	ts := strconv.FormatInt(now.Unix(), 10)
+	stale := strconv.FormatInt(now.Add(-time.Hour).Unix(), 10)
+	exp := strconv.FormatInt(now.Add(time.Hour).Unix(), 10)
+	method := "/export.ExportService/ListTasks"
+
+	tests := []struct {
+		name    string
+		creds   Credentials
+		client  string
+		wantErr error
+	}{
+		{"api key", Credentials{APIKey: "key-1"}, "billing", nil},
+		{"unknown api key", Credentials{APIKey: "nope"}, "", ErrUnauthenticated},
+		{"client not allowed", Credentials{APIKey: "key-2"}, "", ErrClientNotAllowed},
+		{"jwt", Credentials{BearerToken: signJWT("jwt-secret", `{"sub":"reports","iss":"issuer","exp":`+exp+`}`)}, "reports", nil},
+		{"jwt wrong secret", Credentials{BearerToken: signJWT("other", `{"sub":"reports","iss":"issuer","exp":`+exp+`}`)}, "", ErrUnauthenticated},
+		{"jwt expired", Credentials{BearerToken: signJWT("jwt-secret", `{"sub":"reports","iss":"issuer","exp":1}`)}, "", ErrUnauthenticated},
+		{"jwt without expiry", Credentials{BearerToken: signJWT("jwt-secret", `{"sub":"reports","iss":"issuer"}`)}, "", ErrUnauthenticated},
+		{"jwt wrong issuer", Credentials{BearerToken: signJWT("jwt-secret", `{"sub":"reports","iss":"other","exp":`+exp+`}`)}, "", ErrUnauthenticated},
+		{"hmac", Credentials{ClientID: "billing", Timestamp: ts, Method: method, Signature: SignHMAC("hmac-secret", "billing", ts, method)}, "billing", nil},
+		{"hmac stale", Credentials{ClientID: "billing", Timestamp: stale, Method: method, Signature: SignHMAC("hmac-secret", "billing", stale, method)}, "", ErrUnauthenticated},
+		{"hmac bad signature", Credentials{ClientID: "billing", Timestamp: ts, Method: method, Signature: SignHMAC("wrong", "billing", ts, method)}, "", ErrUnauthenticated},
+		{"hmac other method", Credentials{ClientID: "billing", Timestamp: ts, Method: "/export.ExportService/DeleteExport", Signature: SignHMAC("hmac-secret", "billing", ts, method)}, "", ErrUnauthenticated},
+		{"client certificate", Credentials{Certificate: "billing"}, "billing", nil},
+		{"no credentials", Credentials{}, "", ErrUnauthenticated},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, err := a.Authenticate(tt.creds)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("Expected error %v, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if client != tt.client {
+				t.Errorf("Expected client %q, got %q", tt.client, client)
+			}
+		})
+	}
+
+	if !a.IsAdmin("reports") || a.IsAdmin("billing") {
+		t.Error("Unexpected admin classification")
+	}
+}
+
+func TestAuthenticate_HMACReplay(t *testing.T) {
+	a := newTestAuthenticator()
+	method := "/export.ExportService/ListTasks"
+	ts := strconv.FormatInt(time.Now().Unix(), 10)
+	creds := Credentials{ClientID: "billing", Timestamp: ts, Method: method, Signature: SignHMAC("hmac-secret", "billing", ts, method)}
+
+	if _, err := a.Authenticate(creds); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, err := a.Authenticate(creds); !errors.Is(err, ErrUnauthenticated) {
+		t.Errorf("Expected replayed token to be rejected, got %v", err)
+	}
+
+	// A fractional timestamp allows another call within the same second
+	fractional := ts + ".5"
+	creds.Timestamp, creds.Signature = fractional, SignHMAC("hmac-secret", "billing", fractional, method)
+	if _, err := a.Authenticate(creds); err != nil {
+		t.Errorf("Expected fractional timestamp to be accepted, got %v", err)
+	}
+}
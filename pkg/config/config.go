@@ -2,6 +2,7 @@ package config
 
 import (
 	"fmt"
+	"net/url"
 	"os"
 	"time"
 
@@ -10,22 +11,31 @@ import (
 
 // Config represents the complete service configuration
 type Config struct {
-	Server      ServerConfig      `yaml:"server"`
-	Concurrency ConcurrencyConfig `yaml:"concurrency"`
-	Performance PerformanceConfig `yaml:"performance"`
-	Storage     StorageConfig     `yaml:"storage"`
-	OSS         OSSConfig         `yaml:"oss"`
-	Security    SecurityConfig    `yaml:"security"`
-	Logging     LoggingConfig     `yaml:"logging"`
-	Monitoring  MonitoringConfig  `yaml:"monitoring"`
+	Server        ServerConfig        `yaml:"server"`
+	Concurrency   ConcurrencyConfig   `yaml:"concurrency"`
+	Quotas        QuotasConfig        `yaml:"quotas"`
+	Scheduling    SchedulingConfig    `yaml:"scheduling"`
+	Performance   PerformanceConfig   `yaml:"performance"`
+	Storage       StorageConfig       `yaml:"storage"`
+	OSS           OSSConfig           `yaml:"oss"`
+	Downloads     DownloadsConfig     `yaml:"downloads"`
+	Retention     RetentionConfig     `yaml:"retention"`
+	Security      SecurityConfig      `yaml:"security"`
+	Webhook       WebhookConfig       `yaml:"webhook"`
+	Notifications NotificationsConfig `yaml:"notifications"`
+	Logging       LoggingConfig       `yaml:"logging"`
+	Monitoring    MonitoringConfig    `yaml:"monitoring"`
 }
 
 // ServerConfig contains gRPC server configuration
 type ServerConfig struct {
-	Port           int           `yaml:"port"`
-	StatusPort     int           `yaml:"status_port"`
-	MaxConnections int           `yaml:"max_connections"`
-	Timeout        time.Duration `yaml:"timeout"`
+	Port             int           `yaml:"port"`
+	StatusPort       int           `yaml:"status_port"`
+	MaxConnections   int           `yaml:"max_connections"`
+	Timeout          time.Duration `yaml:"timeout"`
+	ProgressInterval time.Duration `yaml:"progress_interval"`
+	ConfigWatch      time.Duration `yaml:"config_watch"`  // Poll interval for config file changes (0 = reload on SIGHUP only)
+	DrainTimeout     time.Duration `yaml:"drain_timeout"` // Time running tasks get to finish on shutdown
 }
 
 // ConcurrencyConfig contains task concurrency settings
@@ -33,6 +43,54 @@ type ConcurrencyConfig struct {
 	MaxConcurrentTasks int           `yaml:"max_concurrent_tasks"`
 	TaskQueueSize      int           `yaml:"task_queue_size"`
 	QueueTimeout       time.Duration `yaml:"queue_timeout"`
+	IdempotencyWindow  time.Duration `yaml:"idempotency_window"`
+	TaskHistory        time.Duration `yaml:"task_history"` // How long finished tasks stay queryable in memory
+}
+
+// QuotasConfig contains per-client limits. Clients without an entry in
+// Clients get the Default limits.
+type QuotasConfig struct {
+	Enabled bool                   `yaml:"enabled"`
+	Default ClientQuota            `yaml:"default"`
+	Clients map[string]ClientQuota `yaml:"clients"` // client ID -> limits
+}
+
+// ClientQuota contains the limits of a single client. Zero means unlimited.
+type ClientQuota struct {
+	MaxConcurrentTasks int   `yaml:"max_concurrent_tasks"` // Tasks not yet completed, failed or cancelled
+	MaxTasksPerHour    int   `yaml:"max_tasks_per_hour"`   // Tasks created in the last hour
+	MaxRecordsPerTask  int64 `yaml:"max_records_per_task"` // Records written to a single task
+	MaxBytesPerDay     int64 `yaml:"max_bytes_per_day"`    // File bytes written in the last 24 hours
+}
+
+// For returns the limits that apply to a client
+func (q *QuotasConfig) For(clientID string) ClientQuota {
+	if quota, exists := q.Clients[clientID]; exists {
+		return quota
+	}
+	return q.Default
+}
+
+// Task priority range
+const (
+	MinPriority = 1
+	MaxPriority = 9
+)
+
+// SchedulingConfig contains task queue ordering settings. Queued tasks run
+// by priority, then by weighted fair share across clients, then in arrival
+// order.
+type SchedulingConfig struct {
+	DefaultPriority int                         `yaml:"default_priority"` // Used when the metadata sets no priority
+	MaxPriority     int                         `yaml:"max_priority"`     // Highest priority a client may request
+	AgingInterval   time.Duration               `yaml:"aging_interval"`   // Waiting time that raises a task's priority by one (0 disables aging)
+	Clients         map[string]ClientScheduling `yaml:"clients"`          // client ID -> scheduling settings
+}
+
+// ClientScheduling contains the scheduling settings of a single client
+type ClientScheduling struct {
+	Weight      int `yaml:"weight"`       // Share relative to other clients (0 = 1)
+	MaxPriority int `yaml:"max_priority"` // Overrides the global maximum (0 = global)
 }
 
 // PerformanceConfig contains resource limit settings
@@ -51,22 +109,153 @@ type StorageConfig struct {
 
 // OSSConfig contains Alibaba Cloud OSS settings
 type OSSConfig struct {
-	Endpoint        string        `yaml:"endpoint"`
-	Bucket          string        `yaml:"bucket"`
-	AccessKeyID     string        `yaml:"access_key_id"`
-	AccessKeySecret string        `yaml:"access_key_secret"`
-	PartSize        int64         `yaml:"part_size"`
-	SignedURLExpiry time.Duration `yaml:"signed_url_expiry"`
-	MaxRetries      int           `yaml:"max_retries"`
-	ParallelParts   int           `yaml:"parallel_parts"`
-	UploadTimeout   time.Duration `yaml:"upload_timeout"`
+	Endpoint          string            `yaml:"endpoint"`
+	Bucket            string            `yaml:"bucket"`
+	AccessKeyID       string            `yaml:"access_key_id"`
+	AccessKeySecret   string            `yaml:"access_key_secret"`
+	PartSize          int64             `yaml:"part_size"`
+	SignedURLExpiry   time.Duration     `yaml:"signed_url_expiry"`
+	MaxRetries        int               `yaml:"max_retries"`
+	InitialBackoff    time.Duration     `yaml:"initial_backoff"` // First retry delay, doubled on each attempt, with jitter
+	MaxBackoff        time.Duration     `yaml:"max_backoff"`     // Maximum retry delay
+	ParallelParts     int               `yaml:"parallel_parts"`
+	UploadTimeout     time.Duration     `yaml:"upload_timeout"`
+	StaleUploadAge    time.Duration     `yaml:"stale_upload_age"`    // Unfinished multi-part uploads older than this are aborted (0 = never)
+	StreamingUpload   string            `yaml:"streaming_upload"`    // CSV upload while writing: off, disk or memory
+	ObjectKeyTemplate string            `yaml:"object_key_template"` // e.g. exports/{yyyy}/{mm}/{dd}/{task_id}/{filename}
+	ObjectTags        map[string]string `yaml:"object_tags"`         // Tags for lifecycle rules, values may use key template placeholders
+	Encryption        EncryptionConfig  `yaml:"encryption"`
+}
+
+// EncryptionConfig controls how exports are encrypted in OSS
+type EncryptionConfig struct {
+	ServerSide      string            `yaml:"server_side"`        // Server-side encryption: "" (bucket default), AES256 or KMS
+	KMSKeyID        string            `yaml:"kms_key_id"`         // KMS key for server-side encryption, empty = OSS-managed key
+	ClientKMSKeyIDs map[string]string `yaml:"client_kms_key_ids"` // KMS key per client ID, overriding kms_key_id
+	ClientSide      bool              `yaml:"client_side"`        // Encrypt files with a per-export data key before upload
+}
+
+// Server-side encryption methods
+const (
+	ServerSideEncryptionAES256 = "AES256"
+	ServerSideEncryptionKMS    = "KMS"
+)
+
+// KMSKeyFor returns the KMS key that encrypts the exports of a client
+func (e *EncryptionConfig) KMSKeyFor(clientID string) string {
+	if keyID, ok := e.ClientKMSKeyIDs[clientID]; ok && clientID != "" {
+		return keyID
+	}
+	return e.KMSKeyID
+}
+
+// Streaming upload modes for CSV exports
+const (
+	StreamingUploadOff    = "off"    // Upload the file after it is finalized
+	StreamingUploadDisk   = "disk"   // Upload parts while writing, keeping a local copy
+	StreamingUploadMemory = "memory" // Upload parts while writing, without a local file
+)
+
+// DownloadsConfig controls the download URLs issued by GetDownloadURL
+type DownloadsConfig struct {
+	MaxURLExpiry   time.Duration `yaml:"max_url_expiry"`  // Cap on the expiry callers may request
+	LinkPort       int           `yaml:"link_port"`       // HTTP port serving single-use links (0 = disabled)
+	LinkBaseURL    string        `yaml:"link_base_url"`   // Public URL of the link server, e.g. https://exports.example.com
+	RedirectExpiry time.Duration `yaml:"redirect_expiry"` // Validity of the signed URL a single-use link redirects to
+	LinkSecret     string        `yaml:"link_secret"`     // HMAC-SHA256 key signing single-use links
+}
+
+// RetentionConfig controls how long exported objects are kept in OSS
+type RetentionConfig struct {
+	DefaultPeriod   time.Duration `yaml:"default_period"`   // Retention of exports that do not request one (0 = keep indefinitely)
+	MaxPeriod       time.Duration `yaml:"max_period"`       // Cap on requested retention (0 = no cap)
+	JanitorInterval time.Duration `yaml:"janitor_interval"` // How often expired objects are deleted (0 = never)
 }
 
 // SecurityConfig contains security settings
 type SecurityConfig struct {
-	AuthEnabled    bool     `yaml:"auth_enabled"`
-	TLSEnabled     bool     `yaml:"tls_enabled"`
-	AllowedClients []string `yaml:"allowed_clients"`
+	AuthEnabled    bool              `yaml:"auth_enabled"`
+	TLSEnabled     bool              `yaml:"tls_enabled"`
+	TLS            TLSConfig         `yaml:"tls"`
+	AllowedClients []string          `yaml:"allowed_clients"`
+	AdminClients   []string          `yaml:"admin_clients"`
+	APIKeys        map[string]string `yaml:"api_keys"`     // API key -> client ID
+	HMACSecrets    map[string]string `yaml:"hmac_secrets"` // client ID -> shared secret
+	HMACMaxSkew    time.Duration     `yaml:"hmac_max_skew"`
+	JWT            JWTConfig         `yaml:"jwt"`
+}
+
+// TLSConfig contains server certificate and mutual TLS settings
+type TLSConfig struct {
+	CertFile          string        `yaml:"cert_file"`
+	KeyFile           string        `yaml:"key_file"`
+	ClientCAFile      string        `yaml:"client_ca_file"`      // Enables mTLS when set
+	RequireClientCert bool          `yaml:"require_client_cert"` // Reject clients without a certificate
+	ReloadInterval    time.Duration `yaml:"reload_interval"`     // How often certificate files are checked for changes
+}
+
+// JWTConfig contains settings for HS256 JWT authentication
+type JWTConfig struct {
+	Secret      string `yaml:"secret"`
+	Issuer      string `yaml:"issuer"`
+	Audience    string `yaml:"audience"`
+	ClientClaim string `yaml:"client_claim"`
+}
+
+// WebhookConfig contains task completion callback settings
+type WebhookConfig struct {
+	Enabled              bool          `yaml:"enabled"` // Accept callback_url in export requests
+	Secret               string        `yaml:"secret"`
+	Timeout              time.Duration `yaml:"timeout"`
+	MaxRetries           int           `yaml:"max_retries"`
+	InitialBackoff       time.Duration `yaml:"initial_backoff"`
+	MaxBackoff           time.Duration `yaml:"max_backoff"`
+	AllowedHosts         []string      `yaml:"allowed_hosts"`          // Callback hosts, e.g. hooks.example.com or *.example.com (empty = any)
+	AllowPrivateNetworks bool          `yaml:"allow_private_networks"` // Allow callbacks to loopback, private and link-local addresses
+}
+
+// NotificationsConfig contains task lifecycle event sink settings
+type NotificationsConfig struct {
+	Enabled        bool                 `yaml:"enabled"`
+	Sink           string               `yaml:"sink"` // redis, kafka_rest_proxy or nats
+	BufferSize     int                  `yaml:"buffer_size"`
+	Timeout        time.Duration        `yaml:"timeout"`
+	Redis          RedisNotifierConfig  `yaml:"redis"`
+	KafkaRestProxy KafkaRestProxyConfig `yaml:"kafka_rest_proxy"`
+	NATS           NATSNotifierConfig   `yaml:"nats"`
+}
+
+// NotifierTLSConfig enables TLS on the connection to a notification sink
+type NotifierTLSConfig struct {
+	Enabled bool   `yaml:"enabled"`
+	CAFile  string `yaml:"ca_file"` // CA bundle verifying the server (empty = system roots)
+}
+
+// RedisNotifierConfig contains Redis Streams sink settings
+type RedisNotifierConfig struct {
+	Address  string            `yaml:"address"`
+	Password string            `yaml:"password"`
+	DB       int               `yaml:"db"`
+	Stream   string            `yaml:"stream"`
+	MaxLen   int64             `yaml:"max_len"`
+	TLS      NotifierTLSConfig `yaml:"tls"`
+}
+
+// KafkaRestProxyConfig contains the settings of the sink producing to
+// Kafka through a Kafka REST Proxy; there is no native Kafka client
+type KafkaRestProxyConfig struct {
+	URL   string `yaml:"url"` // REST Proxy base URL; https is verified against the system roots
+	Topic string `yaml:"topic"`
+}
+
+// NATSNotifierConfig contains NATS sink settings
+type NATSNotifierConfig struct {
+	Address  string            `yaml:"address"`
+	Subject  string            `yaml:"subject"`
+	Username string            `yaml:"username"`
+	Password string            `yaml:"password"`
+	Token    string            `yaml:"token"`
+	TLS      NotifierTLSConfig `yaml:"tls"`
 }
 
 // LoggingConfig contains logging settings
@@ -87,15 +276,24 @@ type MonitoringConfig struct {
 func DefaultConfig() *Config {
 	return &Config{
 		Server: ServerConfig{
-			Port:           9090,
-			StatusPort:     9091,
-			MaxConnections: 100,
-			Timeout:        30 * time.Second,
+			Port:             9090,
+			StatusPort:       9091,
+			MaxConnections:   100,
+			Timeout:          30 * time.Second,
+			ProgressInterval: 2 * time.Second,
+			DrainTimeout:     5 * time.Minute,
 		},
 		Concurrency: ConcurrencyConfig{
 			MaxConcurrentTasks: 10,
 			TaskQueueSize:      100,
 			QueueTimeout:       5 * time.Minute,
+			IdempotencyWindow:  24 * time.Hour,
+			TaskHistory:        7 * 24 * time.Hour,
+		},
+		Scheduling: SchedulingConfig{
+			DefaultPriority: 5,
+			MaxPriority:     5,
+			AgingInterval:   1 * time.Minute,
 		},
 		Performance: PerformanceConfig{
 			BufferSize:   10 * 1024 * 1024, // 10MB
@@ -108,16 +306,60 @@ func DefaultConfig() *Config {
 			CleanupEnabled: true,
 		},
 		OSS: OSSConfig{
-			PartSize:        10 * 1024 * 1024, // 10MB
-			SignedURLExpiry: 7 * 24 * time.Hour,
-			MaxRetries:      3,
-			ParallelParts:   5,
-			UploadTimeout:   30 * time.Minute,
+			PartSize:          10 * 1024 * 1024, // 10MB
+			SignedURLExpiry:   7 * 24 * time.Hour,
+			MaxRetries:        3,
+			InitialBackoff:    1 * time.Second,
+			MaxBackoff:        30 * time.Second,
+			ParallelParts:     5,
+			UploadTimeout:     30 * time.Minute,
+			StaleUploadAge:    24 * time.Hour,
+			StreamingUpload:   StreamingUploadOff,
+			ObjectKeyTemplate: "exports/{yyyy}/{mm}/{dd}/{task_id}/{filename}",
+		},
+		Downloads: DownloadsConfig{
+			MaxURLExpiry:   7 * 24 * time.Hour,
+			RedirectExpiry: 1 * time.Minute,
+		},
+		Retention: RetentionConfig{
+			JanitorInterval: 1 * time.Hour,
 		},
 		Security: SecurityConfig{
-			AuthEnabled:    false,
-			TLSEnabled:     false,
+			AuthEnabled: false,
+			TLSEnabled:  false,
+			TLS: TLSConfig{
+				RequireClientCert: true,
+				ReloadInterval:    30 * time.Second,
+			},
 			AllowedClients: []string{},
+			HMACMaxSkew:    5 * time.Minute,
+			JWT: JWTConfig{
+				ClientClaim: "sub",
+			},
+		},
+		Webhook: WebhookConfig{
+			Timeout:        10 * time.Second,
+			MaxRetries:     5,
+			InitialBackoff: 1 * time.Second,
+			MaxBackoff:     1 * time.Minute,
+		},
+		Notifications: NotificationsConfig{
+			Enabled:    false,
+			Sink:       "redis",
+			BufferSize: 1000,
+			Timeout:    5 * time.Second,
+			Redis: RedisNotifierConfig{
+				Address: "localhost:6379",
+				Stream:  "export-events",
+				MaxLen:  100000,
+			},
+			KafkaRestProxy: KafkaRestProxyConfig{
+				Topic: "export-events",
+			},
+			NATS: NATSNotifierConfig{
+				Address: "localhost:4222",
+				Subject: "export.events",
+			},
 		},
 		Logging: LoggingConfig{
 			Level:         "info",
@@ -174,6 +416,21 @@ func (c *Config) applyEnvOverrides() {
 	if val := os.Getenv("OSS_ACCESS_KEY_SECRET"); val != "" {
 		c.OSS.AccessKeySecret = val
 	}
+	if val := os.Getenv("JWT_SECRET"); val != "" {
+		c.Security.JWT.Secret = val
+	}
+	if val := os.Getenv("WEBHOOK_SECRET"); val != "" {
+		c.Webhook.Secret = val
+	}
+	if val := os.Getenv("DOWNLOAD_LINK_SECRET"); val != "" {
+		c.Downloads.LinkSecret = val
+	}
+	if val := os.Getenv("NOTIFY_REDIS_PASSWORD"); val != "" {
+		c.Notifications.Redis.Password = val
+	}
+	if val := os.Getenv("NOTIFY_NATS_PASSWORD"); val != "" {
+		c.Notifications.NATS.Password = val
+	}
 	if val := os.Getenv("LOG_LEVEL"); val != "" {
 		c.Logging.Level = val
 	}
@@ -187,12 +444,88 @@ func (c *Config) Validate() error {
 	if c.Server.StatusPort <= 0 || c.Server.StatusPort > 65535 {
 		return fmt.Errorf("invalid status port: %d", c.Server.StatusPort)
 	}
+	if c.Server.ProgressInterval <= 0 {
+		return fmt.Errorf("progress interval must be positive")
+	}
+	if c.Server.ConfigWatch < 0 {
+		return fmt.Errorf("config watch interval cannot be negative")
+	}
+	if c.Server.DrainTimeout < 0 {
+		return fmt.Errorf("drain timeout cannot be negative")
+	}
 	if c.Concurrency.MaxConcurrentTasks <= 0 {
 		return fmt.Errorf("max concurrent tasks must be positive")
 	}
 	if c.Concurrency.TaskQueueSize < 0 {
 		return fmt.Errorf("task queue size cannot be negative")
 	}
+	if c.Concurrency.IdempotencyWindow < 0 {
+		return fmt.Errorf("idempotency window cannot be negative")
+	}
+	if c.Concurrency.TaskHistory <= 0 {
+		return fmt.Errorf("task history must be positive")
+	}
+	if err := c.Scheduling.validate(); err != nil {
+		return err
+	}
+	if c.Quotas.Enabled {
+		if err := c.Quotas.Default.validate(); err != nil {
+			return fmt.Errorf("default quota: %w", err)
+		}
+		for clientID, quota := range c.Quotas.Clients {
+			if err := quota.validate(); err != nil {
+				return fmt.Errorf("quota for client %s: %w", clientID, err)
+			}
+		}
+	}
+	if c.Security.TLSEnabled {
+		if c.Security.TLS.CertFile == "" || c.Security.TLS.KeyFile == "" {
+			return fmt.Errorf("tls enabled but cert_file or key_file not configured")
+		}
+		if c.Security.TLS.ReloadInterval <= 0 {
+			return fmt.Errorf("tls reload interval must be positive")
+		}
+	}
+	if c.Security.AuthEnabled {
+		mtls := c.Security.TLSEnabled && c.Security.TLS.ClientCAFile != ""
+		if len(c.Security.APIKeys) == 0 && len(c.Security.HMACSecrets) == 0 && c.Security.JWT.Secret == "" && !mtls {
+			return fmt.Errorf("auth enabled but no api_keys, hmac_secrets, jwt secret or client_ca_file configured")
+		}
+		if c.Security.JWT.Secret != "" && c.Security.JWT.ClientClaim == "" {
+			return fmt.Errorf("jwt client_claim is required")
+		}
+	}
+	if c.Webhook.Enabled && c.Webhook.Secret == "" {
+		return fmt.Errorf("webhook secret is required when webhooks are enabled")
+	}
+	if c.Webhook.MaxRetries < 0 {
+		return fmt.Errorf("webhook max retries cannot be negative")
+	}
+	if c.Webhook.InitialBackoff <= 0 || c.Webhook.MaxBackoff < c.Webhook.InitialBackoff {
+		return fmt.Errorf("webhook backoff must be positive and max backoff at least the initial backoff")
+	}
+	if c.Downloads.MaxURLExpiry <= 0 || c.Downloads.RedirectExpiry <= 0 {
+		return fmt.Errorf("download max URL expiry and redirect expiry must be positive")
+	}
+	if c.Downloads.LinkPort < 0 || c.Downloads.LinkPort > 65535 {
+		return fmt.Errorf("invalid download link port: %d", c.Downloads.LinkPort)
+	}
+	if c.Downloads.LinkPort > 0 {
+		if u, err := url.Parse(c.Downloads.LinkBaseURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("download link_base_url must be an absolute http(s) URL when link_port is set")
+		}
+		if c.Downloads.LinkSecret == "" {
+			return fmt.Errorf("download link_secret is required when link_port is set")
+		}
+	}
+	if c.Retention.DefaultPeriod < 0 || c.Retention.MaxPeriod < 0 || c.Retention.JanitorInterval < 0 {
+		return fmt.Errorf("retention periods and janitor interval cannot be negative")
+	}
+	if c.Notifications.Enabled {
+		if err := c.Notifications.validate(); err != nil {
+			return err
+		}
+	}
 	if c.OSS.Endpoint == "" {
 		return fmt.Errorf("OSS endpoint is required")
 	}
@@ -205,5 +538,112 @@ func (c *Config) Validate() error {
 	if c.OSS.AccessKeySecret == "" {
 		return fmt.Errorf("OSS access key secret is required")
 	}
+	// OSS rejects parts below 100KB, except the last, and above 5GB
+	if c.OSS.PartSize < 100*1024 || c.OSS.PartSize > 5*1024*1024*1024 {
+		return fmt.Errorf("OSS part size must be between 100KB and 5GB")
+	}
+	if c.OSS.ParallelParts < 1 {
+		return fmt.Errorf("OSS parallel parts must be at least 1")
+	}
+	if c.OSS.MaxRetries < 0 {
+		return fmt.Errorf("OSS max retries cannot be negative")
+	}
+	if c.OSS.InitialBackoff <= 0 || c.OSS.MaxBackoff < c.OSS.InitialBackoff {
+		return fmt.Errorf("OSS backoff must be positive and max backoff at least the initial backoff")
+	}
+	if c.OSS.UploadTimeout < 0 {
+		return fmt.Errorf("OSS upload timeout cannot be negative")
+	}
+	if c.OSS.StaleUploadAge < 0 {
+		return fmt.Errorf("OSS stale upload age cannot be negative")
+	}
+	if c.OSS.StaleUploadAge > 0 && c.OSS.StaleUploadAge <= c.OSS.UploadTimeout {
+		return fmt.Errorf("OSS stale upload age must exceed the upload timeout")
+	}
+	if c.OSS.ObjectKeyTemplate == "" {
+		return fmt.Errorf("OSS object key template is required")
+	}
+	if len(c.OSS.ObjectTags) > 10 {
+		return fmt.Errorf("OSS allows at most 10 object tags")
+	}
+	switch c.OSS.Encryption.ServerSide {
+	case "", ServerSideEncryptionAES256:
+		if c.OSS.Encryption.KMSKeyID != "" || len(c.OSS.Encryption.ClientKMSKeyIDs) > 0 {
+			return fmt.Errorf("OSS KMS key IDs require server-side encryption KMS")
+		}
+	case ServerSideEncryptionKMS:
+	default:
+		return fmt.Errorf("OSS server-side encryption must be empty, AES256 or KMS")
+	}
+	// The data key is returned to the authenticated creator of a task;
+	// without authentication anyone could claim to be that client
+	if c.OSS.Encryption.ClientSide && !c.Security.AuthEnabled {
+		return fmt.Errorf("OSS client-side encryption requires security.auth_enabled")
+	}
+	switch c.OSS.StreamingUpload {
+	case StreamingUploadOff, StreamingUploadDisk, StreamingUploadMemory:
+	default:
+		return fmt.Errorf("OSS streaming upload must be off, disk or memory")
+	}
+	return nil
+}
+
+// validate checks priority bounds and client weights
+func (s *SchedulingConfig) validate() error {
+	if s.DefaultPriority < MinPriority || s.DefaultPriority > MaxPriority {
+		return fmt.Errorf("default priority must be between %d and %d", MinPriority, MaxPriority)
+	}
+	if s.MaxPriority < MinPriority || s.MaxPriority > MaxPriority {
+		return fmt.Errorf("max priority must be between %d and %d", MinPriority, MaxPriority)
+	}
+	if s.AgingInterval < 0 {
+		return fmt.Errorf("aging interval cannot be negative")
+	}
+	for clientID, client := range s.Clients {
+		if client.Weight < 0 {
+			return fmt.Errorf("scheduling weight for client %s cannot be negative", clientID)
+		}
+		if client.MaxPriority < 0 || client.MaxPriority > MaxPriority {
+			return fmt.Errorf("max priority for client %s must be between 0 and %d", clientID, MaxPriority)
+		}
+	}
+	return nil
+}
+
+// validate checks that no limit is negative
+func (q *ClientQuota) validate() error {
+	if q.MaxConcurrentTasks < 0 || q.MaxTasksPerHour < 0 || q.MaxRecordsPerTask < 0 || q.MaxBytesPerDay < 0 {
+		return fmt.Errorf("limits cannot be negative")
+	}
+	return nil
+}
+
+// validate checks the settings of the selected notification sink
+func (n *NotificationsConfig) validate() error {
+	if n.BufferSize <= 0 {
+		return fmt.Errorf("notification buffer size must be positive")
+	}
+	if n.Timeout <= 0 {
+		return fmt.Errorf("notification timeout must be positive")
+	}
+
+	switch n.Sink {
+	case "redis":
+		if n.Redis.Address == "" || n.Redis.Stream == "" {
+			return fmt.Errorf("redis notifier requires address and stream")
+		}
+	case "kafka_rest_proxy":
+		if n.KafkaRestProxy.URL == "" || n.KafkaRestProxy.Topic == "" {
+			return fmt.Errorf("kafka_rest_proxy notifier requires url and topic")
+		}
+	case "kafka":
+		return fmt.Errorf("notification sink kafka was renamed to kafka_rest_proxy: events are produced through a Kafka REST Proxy")
+	case "nats":
+		if n.NATS.Address == "" || n.NATS.Subject == "" {
+			return fmt.Errorf("nats notifier requires address and subject")
+		}
+	default:
+		return fmt.Errorf("unknown notification sink: %s", n.Sink)
+	}
 	return nil
 }
@@ -0,0 +1,73 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+// validConfig returns the default config with the required OSS settings
+func validConfig() *Config {
+	cfg := DefaultConfig()
+	cfg.OSS.Endpoint = "oss-cn-hangzhou.aliyuncs.com"
+	cfg.OSS.Bucket = "exports"
+	cfg.OSS.AccessKeyID = "id"
+	cfg.OSS.AccessKeySecret = "secret"
+	return cfg
+}
+
+func TestValidate(t *testing.T) {
+	if err := validConfig().Validate(); err != nil {
+		t.Fatalf("Expected valid config, got %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		modify func(*Config)
+		want   string
+	}{
+		{
+			"part size too small",
+			func(c *Config) { c.OSS.PartSize = 0 },
+			"part size must be between",
+		},
+		{
+			"part size too large",
+			func(c *Config) { c.OSS.PartSize = 6 * 1024 * 1024 * 1024 },
+			"part size must be between",
+		},
+		{
+			"webhooks without a secret",
+			func(c *Config) { c.Webhook.Enabled = true },
+			"webhook secret is required",
+		},
+		{
+			"client-side encryption without auth",
+			func(c *Config) { c.OSS.Encryption.ClientSide = true },
+			"client-side encryption requires",
+		},
+		{
+			"download links without a secret",
+			func(c *Config) {
+				c.Downloads.LinkPort = 9092
+				c.Downloads.LinkBaseURL = "https://exports.example.com"
+			},
+			"link_secret is required",
+		},
+	}
+	for _, tt := range tests {
+		cfg := validConfig()
+		tt.modify(cfg)
+		err := cfg.Validate()
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: expected error containing %q, got %v", tt.name, tt.want, err)
+		}
+	}
+
+	cfg := validConfig()
+	cfg.OSS.Encryption.ClientSide = true
+	cfg.Security.AuthEnabled = true
+	cfg.Security.APIKeys = map[string]string{"key": "client-a"}
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("Expected client-side encryption with auth to be valid, got %v", err)
+	}
+}
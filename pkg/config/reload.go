@@ -0,0 +1,45 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+)
+
+// Reload returns a copy of c with the settings of next that can change
+// while the service runs:
+//   - concurrency: max_concurrent_tasks, queue_timeout, idempotency_window,
+//     task_history
+//   - logging.level
+//   - storage.temp_retention
+//   - oss.signed_url_expiry
+//   - downloads.max_url_expiry
+//   - quotas
+//
+// It also returns the names of config sections in which next differs from
+// the result, i.e. changes that only take effect after a restart. c and
+// next are not modified.
+func (c *Config) Reload(next *Config) (*Config, []string) {
+	merged := *c
+
+	merged.Concurrency.MaxConcurrentTasks = next.Concurrency.MaxConcurrentTasks
+	merged.Concurrency.QueueTimeout = next.Concurrency.QueueTimeout
+	merged.Concurrency.IdempotencyWindow = next.Concurrency.IdempotencyWindow
+	merged.Concurrency.TaskHistory = next.Concurrency.TaskHistory
+	merged.Logging.Level = next.Logging.Level
+	merged.Storage.TempRetention = next.Storage.TempRetention
+	merged.OSS.SignedURLExpiry = next.OSS.SignedURLExpiry
+	merged.Downloads.MaxURLExpiry = next.Downloads.MaxURLExpiry
+	merged.Quotas = next.Quotas
+
+	var restart []string
+	mergedValue := reflect.ValueOf(merged)
+	nextValue := reflect.ValueOf(*next)
+	for i := 0; i < mergedValue.NumField(); i++ {
+		if !reflect.DeepEqual(mergedValue.Field(i).Interface(), nextValue.Field(i).Interface()) {
+			tag := mergedValue.Type().Field(i).Tag.Get("yaml")
+			restart = append(restart, strings.Split(tag, ",")[0])
+		}
+	}
+
+	return &merged, restart
+}
@@ -0,0 +1,50 @@
+package config
+
+import (
+	"testing"
+	"time"
+)
+
+func TestReload(t *testing.T) {
+	current := DefaultConfig()
+
+	next := DefaultConfig()
+	next.Concurrency.MaxConcurrentTasks = 20
+	next.Logging.Level = "debug"
+	next.Quotas.Enabled = true
+	next.Server.Port = 9999
+	next.Concurrency.TaskQueueSize = 5
+
+	merged, restart := current.Reload(next)
+
+	if merged.Concurrency.MaxConcurrentTasks != 20 || merged.Logging.Level != "debug" || !merged.Quotas.Enabled {
+		t.Error("Expected reloadable settings to be applied")
+	}
+	if merged.Server.Port != current.Server.Port || merged.Concurrency.TaskQueueSize != current.Concurrency.TaskQueueSize {
+		t.Error("Expected settings requiring a restart to be kept")
+	}
+	if current.Concurrency.MaxConcurrentTasks == 20 {
+		t.Error("Expected current config to be left unchanged")
+	}
+
+	want := map[string]bool{"server": true, "concurrency": true}
+	if len(restart) != len(want) {
+		t.Fatalf("Expected restart sections %v, got %v", want, restart)
+	}
+	for _, section := range restart {
+		if !want[section] {
+			t.Errorf("Unexpected restart section %q", section)
+		}
+	}
+
+	// Unchanged configs need no restart
+	if _, restart := current.Reload(DefaultConfig()); len(restart) != 0 {
+		t.Errorf("Expected no restart sections, got %v", restart)
+	}
+
+	next = DefaultConfig()
+	next.OSS.SignedURLExpiry = time.Hour
+	if merged, restart := current.Reload(next); merged.OSS.SignedURLExpiry != time.Hour || len(restart) != 0 {
+		t.Errorf("Expected signed URL expiry to reload without restart, got %v", restart)
+	}
+}
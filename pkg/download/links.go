@@ -0,0 +1,283 @@
+package download
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fluxo/export-middleware/pkg/config"
+	"github.com/fluxo/export-middleware/pkg/logger"
+)
+
+// linkPath is the path below which the link server serves links
+const linkPath = "/download/"
+
+// linksDir is the directory below the temp directory marking redeemed
+// links
+const linksDir = "links"
+
+// sweepInterval is how often markers of expired links are removed
+const sweepInterval = time.Minute
+
+// errLinkUsed is returned for links that were already redeemed
+var errLinkUsed = errors.New("link already used")
+
+// Signer signs download URLs for objects
+type Signer interface {
+	SignURL(objectKey string, expiry time.Duration) (string, error)
+}
+
+// Resolver finds the object of a task's export. It fails once the export
+// was deleted or has expired.
+type Resolver interface {
+	ObjectKey(taskID string) (string, error)
+}
+
+// token is the content of a link: the task, when the link expires and a
+// nonce making every link distinct
+type token struct {
+	taskID    string
+	expiresAt time.Time
+	nonce     string
+	mac       []byte
+}
+
+// Links issues single-use download links and serves them over HTTP. A
+// link is a token signed with link_secret naming the task, so links
+// survive restarts and are served by any instance with the same secret.
+// The object is looked up when a link is opened, so links stop working
+// when the export is deleted or expires. The first GET redirects to a
+// signed URL valid for redirect_expiry and marks the link as used in the
+// temp directory, which instances must share for links to be single-use
+// across them.
+type Links struct {
+	config   *config.DownloadsConfig
+	dir      string
+	resolver Resolver
+	signer   Signer
+	logger   *logger.Logger
+
+	mu        sync.Mutex
+	lastSweep time.Time
+
+	server *http.Server
+}
+
+// NewLinks creates a link server for the exports found by resolver, with
+// redeemed links marked below tempDir
+func NewLinks(cfg *config.DownloadsConfig, tempDir string, resolver Resolver, signer Signer, log *logger.Logger) *Links {
+	return &Links{
+		config:   cfg,
+		dir:      filepath.Join(tempDir, linksDir),
+		resolver: resolver,
+		signer:   signer,
+		logger:   log,
+	}
+}
+
+// Issue returns a single-use link to the export of a task, valid until it
+// is opened or expiry has passed
+func (l *Links) Issue(taskID string, expiry time.Duration) (string, time.Time, error) {
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return "", time.Time{}, fmt.Errorf("failed to generate link nonce: %w", err)
+	}
+	t := &token{
+		taskID:    taskID,
+		expiresAt: time.Now().Add(expiry).Truncate(time.Second),
+		nonce:     base64.RawURLEncoding.EncodeToString(nonce),
+	}
+	t.mac = l.sign(t)
+
+	return strings.TrimSuffix(l.config.LinkBaseURL, "/") + linkPath + t.String(), t.expiresAt, nil
+}
+
+// String encodes a token as <task_id>.<expiry>.<nonce>.<mac>
+func (t *token) String() string {
+	return t.payload() + "." + base64.RawURLEncoding.EncodeToString(t.mac)
+}
+
+// payload returns the signed part of a token
+func (t *token) payload() string {
+	return t.taskID + "." + strconv.FormatInt(t.expiresAt.Unix(), 10) + "." + t.nonce
+}
+
+// sign returns the MAC of a token
+func (l *Links) sign(t *token) []byte {
+	mac := hmac.New(sha256.New, []byte(l.config.LinkSecret))
+	mac.Write([]byte(t.payload()))
+	return mac.Sum(nil)
+}
+
+// parse decodes a link token and checks its signature and expiry
+func (l *Links) parse(s string) (*token, bool) {
+	parts := strings.Split(s, ".")
+	if len(parts) != 4 {
+		return nil, false
+	}
+	expires, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return nil, false
+	}
+	mac, err := base64.RawURLEncoding.DecodeString(parts[3])
+	if err != nil {
+		return nil, false
+	}
+
+	t := &token{taskID: parts[0], expiresAt: time.Unix(expires, 0), nonce: parts[2], mac: mac}
+	if !hmac.Equal(mac, l.sign(t)) || time.Now().After(t.expiresAt) {
+		return nil, false
+	}
+	return t, true
+}
+
+// markerPath returns the file marking a link as used
+func (l *Links) markerPath(t *token) string {
+	return filepath.Join(l.dir, hex.EncodeToString(t.mac))
+}
+
+// used reports whether a link was redeemed
+func (l *Links) used(t *token) bool {
+	_, err := os.Stat(l.markerPath(t))
+	return err == nil
+}
+
+// markUsed records that a link was redeemed. It returns errLinkUsed if the
+// link was redeemed before. The marker's modification time is the link's
+// expiry, after which the marker is removed.
+func (l *Links) markUsed(t *token) error {
+	l.sweep(time.Now())
+
+	if err := os.MkdirAll(l.dir, 0755); err != nil {
+		return fmt.Errorf("failed to create links directory: %w", err)
+	}
+	path := l.markerPath(t)
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+	if errors.Is(err, os.ErrExist) {
+		return errLinkUsed
+	}
+	if err != nil {
+		return fmt.Errorf("failed to mark link as used: %w", err)
+	}
+	file.Close()
+	return os.Chtimes(path, t.expiresAt, t.expiresAt)
+}
+
+// sweep removes the markers of expired links, at most once per
+// sweepInterval
+func (l *Links) sweep(now time.Time) {
+	l.mu.Lock()
+	if now.Sub(l.lastSweep) < sweepInterval {
+		l.mu.Unlock()
+		return
+	}
+	l.lastSweep = now
+	l.mu.Unlock()
+
+	entries, err := os.ReadDir(l.dir)
+	if err != nil {
+		return
+	}
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err == nil && now.After(info.ModTime()) {
+			os.Remove(filepath.Join(l.dir, entry.Name()))
+		}
+	}
+}
+
+// ServeHTTP redirects a valid link to a short-lived signed URL. Only GET
+// redeems a link; HEAD reports whether it can still be opened.
+func (l *Links) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	id, found := strings.CutPrefix(r.URL.Path, linkPath)
+	if !found || id == "" || strings.Contains(id, "/") {
+		http.NotFound(w, r)
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	t, ok := l.parse(id)
+	if !ok {
+		http.Error(w, "link expired or invalid", http.StatusGone)
+		return
+	}
+	objectKey, err := l.resolver.ObjectKey(t.taskID)
+	if err != nil {
+		http.Error(w, "export no longer available", http.StatusGone)
+		return
+	}
+
+	if r.Method == http.MethodHead {
+		if l.used(t) {
+			w.WriteHeader(http.StatusGone)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	signedURL, err := l.signer.SignURL(objectKey, l.config.RedirectExpiry)
+	if err != nil {
+		l.logger.Error("Failed to sign download URL", logger.Fields{"object_key": objectKey, "error": err.Error()})
+		http.Error(w, "failed to sign download URL", http.StatusInternalServerError)
+		return
+	}
+	if err := l.markUsed(t); err != nil {
+		if errors.Is(err, errLinkUsed) {
+			http.Error(w, "link expired or already used", http.StatusGone)
+			return
+		}
+		l.logger.Error("Failed to redeem download link", logger.Fields{"task_id": t.taskID, "error": err.Error()})
+		http.Error(w, "failed to redeem link", http.StatusInternalServerError)
+		return
+	}
+
+	l.logger.Info("Single-use download link redeemed", logger.Fields{"task_id": t.taskID, "object_key": objectKey})
+	http.Redirect(w, r, signedURL, http.StatusFound)
+}
+
+// Start serves links on link_port
+func (l *Links) Start() error {
+	lis, err := net.Listen("tcp", fmt.Sprintf(":%d", l.config.LinkPort))
+	if err != nil {
+		return fmt.Errorf("failed to listen: %w", err)
+	}
+
+	l.server = &http.Server{
+		Handler:           l,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	l.logger.Info("Download link server starting", logger.Fields{"port": l.config.LinkPort})
+
+	go func() {
+		if err := l.server.Serve(lis); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			l.logger.Error("Download link server error", logger.Fields{"error": err.Error()})
+		}
+	}()
+	return nil
+}
+
+// Stop shuts the link server down
+func (l *Links) Stop(ctx context.Context) error {
+	if l.server == nil {
+		return nil
+	}
+	return l.server.Shutdown(ctx)
+}
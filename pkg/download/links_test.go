@@ -0,0 +1,117 @@
+package download
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fluxo/export-middleware/pkg/config"
+	"github.com/fluxo/export-middleware/pkg/logger"
+)
+
+type fakeSigner struct{}
+
+func (fakeSigner) SignURL(objectKey string, expiry time.Duration) (string, error) {
+	return "https://bucket.oss.example.com/" + objectKey + "?Expires=" + expiry.String(), nil
+}
+
+// fakeResolver maps task IDs to object keys; deleted exports are missing
+type fakeResolver map[string]string
+
+func (r fakeResolver) ObjectKey(taskID string) (string, error) {
+	if key, ok := r[taskID]; ok {
+		return key, nil
+	}
+	return "", errors.New("task not found")
+}
+
+func newTestLinks(t *testing.T, dir string, resolver fakeResolver) *Links {
+	log, err := logger.New("error", "json", "stderr", false)
+	if err != nil {
+		t.Fatalf("Failed to create logger: %v", err)
+	}
+	return NewLinks(&config.DownloadsConfig{
+		LinkBaseURL:    "https://exports.example.com/",
+		RedirectExpiry: time.Minute,
+		LinkSecret:     "link-secret",
+	}, dir, resolver, fakeSigner{}, log)
+}
+
+func open(links *Links, method, url string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	links.ServeHTTP(rec, httptest.NewRequest(method, strings.TrimPrefix(url, "https://exports.example.com"), nil))
+	return rec
+}
+
+func TestLinks_SingleUse(t *testing.T) {
+	dir := t.TempDir()
+	resolver := fakeResolver{"task-1": "exports/task-1/report.csv"}
+	links := newTestLinks(t, dir, resolver)
+
+	url, expiresAt, err := links.Issue("task-1", time.Hour)
+	if err != nil {
+		t.Fatalf("Failed to issue link: %v", err)
+	}
+	if !strings.HasPrefix(url, "https://exports.example.com/download/") {
+		t.Errorf("Unexpected link %s", url)
+	}
+	if time.Until(expiresAt) <= 59*time.Minute {
+		t.Errorf("Expected link to expire in an hour, got %v", expiresAt)
+	}
+
+	// HEAD must not use up the link
+	if rec := open(links, http.MethodHead, url); rec.Code != http.StatusOK {
+		t.Fatalf("Expected HEAD to report a valid link, got %d", rec.Code)
+	}
+
+	rec := open(links, http.MethodGet, url)
+	if rec.Code != http.StatusFound {
+		t.Fatalf("Expected redirect, got %d", rec.Code)
+	}
+	if location := rec.Header().Get("Location"); location != "https://bucket.oss.example.com/exports/task-1/report.csv?Expires=1m0s" {
+		t.Errorf("Unexpected redirect to %s", location)
+	}
+
+	if rec := open(links, http.MethodGet, url); rec.Code != http.StatusGone {
+		t.Errorf("Expected used link to be gone, got %d", rec.Code)
+	}
+
+	// Another instance sharing the directory, or one after a restart,
+	// accepts new links but not used ones
+	restarted := newTestLinks(t, dir, resolver)
+	if rec := open(restarted, http.MethodGet, url); rec.Code != http.StatusGone {
+		t.Errorf("Expected used link to be gone after a restart, got %d", rec.Code)
+	}
+	fresh, _, _ := links.Issue("task-1", time.Hour)
+	if rec := open(restarted, http.MethodGet, fresh); rec.Code != http.StatusFound {
+		t.Errorf("Expected link to work after a restart, got %d", rec.Code)
+	}
+}
+
+func TestLinks_Invalid(t *testing.T) {
+	resolver := fakeResolver{"task-1": "exports/task-1/report.csv"}
+	links := newTestLinks(t, t.TempDir(), resolver)
+
+	expired, _, _ := links.Issue("task-1", -time.Second)
+	if rec := open(links, http.MethodGet, expired); rec.Code != http.StatusGone {
+		t.Errorf("Expected expired link to be gone, got %d", rec.Code)
+	}
+	if rec := open(links, http.MethodGet, "/download/unknown/extra"); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected malformed link to be not found, got %d", rec.Code)
+	}
+
+	valid, _, _ := links.Issue("task-1", time.Hour)
+	forged := strings.Replace(valid, "task-1", "task-2", 1)
+	if rec := open(links, http.MethodGet, forged); rec.Code != http.StatusGone {
+		t.Errorf("Expected forged link to be rejected, got %d", rec.Code)
+	}
+
+	// Links stop working once the export is deleted or expires
+	delete(resolver, "task-1")
+	if rec := open(links, http.MethodGet, valid); rec.Code != http.StatusGone {
+		t.Errorf("Expected link to a deleted export to be gone, got %d", rec.Code)
+	}
+}
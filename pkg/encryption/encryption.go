@@ -0,0 +1,293 @@
+// Package encryption implements the client-side encryption of export
+// files: AES-256-GCM over fixed-size chunks, so files of any size can be
+// encrypted and decrypted as streams.
+//
+// An encrypted file is a header followed by sealed chunks:
+//
+//	header: "XENC" | version (1 byte) | chunk size (4 bytes, big endian) | nonce prefix (7 bytes)
+//	chunk:  AES-GCM(chunk of up to chunk size plaintext bytes) with its 16-byte tag
+//
+// The nonce of chunk i is the nonce prefix, i as a 4-byte big endian
+// counter and a byte that is 1 for the last chunk and 0 otherwise. The
+// header is the additional data of every chunk. The last chunk may be
+// empty, so truncated files are detected.
+package encryption
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+)
+
+const (
+	// Algorithm names the format in object metadata and task statuses
+	Algorithm = "AES-256-GCM-CHUNKED"
+
+	// KeySize is the size of data keys in bytes
+	KeySize = 32
+
+	// ChunkSize is the plaintext size of every chunk but the last
+	ChunkSize = 64 * 1024
+
+	version     = 1
+	prefixSize  = 7
+	headerSize  = 4 + 1 + 4 + prefixSize
+	tagOverhead = 16
+)
+
+var magic = []byte("XENC")
+
+// ErrInvalidFile is returned when a file is not in the encrypted format
+var ErrInvalidFile = errors.New("not an encrypted export file")
+
+// GenerateKey returns a new random data key
+func GenerateKey() ([]byte, error) {
+	key := make([]byte, KeySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, fmt.Errorf("failed to generate data key: %w", err)
+	}
+	return key, nil
+}
+
+// newAEAD creates the AES-GCM cipher for a data key
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	if len(key) != KeySize {
+		return nil, fmt.Errorf("data key must be %d bytes, got %d", KeySize, len(key))
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// chunkNonce returns the nonce of a chunk
+func chunkNonce(prefix []byte, counter uint32, last bool) []byte {
+	nonce := make([]byte, 0, prefixSize+5)
+	nonce = append(nonce, prefix...)
+	nonce = binary.BigEndian.AppendUint32(nonce, counter)
+	if last {
+		return append(nonce, 1)
+	}
+	return append(nonce, 0)
+}
+
+// Writer encrypts everything written to it. Close must be called to write
+// the last chunk.
+type Writer struct {
+	w       io.Writer
+	aead    cipher.AEAD
+	header  []byte
+	prefix  []byte
+	counter uint32
+	buf     []byte
+	started bool
+	closed  bool
+}
+
+// NewWriter returns a Writer that writes the encryption of its input to w
+func NewWriter(w io.Writer, key []byte) (*Writer, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+
+	prefix := make([]byte, prefixSize)
+	if _, err := rand.Read(prefix); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	header := make([]byte, 0, headerSize)
+	header = append(header, magic...)
+	header = append(header, version)
+	header = binary.BigEndian.AppendUint32(header, ChunkSize)
+	header = append(header, prefix...)
+
+	return &Writer{
+		w:      w,
+		aead:   aead,
+		header: header,
+		prefix: prefix,
+		buf:    make([]byte, 0, ChunkSize),
+	}, nil
+}
+
+// Write implements io.Writer. A chunk is sealed once ChunkSize bytes are
+// buffered and more data follows.
+func (w *Writer) Write(p []byte) (int, error) {
+	if w.closed {
+		return 0, errors.New("write to closed encryption writer")
+	}
+
+	written := 0
+	for written < len(p) {
+		// A full buffer is only sealed when more data follows, as the
+		// last chunk is sealed differently
+		if len(w.buf) == ChunkSize {
+			if err := w.seal(false); err != nil {
+				return written, err
+			}
+		}
+		n := min(ChunkSize-len(w.buf), len(p)-written)
+		w.buf = append(w.buf, p[written:written+n]...)
+		written += n
+	}
+	return written, nil
+}
+
+// Close seals the last chunk. It does not close the underlying writer.
+func (w *Writer) Close() error {
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+	return w.seal(true)
+}
+
+// seal encrypts the buffered chunk and writes it
+func (w *Writer) seal(last bool) error {
+	if !w.started {
+		if _, err := w.w.Write(w.header); err != nil {
+			return err
+		}
+		w.started = true
+	}
+	if w.counter == math.MaxUint32 {
+		return errors.New("file too large to encrypt")
+	}
+
+	sealed := w.aead.Seal(nil, chunkNonce(w.prefix, w.counter, last), w.buf, w.header)
+	w.counter++
+	w.buf = w.buf[:0]
+	_, err := w.w.Write(sealed)
+	return err
+}
+
+// Reader decrypts a file written by Writer
+type Reader struct {
+	r       *bufio.Reader
+	aead    cipher.AEAD
+	header  []byte
+	prefix  []byte
+	chunk   int
+	counter uint32
+	plain   []byte
+	done    bool
+}
+
+// NewReader returns a Reader that decrypts r. Read fails if the file was
+// modified or truncated.
+func NewReader(r io.Reader, key []byte) (*Reader, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+
+	br := bufio.NewReader(r)
+	header := make([]byte, headerSize)
+	if _, err := io.ReadFull(br, header); err != nil {
+		return nil, ErrInvalidFile
+	}
+	if !bytes.Equal(header[:4], magic) || header[4] != version {
+		return nil, ErrInvalidFile
+	}
+	chunk := int(binary.BigEndian.Uint32(header[5:9]))
+	if chunk <= 0 || chunk > 16*ChunkSize {
+		return nil, ErrInvalidFile
+	}
+
+	return &Reader{
+		r:      br,
+		aead:   aead,
+		header: header,
+		prefix: header[9:],
+		chunk:  chunk,
+	}, nil
+}
+
+// Read implements io.Reader
+func (r *Reader) Read(p []byte) (int, error) {
+	for len(r.plain) == 0 {
+		if r.done {
+			return 0, io.EOF
+		}
+		if err := r.open(); err != nil {
+			return 0, err
+		}
+	}
+
+	n := copy(p, r.plain)
+	r.plain = r.plain[n:]
+	return n, nil
+}
+
+// open reads and decrypts the next chunk
+func (r *Reader) open() error {
+	sealed := make([]byte, r.chunk+tagOverhead)
+	n, err := io.ReadFull(r.r, sealed)
+	switch {
+	case err == io.ErrUnexpectedEOF || err == io.EOF:
+		// Short chunk: this must be the last one
+	case err != nil:
+		return err
+	}
+
+	last := n < len(sealed)
+	if !last {
+		if _, err := r.r.Peek(1); err == io.EOF {
+			last = true
+		}
+	}
+
+	plain, err := r.aead.Open(nil, chunkNonce(r.prefix, r.counter, last), sealed[:n], r.header)
+	if err != nil {
+		return fmt.Errorf("failed to decrypt chunk %d: %w", r.counter, err)
+	}
+	r.counter++
+	r.plain = plain
+	r.done = last
+	return nil
+}
+
+// EncryptFile writes the encryption of the file src to dst
+func EncryptFile(src, dst string, key []byte) (err error) {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if closeErr := out.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			os.Remove(dst)
+		}
+	}()
+
+	buffered := bufio.NewWriterSize(out, ChunkSize+tagOverhead)
+	w, err := NewWriter(buffered, key)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(w, in); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return buffered.Flush()
+}
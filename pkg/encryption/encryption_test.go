@@ -0,0 +1,80 @@
+package encryption
+
+import (
+	"bytes"
+	"crypto/rand"
+	"io"
+	"testing"
+)
+
+func encrypt(t *testing.T, key, plain []byte) []byte {
+	t.Helper()
+	var out bytes.Buffer
+	w, err := NewWriter(&out, key)
+	if err != nil {
+		t.Fatalf("Failed to create writer: %v", err)
+	}
+	// Uneven writes, so chunks span several calls
+	for len(plain) > 0 {
+		n := min(len(plain), 10000)
+		if _, err := w.Write(plain[:n]); err != nil {
+			t.Fatalf("Failed to write: %v", err)
+		}
+		plain = plain[n:]
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Failed to close writer: %v", err)
+	}
+	return out.Bytes()
+}
+
+func decrypt(key, sealed []byte) ([]byte, error) {
+	r, err := NewReader(bytes.NewReader(sealed), key)
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(r)
+}
+
+func TestRoundTrip(t *testing.T) {
+	key, err := GenerateKey()
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+
+	for _, size := range []int{0, 1, ChunkSize, ChunkSize + 1, 3*ChunkSize - 7} {
+		plain := make([]byte, size)
+		rand.Read(plain)
+
+		got, err := decrypt(key, encrypt(t, key, plain))
+		if err != nil {
+			t.Fatalf("Size %d: failed to decrypt: %v", size, err)
+		}
+		if !bytes.Equal(got, plain) {
+			t.Errorf("Size %d: decrypted data differs", size)
+		}
+	}
+}
+
+func TestTampering(t *testing.T) {
+	key, _ := GenerateKey()
+	plain := make([]byte, 2*ChunkSize+100)
+	sealed := encrypt(t, key, plain)
+
+	// A truncated file must not decrypt to a shorter file
+	truncated := sealed[:headerSize+ChunkSize+tagOverhead]
+	if _, err := decrypt(key, truncated); err == nil {
+		t.Error("Expected truncated file to fail")
+	}
+
+	modified := bytes.Clone(sealed)
+	modified[len(modified)-1] ^= 1
+	if _, err := decrypt(key, modified); err == nil {
+		t.Error("Expected modified file to fail")
+	}
+
+	otherKey, _ := GenerateKey()
+	if _, err := decrypt(otherKey, sealed); err == nil {
+		t.Error("Expected wrong key to fail")
+	}
+}
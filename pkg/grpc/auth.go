@@ -0,0 +1,141 @@
+package grpcserver
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	grpcStatus "google.golang.org/grpc/status"
+
+	"github.com/fluxo/export-middleware/pkg/auth"
+	"github.com/fluxo/export-middleware/pkg/logger"
+	"github.com/fluxo/export-middleware/pkg/taskmanager"
+	pb "github.com/fluxo/export-middleware/proto"
+)
+
+// Metadata keys carrying credentials
+const (
+	apiKeyHeader        = "x-api-key"
+	authorizationHeader = "authorization"
+	authTimestampHeader = "x-auth-timestamp"
+	authSignatureHeader = "x-auth-signature"
+)
+
+// healthMethodPrefix is the method prefix of the health service, which
+// load balancers and orchestrators probe without credentials
+var healthMethodPrefix = "/" + healthpb.Health_ServiceDesc.ServiceName + "/"
+
+// authenticatedStream overrides the context of a server stream with the
+// authenticated one
+type authenticatedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+// Context returns the authenticated context
+func (s *authenticatedStream) Context() context.Context {
+	return s.ctx
+}
+
+// unaryAuthInterceptor authenticates unary calls
+func (s *Server) unaryAuthInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	if strings.HasPrefix(info.FullMethod, healthMethodPrefix) {
+		return handler(ctx, req)
+	}
+	ctx, err := s.authenticate(ctx, info.FullMethod)
+	if err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+// streamAuthInterceptor authenticates streaming calls
+func (s *Server) streamAuthInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if strings.HasPrefix(info.FullMethod, healthMethodPrefix) {
+		return handler(srv, ss)
+	}
+	ctx, err := s.authenticate(ss.Context(), info.FullMethod)
+	if err != nil {
+		return err
+	}
+	return handler(srv, &authenticatedStream{ServerStream: ss, ctx: ctx})
+}
+
+// authenticate extracts credentials from the request metadata and stores
+// the resulting client identity in the context
+func (s *Server) authenticate(ctx context.Context, method string) (context.Context, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	first := func(key string) string {
+		if values := md.Get(key); len(values) > 0 {
+			return values[0]
+		}
+		return ""
+	}
+
+	creds := auth.Credentials{
+		APIKey:      first(apiKeyHeader),
+		ClientID:    first(clientIDHeader),
+		Timestamp:   first(authTimestampHeader),
+		Signature:   first(authSignatureHeader),
+		Method:      method,
+		Certificate: certificateIdentity(ctx),
+	}
+	if authz := first(authorizationHeader); len(authz) > 7 && strings.EqualFold(authz[:7], "bearer ") {
+		creds.BearerToken = strings.TrimSpace(authz[7:])
+	}
+
+	clientID, err := s.authenticator.Authenticate(creds)
+	if err != nil {
+		contextLogger := s.logger.WithContext(ctx).WithComponent("grpc_server")
+		if errors.Is(err, auth.ErrClientNotAllowed) {
+			contextLogger.LogWarn("AuthForbidden", "Client not allowed", logger.Fields{"method": method, "error": err.Error()})
+			return nil, grpcStatus.Error(codes.PermissionDenied, "client is not allowed")
+		}
+		contextLogger.LogWarn("AuthFailed", "Authentication failed", logger.Fields{"method": method, "error": err.Error()})
+		return nil, grpcStatus.Error(codes.Unauthenticated, "authentication required")
+	}
+
+	return taskmanager.WithClientID(ctx, clientID), nil
+}
+
+// authorizeTask checks that the caller owns a task. Admin clients may
+// access every task. Without authentication every caller is allowed.
+func (s *Server) authorizeTask(ctx context.Context, status *pb.TaskStatusResponse) error {
+	if s.authenticator == nil {
+		return nil
+	}
+
+	caller := taskmanager.ClientIDFromContext(ctx)
+	if s.authenticator.IsAdmin(caller) || status.ClientId == caller {
+		return nil
+	}
+
+	s.logger.WithContext(ctx).WithComponent("grpc_server").WithTaskID(status.TaskId).LogWarn(
+		"TaskAccessDenied",
+		"Client does not own the task",
+		logger.Fields{"client_id": caller},
+	)
+	return grpcStatus.Error(codes.PermissionDenied, "task belongs to another client")
+}
+
+// scopeClient resolves the client a request refers to. Without
+// authentication any client may be named. With authentication, callers
+// other than admins are restricted to themselves.
+func (s *Server) scopeClient(ctx context.Context, requested string) (string, error) {
+	if s.authenticator == nil {
+		return requested, nil
+	}
+
+	caller := taskmanager.ClientIDFromContext(ctx)
+	if s.authenticator.IsAdmin(caller) {
+		return requested, nil
+	}
+	if requested != "" && requested != caller {
+		return "", grpcStatus.Error(codes.PermissionDenied, "cannot access another client")
+	}
+	return caller, nil
+}
@@ -0,0 +1,50 @@
+package grpcserver
+
+import (
+	"context"
+
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+
+	"github.com/fluxo/export-middleware/pkg/taskmanager"
+)
+
+// clientIDHeader is the metadata key clients use to identify themselves
+const clientIDHeader = "x-client-id"
+
+// withClientIdentity stores the identity of the client in the request
+// context so the task manager can record who created a task. A verified
+// client certificate takes precedence over the declared x-client-id.
+func withClientIdentity(ctx context.Context) context.Context {
+	if taskmanager.ClientIDFromContext(ctx) != "" {
+		return ctx
+	}
+
+	if id := certificateIdentity(ctx); id != "" {
+		return taskmanager.WithClientID(ctx, id)
+	}
+
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ctx
+	}
+	if values := md.Get(clientIDHeader); len(values) > 0 && values[0] != "" {
+		return taskmanager.WithClientID(ctx, values[0])
+	}
+	return ctx
+}
+
+// certificateIdentity returns the subject common name of the client
+// certificate verified during the mTLS handshake, or "" without one
+func certificateIdentity(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return ""
+	}
+	info, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok || len(info.State.VerifiedChains) == 0 || len(info.State.VerifiedChains[0]) == 0 {
+		return ""
+	}
+	return info.State.VerifiedChains[0][0].Subject.CommonName
+}
@@ -2,6 +2,7 @@ package grpcserver
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"net"
@@ -9,30 +10,50 @@ import (
 
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 	grpcStatus "google.golang.org/grpc/status"
 
+	"github.com/fluxo/export-middleware/pkg/auth"
 	"github.com/fluxo/export-middleware/pkg/config"
+	"github.com/fluxo/export-middleware/pkg/download"
 	"github.com/fluxo/export-middleware/pkg/logger"
 	"github.com/fluxo/export-middleware/pkg/taskmanager"
+	"github.com/fluxo/export-middleware/pkg/webhook"
 	pb "github.com/fluxo/export-middleware/proto"
 )
 
+// exportServiceName is the name the export service reports under in the
+// gRPC health service, next to the overall "" server status
+const exportServiceName = "export.ExportService"
+
 // Server implements the ExportService gRPC server
 type Server struct {
 	pb.UnimplementedExportServiceServer
-	config      *config.Config
-	logger      *logger.Logger
-	taskManager *taskmanager.Manager
-	grpcServer  *grpc.Server
+	config        *config.Config
+	logger        *logger.Logger
+	taskManager   *taskmanager.Manager
+	authenticator *auth.Authenticator // nil when auth is disabled
+	certs         *certReloader       // nil when TLS is disabled
+	links         *download.Links     // nil when single-use links are disabled
+	health        *health.Server
+	grpcServer    *grpc.Server
 }
 
-// NewServer creates a new gRPC server
-func NewServer(cfg *config.Config, log *logger.Logger, taskMgr *taskmanager.Manager) *Server {
-	return &Server{
+// NewServer creates a new gRPC server. links may be nil.
+func NewServer(cfg *config.Config, log *logger.Logger, taskMgr *taskmanager.Manager, links *download.Links) *Server {
+	s := &Server{
 		config:      cfg,
 		logger:      log,
 		taskManager: taskMgr,
+		links:       links,
+		health:      health.NewServer(),
+	}
+	if cfg.Security.AuthEnabled {
+		s.authenticator = auth.NewAuthenticator(&cfg.Security)
 	}
+	return s
 }
 
 // Start starts the gRPC server
@@ -41,16 +62,47 @@ func (s *Server) Start() error {
 	if err != nil {
 		return fmt.Errorf("failed to listen: %w", err)
 	}
+	if err := s.serve(lis); err != nil {
+		lis.Close()
+		return err
+	}
+	return nil
+}
 
-	s.grpcServer = grpc.NewServer(
+// serve sets up the gRPC server and serves on lis in the background
+func (s *Server) serve(lis net.Listener) error {
+	opts := []grpc.ServerOption{
 		grpc.MaxRecvMsgSize(int(s.config.Performance.BufferSize)),
 		grpc.MaxSendMsgSize(int(s.config.Performance.BufferSize)),
 		grpc.ConnectionTimeout(s.config.Server.Timeout),
-	)
+	}
+	if s.config.Security.TLSEnabled {
+		certs, err := newCertReloader(&s.config.Security.TLS, s.logger)
+		if err != nil {
+			return fmt.Errorf("failed to load TLS certificates: %w", err)
+		}
+		s.certs = certs
+		opts = append(opts, grpc.Creds(credentials.NewTLS(certs.tlsConfig())))
+	}
+	if s.authenticator != nil {
+		opts = append(opts,
+			grpc.ChainUnaryInterceptor(s.unaryAuthInterceptor),
+			grpc.ChainStreamInterceptor(s.streamAuthInterceptor),
+		)
+	}
 
-	pb.RegisterExportServiceServer(s.grpcServer, s)
+	s.grpcServer = grpc.NewServer(opts...)
 
-	s.logger.Info("gRPC server starting", logger.Fields{"port": s.config.Server.Port})
+	pb.RegisterExportServiceServer(s.grpcServer, s)
+	healthpb.RegisterHealthServer(s.grpcServer, s.health)
+	s.health.SetServingStatus(exportServiceName, healthpb.HealthCheckResponse_SERVING)
+
+	s.logger.Info("gRPC server starting", logger.Fields{
+		"port":         s.config.Server.Port,
+		"auth_enabled": s.authenticator != nil,
+		"tls_enabled":  s.certs != nil,
+		"mtls_enabled": s.certs != nil && s.config.Security.TLS.ClientCAFile != "",
+	})
 
 	go func() {
 		if err := s.grpcServer.Serve(lis); err != nil {
@@ -61,180 +113,573 @@ func (s *Server) Start() error {
 	return nil
 }
 
-// Stop gracefully stops the gRPC server
+// Drain reports the server as NOT_SERVING to health checks so load
+// balancers stop routing new exports to it. Streams in flight continue.
+func (s *Server) Drain() {
+	s.logger.Info("Draining gRPC server, health status set to NOT_SERVING")
+	s.health.Shutdown()
+}
+
+// Stop gracefully stops the gRPC server. Streams still open after the
+// server timeout are closed forcefully.
 func (s *Server) Stop() {
 	if s.grpcServer != nil {
 		s.logger.Info("Stopping gRPC server...")
-		s.grpcServer.GracefulStop()
+		stopped := make(chan struct{})
+		go func() {
+			s.grpcServer.GracefulStop()
+			close(stopped)
+		}()
+		select {
+		case <-stopped:
+		case <-time.After(s.config.Server.Timeout):
+			s.logger.Warn("Graceful stop timed out, closing open streams")
+			s.grpcServer.Stop()
+			<-stopped
+		}
 		s.logger.Info("gRPC server stopped")
 	}
+	if s.certs != nil {
+		s.certs.Close()
+	}
+}
+
+// exportSession holds the state of a single export stream. It is shared by
+// StreamExport and StreamExportV2 so both apply the same validation and
+// batch handling.
+type exportSession struct {
+	task           *taskmanager.Task
+	metadata       *pb.ExportMetadata
+	logger         *logger.ContextLogger
+	batchCount     int64
+	duplicateCount int64
+	recordCount    int64
+	startTime      time.Time
+	existing       bool // request was a re-send of an existing task
 }
 
 // StreamExport handles streaming export requests
 func (s *Server) StreamExport(stream pb.ExportService_StreamExportServer) error {
 	ctx := stream.Context()
-	contextLogger := s.logger.WithContext(ctx).WithComponent("grpc_server")
 
 	// Receive first message (metadata)
 	firstMsg, err := stream.Recv()
+	session, err := s.startSession(ctx, firstMsg, err)
 	if err != nil {
-		contextLogger.LogError("StreamReceiveError", "Failed to receive first message", "STREAM_ERROR", err.Error(), nil)
-		return grpcStatus.Error(codes.InvalidArgument, "failed to receive metadata")
+		return err
+	}
+	// Note: In client-streaming RPC, we can't send the task ID immediately.
+	// Clients that need it early should use StreamExportV2.
+
+	if session.existing {
+		response, err := s.existingTaskResponse(session)
+		if err != nil {
+			return err
+		}
+		return stream.SendAndClose(response)
+	}
+
+	if err := s.openSession(ctx, session); err != nil {
+		return err
+	}
+
+	for {
+		msg, err := stream.Recv()
+		if err == io.EOF {
+			// End of stream
+			break
+		}
+		if err != nil {
+			return s.abortSession(session, err)
+		}
+
+		batch := msg.GetBatch()
+		if batch == nil {
+			continue
+		}
+
+		if _, err := s.processBatch(session, batch); err != nil {
+			return err
+		}
+	}
+
+	response, err := s.finishSession(session)
+	if err != nil {
+		return err
+	}
+
+	return stream.SendAndClose(response)
+}
+
+// startSession validates the metadata message and creates the task
+func (s *Server) startSession(ctx context.Context, firstMsg *pb.ExportRequest, recvErr error) (*exportSession, error) {
+	contextLogger := s.logger.WithContext(ctx).WithComponent("grpc_server")
+
+	if recvErr != nil {
+		contextLogger.LogError("StreamReceiveError", "Failed to receive first message", "STREAM_ERROR", recvErr.Error(), nil)
+		return nil, grpcStatus.Error(codes.InvalidArgument, "failed to receive metadata")
 	}
 
 	metadata := firstMsg.GetMetadata()
 	if metadata == nil {
 		contextLogger.LogError("ValidationError", "First message must contain metadata", "INVALID_METADATA", "metadata is nil", nil)
-		return grpcStatus.Error(codes.InvalidArgument, "first message must contain metadata")
+		return nil, grpcStatus.Error(codes.InvalidArgument, "first message must contain metadata")
 	}
 
 	// Validate metadata
 	if err := s.validateMetadata(metadata); err != nil {
 		contextLogger.LogError("ValidationError", "Invalid metadata", "VALIDATION_ERROR", err.Error(), nil)
-		return grpcStatus.Error(codes.InvalidArgument, err.Error())
+		return nil, grpcStatus.Error(codes.InvalidArgument, err.Error())
 	}
 
 	// Create task
-	task, err := s.taskManager.CreateTask(ctx, metadata)
+	task, created, err := s.taskManager.CreateTask(withClientIdentity(ctx), metadata)
 	if err != nil {
+		var quotaErr *taskmanager.QuotaError
+		if errors.As(err, &quotaErr) {
+			contextLogger.LogWarn("QuotaExceeded", "Task rejected by client quota", logger.Fields{"error_code": quotaErr.Code, "error": err.Error()})
+			return nil, grpcStatus.Error(codes.ResourceExhausted, err.Error())
+		}
+		if errors.Is(err, taskmanager.ErrDraining) {
+			contextLogger.LogWarn("ServerDraining", "Task rejected while draining", nil)
+			return nil, grpcStatus.Error(codes.Unavailable, "server is shutting down, retry on another instance")
+		}
 		contextLogger.LogError("TaskCreationError", "Failed to create task", "TASK_ERROR", err.Error(), nil)
-		return grpcStatus.Error(codes.ResourceExhausted, "failed to create task")
+		return nil, grpcStatus.Error(codes.ResourceExhausted, "failed to create task")
 	}
 
 	taskLogger := contextLogger.WithTaskID(task.ID)
-	taskLogger.LogInfo("StreamStarted", "Export stream started", logger.Fields{"format": metadata.Format.String()})
 
-	// Send task ID back to client immediately
-	response := &pb.ExportResponse{
-		TaskId: task.ID,
-		Status: pb.TaskStatus_TASK_STATUS_QUEUED,
+	if !created {
+		taskLogger.LogInfo("StreamDuplicate", "Request already has a task, ignoring stream data", logger.Fields{
+			"request_id": metadata.RequestId,
+		})
+		return &exportSession{
+			task:      task,
+			metadata:  metadata,
+			logger:    taskLogger,
+			startTime: time.Now(),
+			existing:  true,
+		}, nil
+	}
+
+	taskLogger.LogInfo("StreamStarted", "Export stream started", logger.Fields{
+		"format":   metadata.Format.String(),
+		"priority": task.Priority,
+	})
+
+	return &exportSession{
+		task:      task,
+		metadata:  metadata,
+		logger:    taskLogger,
+		startTime: time.Now(),
+	}, nil
+}
+
+// openSession waits until the scheduler has started the task and writes
+// the column headers. A client that goes away while its task is queued
+// cancels the task.
+func (s *Server) openSession(ctx context.Context, session *exportSession) error {
+	task := session.task
+
+	if err := s.taskManager.WaitReady(ctx, task); err != nil {
+		if ctx.Err() != nil {
+			return s.abortSession(session, err)
+		}
+		session.logger.LogError("TaskStartError", "Task ended before it started", "TASK_ERROR", err.Error(), nil)
+		return grpcStatus.Error(codes.Internal, err.Error())
 	}
-	// Note: In streaming RPC, we can't send response immediately
-	// Client needs to track the task ID from initial metadata or wait for completion
 
 	// Write headers
-	if err := task.Writer.WriteHeader(metadata.Columns); err != nil {
-		s.taskManager.GetTask(task.ID) // Get task for cleanup
-		taskLogger.LogError("WriteHeaderError", "Failed to write headers", "WRITER_ERROR", err.Error(), nil)
+	if err := task.Writer.WriteHeader(session.metadata.Columns); err != nil {
+		session.logger.LogError("WriteHeaderError", "Failed to write headers", "WRITER_ERROR", err.Error(), nil)
+		s.taskManager.FailTask(task, "WRITER_ERROR", fmt.Sprintf("Failed to write headers: %v", err))
 		return grpcStatus.Error(codes.Internal, "failed to write headers")
 	}
 
-	// Process data batches
-	batchCount := int64(0)
-	recordCount := int64(0)
-	startTime := time.Now()
+	// Measure stream throughput from the moment data can be written
+	session.startTime = time.Now()
+	return nil
+}
 
-	for {
-		msg, err := stream.Recv()
-		if err == io.EOF {
-			// End of stream
-			break
-		}
-		if err != nil {
-			taskLogger.LogError("StreamError", "Stream receive error", "STREAM_ERROR", err.Error(), nil)
-			if task.Writer != nil {
-				task.Writer.Cleanup()
-			}
-			return grpcStatus.Error(codes.Internal, "stream error")
-		}
+// processBatch validates the batch sequence and writes the records. It
+// returns true if the batch was a re-send that has been dropped.
+func (s *Server) processBatch(session *exportSession, batch *pb.DataBatch) (bool, error) {
+	task := session.task
 
-		batch := msg.GetBatch()
-		if batch == nil {
-			continue
-		}
-
-		// Write records
-		batchStartTime := time.Now()
-		if err := task.Writer.WriteRecords(batch.Records); err != nil {
-			taskLogger.LogError("WriteError", "Failed to write records", "WRITER_ERROR", err.Error(), logger.Fields{
-				"batch_sequence": batch.BatchSequence,
-			})
-			if task.Writer != nil {
-				task.Writer.Cleanup()
-			}
-			return grpcStatus.Error(codes.Internal, "failed to write records")
-		}
+	// A task interrupted by shutdown no longer accepts data
+	if task.Finished() {
+		return false, grpcStatus.Error(codes.Aborted, "task is no longer running")
+	}
 
-		batchCount++
-		recordCount += int64(len(batch.Records))
-		batchDuration := time.Since(batchStartTime)
+	// Validate batch ordering before touching the file
+	duplicate, err := s.taskManager.CheckBatchSequence(task, batch)
+	if err != nil {
+		var seqErr *taskmanager.SequenceError
+		errors.As(err, &seqErr)
+		session.logger.LogError("BatchSequenceError", "Invalid batch sequence", seqErr.Code, err.Error(), logger.Fields{
+			"batch_sequence":    batch.BatchSequence,
+			"expected_sequence": seqErr.Expected,
+		})
+		s.taskManager.FailTask(task, seqErr.Code, err.Error())
+		return false, grpcStatus.Error(codes.InvalidArgument, err.Error())
+	}
+	if duplicate {
+		session.duplicateCount++
+		session.logger.LogWarn("BatchDuplicateDropped", "Dropped re-sent batch", logger.Fields{
+			"batch_sequence": batch.BatchSequence,
+			"records":        len(batch.Records),
+		})
+		return true, nil
+	}
 
-		// Update progress
-		if metadata.Format == pb.ExportFormat_FORMAT_CSV {
-			// For CSV, we can estimate progress
-			progress := float32(recordCount) / float32(recordCount+1000) * 100 // Rough estimate
-			if progress > 100 {
-				progress = 99 // Cap at 99 until finalization
-			}
-			s.taskManager.UpdateTaskProgress(task.ID, recordCount, progress)
-		}
+	// Enforce client quotas before growing the file
+	if err := s.taskManager.CheckBatchQuota(task, batch); err != nil {
+		var quotaErr *taskmanager.QuotaError
+		errors.As(err, &quotaErr)
+		session.logger.LogError("QuotaExceeded", "Batch rejected by client quota", quotaErr.Code, err.Error(), logger.Fields{
+			"batch_sequence": batch.BatchSequence,
+		})
+		s.taskManager.FailTask(task, quotaErr.Code, err.Error())
+		return false, grpcStatus.Error(codes.ResourceExhausted, err.Error())
+	}
 
-		taskLogger.LogBatchProcessed(
-			fmt.Sprintf("Batch %d processed", batch.BatchSequence),
-			batchDuration.Milliseconds(),
-			logger.Fields{
-				"batch_sequence": batch.BatchSequence,
-				"records":        len(batch.Records),
-				"total_records":  recordCount,
-			},
-		)
+	// Write records
+	batchStartTime := time.Now()
+	if err := task.Writer.WriteRecords(batch.Records); err != nil {
+		session.logger.LogError("WriteError", "Failed to write records", "WRITER_ERROR", err.Error(), logger.Fields{
+			"batch_sequence": batch.BatchSequence,
+		})
+		s.taskManager.FailTask(task, "WRITER_ERROR", fmt.Sprintf("Failed to write records: %v", err))
+		return false, grpcStatus.Error(codes.Internal, "failed to write records")
 	}
+	s.taskManager.CommitBatch(task, batch)
+
+	session.batchCount++
+	session.recordCount += int64(len(batch.Records))
+	batchDuration := time.Since(batchStartTime)
+
+	// Update progress
+	s.taskManager.UpdateTaskProgress(task.ID, session.recordCount, session.batchCount)
+
+	session.logger.LogBatchProcessed(
+		fmt.Sprintf("Batch %d processed", batch.BatchSequence),
+		batchDuration.Milliseconds(),
+		logger.Fields{
+			"batch_sequence": batch.BatchSequence,
+			"records":        len(batch.Records),
+			"total_records":  session.recordCount,
+		},
+	)
+
+	return false, nil
+}
+
+// abortSession handles a broken stream
+func (s *Server) abortSession(session *exportSession, err error) error {
+	session.logger.LogError("StreamError", "Stream receive error", "STREAM_ERROR", err.Error(), nil)
+	s.taskManager.CancelTask(session.task, fmt.Sprintf("client stream aborted: %v", err))
+	return grpcStatus.Error(codes.Internal, "stream error")
+}
+
+// finishSession finalizes and uploads the file once all batches are received
+func (s *Server) finishSession(session *exportSession) (*pb.ExportResponse, error) {
+	task := session.task
 
-	taskLogger.LogInfo("StreamCompleted", "All batches received", logger.Fields{
-		"batch_count":  batchCount,
-		"record_count": recordCount,
-		"duration_ms":  time.Since(startTime).Milliseconds(),
+	session.logger.LogInfo("StreamCompleted", "All batches received", logger.Fields{
+		"batch_count":     session.batchCount,
+		"duplicate_count": session.duplicateCount,
+		"record_count":    session.recordCount,
+		"duration_ms":     time.Since(session.startTime).Milliseconds(),
 	})
 
+	// Let the client go and finish the export in the background
+	if session.metadata.AsyncFinalize {
+		s.taskManager.FinalizeTaskAsync(task)
+		session.logger.LogInfo("FinalizeDeferred", "Finalizing export in the background", logger.Fields{
+			"callback_url": session.metadata.CallbackUrl != "",
+		})
+		return s.existingTaskResponse(session)
+	}
+
 	// Finalize task
 	if err := s.taskManager.FinalizeTask(task); err != nil {
-		taskLogger.LogError("FinalizeError", "Failed to finalize task", "FINALIZE_ERROR", err.Error(), nil)
-		return grpcStatus.Error(codes.Internal, "failed to finalize export")
+		session.logger.LogError("FinalizeError", "Failed to finalize task", "FINALIZE_ERROR", err.Error(), nil)
+		return nil, grpcStatus.Error(codes.Internal, "failed to finalize export")
 	}
 
 	// Get final task status
 	finalStatus, err := s.taskManager.GetTaskStatus(task.ID)
 	if err != nil {
-		return grpcStatus.Error(codes.Internal, "failed to get task status")
+		return nil, grpcStatus.Error(codes.Internal, "failed to get task status")
 	}
 
-	// Send final response
-	response = &pb.ExportResponse{
-		TaskId:          finalStatus.TaskId,
-		Status:          finalStatus.Status,
-		OssUrl:          finalStatus.OssUrl,
-		FileSizeBytes:   finalStatus.FileSizeBytes,
-		RecordCount:     finalStatus.RecordsProcessed,
-		ProgressPercent: 100,
-		StartTime:       finalStatus.StartTime,
-		CompletionTime:  finalStatus.CompletionTime,
-	}
+	// The session's client created the task
+	s.attachDataKey(finalStatus, task.ClientID)
+	response := exportResponseFromStatus(finalStatus)
+	response.ProgressPercent = 100
 
-	taskLogger.LogInfo("ExportCompleted", "Export completed successfully", logger.Fields{
+	session.logger.LogInfo("ExportCompleted", "Export completed successfully", logger.Fields{
 		"oss_url":    response.OssUrl,
 		"file_size":  response.FileSizeBytes,
 		"records":    response.RecordCount,
-		"duration_s": time.Since(startTime).Seconds(),
+		"duration_s": time.Since(session.startTime).Seconds(),
 	})
 
-	return stream.SendAndClose(response)
+	return response, nil
 }
 
-// QueryTaskStatus handles task status queries
+// existingTaskResponse builds the response for a re-sent request from the
+// current state of the task it already created
+func (s *Server) existingTaskResponse(session *exportSession) (*pb.ExportResponse, error) {
+	status, err := s.taskManager.GetTaskStatus(session.task.ID)
+	if err != nil {
+		return nil, grpcStatus.Error(codes.Internal, "failed to get task status")
+	}
+	s.attachDataKey(status, session.task.ClientID)
+	return exportResponseFromStatus(status), nil
+}
+
+// attachDataKey adds the client-side encryption key of a task to its
+// status if clientID created the task
+func (s *Server) attachDataKey(status *pb.TaskStatusResponse, clientID string) {
+	if status.Encryption == nil {
+		return
+	}
+	status.Encryption.DataKey = s.taskManager.DataKey(status.TaskId, clientID)
+}
+
+// exportResponseFromStatus converts a task status into an export response
+func exportResponseFromStatus(status *pb.TaskStatusResponse) *pb.ExportResponse {
+	return &pb.ExportResponse{
+		TaskId:          status.TaskId,
+		Status:          status.Status,
+		OssUrl:          status.OssUrl,
+		FileSizeBytes:   status.FileSizeBytes,
+		RecordCount:     status.RecordsProcessed,
+		ProgressPercent: status.ProgressPercent,
+		ChecksumSha256:  status.ChecksumSha256,
+		ErrorMessage:    status.ErrorMessage,
+		ErrorCode:       status.ErrorCode,
+		StartTime:       status.StartTime,
+		CompletionTime:  status.CompletionTime,
+		Encryption:      status.Encryption,
+	}
+}
+
+// QueryTaskStatus handles task status queries. The task is looked up by
+// task_id, or by request_id for the calling client when task_id is empty.
 func (s *Server) QueryTaskStatus(ctx context.Context, req *pb.TaskStatusRequest) (*pb.TaskStatusResponse, error) {
-	contextLogger := s.logger.WithContext(ctx).WithComponent("grpc_server").WithTaskID(req.TaskId)
+	ctx = withClientIdentity(ctx)
+
+	taskID := req.TaskId
+	if taskID == "" && req.RequestId != "" {
+		task, err := s.taskManager.FindTaskByRequestID(taskmanager.ClientIDFromContext(ctx), req.RequestId)
+		if err != nil {
+			s.logger.WithContext(ctx).WithComponent("grpc_server").LogWarn("StatusNotFound", "Task not found", logger.Fields{
+				"request_id": req.RequestId,
+				"error":      err.Error(),
+			})
+			return nil, grpcStatus.Error(codes.NotFound, "task not found")
+		}
+		taskID = task.ID
+	}
+
+	contextLogger := s.logger.WithContext(ctx).WithComponent("grpc_server").WithTaskID(taskID)
 
 	contextLogger.LogInfo("StatusQueried", "Task status query received", nil)
 
-	status, err := s.taskManager.GetTaskStatus(req.TaskId)
+	status, err := s.taskManager.GetTaskStatus(taskID)
 	if err != nil {
 		contextLogger.LogWarn("StatusNotFound", "Task not found", logger.Fields{"error": err.Error()})
 		return nil, grpcStatus.Error(codes.NotFound, "task not found")
 	}
 
+	if err := s.authorizeTask(ctx, status); err != nil {
+		return nil, err
+	}
+	s.attachDataKey(status, taskmanager.ClientIDFromContext(ctx))
+
 	return status, nil
 }
 
+// WatchTaskStatus streams status updates of a task until it reaches a
+// terminal state or the client goes away
+func (s *Server) WatchTaskStatus(req *pb.TaskStatusRequest, stream pb.ExportService_WatchTaskStatusServer) error {
+	ctx := stream.Context()
+	contextLogger := s.logger.WithContext(ctx).WithComponent("grpc_server").WithTaskID(req.TaskId)
+
+	current, err := s.taskManager.GetTaskStatus(req.TaskId)
+	if err != nil {
+		contextLogger.LogWarn("StatusNotFound", "Task not found", logger.Fields{"error": err.Error()})
+		return grpcStatus.Error(codes.NotFound, "task not found")
+	}
+	if err := s.authorizeTask(ctx, current); err != nil {
+		return err
+	}
+
+	updates, cancel, err := s.taskManager.WatchTask(req.TaskId)
+	if err != nil {
+		contextLogger.LogWarn("StatusNotFound", "Task not found", logger.Fields{"error": err.Error()})
+		return grpcStatus.Error(codes.NotFound, "task not found")
+	}
+	defer cancel()
+
+	contextLogger.LogInfo("StatusWatchStarted", "Task status watch started", nil)
+
+	for {
+		select {
+		case <-ctx.Done():
+			contextLogger.LogDebug("StatusWatchCancelled", "Client stopped watching task status", nil)
+			return nil
+		case status, ok := <-updates:
+			if !ok {
+				contextLogger.LogInfo("StatusWatchCompleted", "Task reached terminal state", nil)
+				return nil
+			}
+			if err := stream.Send(status); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// ListTasks handles task listing requests
+func (s *Server) ListTasks(ctx context.Context, req *pb.ListTasksRequest) (*pb.ListTasksResponse, error) {
+	contextLogger := s.logger.WithContext(ctx).WithComponent("grpc_server")
+
+	clientID, err := s.scopeClient(ctx, req.ClientId)
+	if err != nil {
+		return nil, err
+	}
+	req.ClientId = clientID
+
+	resp, err := s.taskManager.ListTasks(req)
+	if err != nil {
+		contextLogger.LogWarn("ListTasksInvalid", "Invalid task list request", logger.Fields{"error": err.Error()})
+		return nil, grpcStatus.Error(codes.InvalidArgument, err.Error())
+	}
+
+	contextLogger.LogDebug("TasksListed", "Task list returned", logger.Fields{
+		"returned": len(resp.Tasks),
+		"total":    resp.TotalCount,
+	})
+
+	return resp, nil
+}
+
+// GetQuotaUsage reports the quota limits and current usage of a client
+func (s *Server) GetQuotaUsage(ctx context.Context, req *pb.QuotaUsageRequest) (*pb.QuotaUsageResponse, error) {
+	ctx = withClientIdentity(ctx)
+
+	clientID, err := s.scopeClient(ctx, req.ClientId)
+	if err != nil {
+		return nil, err
+	}
+	if clientID == "" {
+		clientID = taskmanager.ClientIDFromContext(ctx)
+	}
+
+	limits, usage := s.taskManager.GetQuotaUsage(clientID)
+
+	return &pb.QuotaUsageResponse{
+		ClientId:           clientID,
+		Enabled:            s.taskManager.Config().Quotas.Enabled,
+		MaxConcurrentTasks: int32(limits.MaxConcurrentTasks),
+		MaxTasksPerHour:    int32(limits.MaxTasksPerHour),
+		MaxRecordsPerTask:  limits.MaxRecordsPerTask,
+		MaxBytesPerDay:     limits.MaxBytesPerDay,
+		ConcurrentTasks:    int32(usage.ConcurrentTasks),
+		TasksLastHour:      int32(usage.TasksLastHour),
+		BytesLastDay:       usage.BytesLastDay,
+	}, nil
+}
+
+// GetDownloadURL signs a new download URL for a completed task, or issues
+// a single-use link to it
+func (s *Server) GetDownloadURL(ctx context.Context, req *pb.DownloadURLRequest) (*pb.DownloadURLResponse, error) {
+	ctx = withClientIdentity(ctx)
+	contextLogger := s.logger.WithContext(ctx).WithComponent("grpc_server").WithTaskID(req.TaskId)
+
+	if !taskmanager.ValidTaskID(req.TaskId) {
+		return nil, grpcStatus.Error(codes.InvalidArgument, taskmanager.ErrInvalidTaskID.Error())
+	}
+	status, err := s.taskManager.GetTaskStatus(req.TaskId)
+	if err != nil {
+		contextLogger.LogWarn("StatusNotFound", "Task not found", logger.Fields{"error": err.Error()})
+		return nil, grpcStatus.Error(codes.NotFound, "task not found")
+	}
+	if err := s.authorizeTask(ctx, status); err != nil {
+		return nil, err
+	}
+	if req.ExpirySeconds < 0 {
+		return nil, grpcStatus.Error(codes.InvalidArgument, "expiry_seconds cannot be negative")
+	}
+	if req.SingleUse && s.links == nil {
+		return nil, grpcStatus.Error(codes.FailedPrecondition, "single-use links are not enabled")
+	}
+
+	expiry := s.taskManager.DownloadExpiry(time.Duration(req.ExpirySeconds) * time.Second)
+	response := &pb.DownloadURLResponse{SingleUse: req.SingleUse}
+	if req.SingleUse {
+		_, err = s.taskManager.ObjectKey(req.TaskId)
+		if err == nil {
+			var expiresAt time.Time
+			response.Url, expiresAt, err = s.links.Issue(req.TaskId, expiry)
+			response.ExpiresAt = expiresAt.Unix()
+		}
+	} else {
+		response.ExpiresAt = time.Now().Add(expiry).Unix()
+		response.Url, err = s.taskManager.SignDownloadURL(req.TaskId, expiry)
+	}
+	if errors.Is(err, taskmanager.ErrNotDownloadable) {
+		return nil, grpcStatus.Error(codes.FailedPrecondition, "task has not completed an upload")
+	}
+	if err != nil {
+		contextLogger.LogError("DownloadURLError", "Failed to create download URL", "OSS_ERROR", err.Error(), nil)
+		return nil, grpcStatus.Error(codes.Internal, "failed to create download URL")
+	}
+
+	contextLogger.LogInfo("DownloadURLIssued", "Download URL issued", logger.Fields{
+		"expiry_s":   int64(expiry.Seconds()),
+		"single_use": req.SingleUse,
+	})
+	return response, nil
+}
+
+// DeleteExport erases the file of a finished task. Tasks from before a
+// restart can still be deleted as long as their object was recorded.
+func (s *Server) DeleteExport(ctx context.Context, req *pb.DeleteExportRequest) (*pb.DeleteExportResponse, error) {
+	ctx = withClientIdentity(ctx)
+	contextLogger := s.logger.WithContext(ctx).WithComponent("grpc_server").WithTaskID(req.TaskId)
+
+	if !taskmanager.ValidTaskID(req.TaskId) {
+		return nil, grpcStatus.Error(codes.InvalidArgument, taskmanager.ErrInvalidTaskID.Error())
+	}
+	owner, err := s.taskManager.ExportOwner(req.TaskId)
+	if err != nil {
+		contextLogger.LogWarn("StatusNotFound", "Task not found", logger.Fields{"error": err.Error()})
+		return nil, grpcStatus.Error(codes.NotFound, "task not found")
+	}
+	if err := s.authorizeTask(ctx, &pb.TaskStatusResponse{TaskId: req.TaskId, ClientId: owner}); err != nil {
+		return nil, err
+	}
+
+	if err := s.taskManager.DeleteExport(req.TaskId); err != nil {
+		if errors.Is(err, taskmanager.ErrTaskRunning) {
+			return nil, grpcStatus.Error(codes.FailedPrecondition, err.Error())
+		}
+		contextLogger.LogError("DeleteExportError", "Failed to delete export", "OSS_ERROR", err.Error(), nil)
+		return nil, grpcStatus.Error(codes.Internal, "failed to delete export")
+	}
+
+	response := &pb.DeleteExportResponse{TaskId: req.TaskId}
+	if status, err := s.taskManager.GetTaskStatus(req.TaskId); err == nil {
+		response.Status = status.Status
+	}
+	return response, nil
+}
+
 // validateMetadata validates export metadata
 func (s *Server) validateMetadata(metadata *pb.ExportMetadata) error {
 	if metadata.RequestId == "" {
@@ -249,6 +694,23 @@ func (s *Server) validateMetadata(metadata *pb.ExportMetadata) error {
 	if len(metadata.Columns) == 0 {
 		return fmt.Errorf("at least one column is required")
 	}
+	if metadata.TotalRecords < 0 {
+		return fmt.Errorf("total_records cannot be negative")
+	}
+	if metadata.TotalBatches < 0 {
+		return fmt.Errorf("total_batches cannot be negative")
+	}
+	if metadata.RetentionSeconds < 0 {
+		return fmt.Errorf("retention_seconds cannot be negative")
+	}
+	if metadata.CallbackUrl != "" {
+		if !s.config.Webhook.Enabled {
+			return fmt.Errorf("callback_url is not accepted, webhooks are disabled")
+		}
+		if err := webhook.ValidateURL(metadata.CallbackUrl, &s.config.Webhook); err != nil {
+			return err
+		}
+	}
 
 	// Validate columns
 	for i, col := range metadata.Columns {
@@ -0,0 +1,291 @@
+package grpcserver
+
+import (
+	"context"
+	"hash/crc64"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	grpcStatus "google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	"github.com/fluxo/export-middleware/pkg/config"
+	"github.com/fluxo/export-middleware/pkg/download"
+	"github.com/fluxo/export-middleware/pkg/logger"
+	"github.com/fluxo/export-middleware/pkg/oss"
+	"github.com/fluxo/export-middleware/pkg/storage"
+	"github.com/fluxo/export-middleware/pkg/taskmanager"
+	pb "github.com/fluxo/export-middleware/proto"
+)
+
+// fakeBucket is an OSS bucket in memory, served path-style on a local
+// address. It supports simple uploads, object metadata and deletion.
+type fakeBucket struct {
+	mu      sync.Mutex
+	objects map[string][]byte // path -> content
+}
+
+func (b *fakeBucket) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch r.Method {
+	case http.MethodPut:
+		data, _ := io.ReadAll(r.Body)
+		b.objects[r.URL.Path] = data
+		w.Header().Set("ETag", `"etag"`)
+		w.Header().Set("X-Oss-Hash-Crc64ecma", strconv.FormatUint(crc64.Checksum(data, crc64.MakeTable(crc64.ECMA)), 10))
+	case http.MethodHead:
+		data, ok := b.objects[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+		w.Header().Set("X-Oss-Hash-Crc64ecma", strconv.FormatUint(crc64.Checksum(data, crc64.MakeTable(crc64.ECMA)), 10))
+	case http.MethodDelete:
+		delete(b.objects, r.URL.Path)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// count returns the number of stored objects
+func (b *fakeBucket) count() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.objects)
+}
+
+// testService is a server wired like cmd/server, with API key
+// authentication, OSS replaced by a fakeBucket, and a client connected
+// over an in-memory listener
+type testService struct {
+	server *Server
+	bucket *fakeBucket
+	conn   *grpc.ClientConn
+	client pb.ExportServiceClient
+}
+
+func newTestService(t *testing.T) *testService {
+	t.Helper()
+
+	log, err := logger.New("error", "json", "stderr", false)
+	if err != nil {
+		t.Fatalf("Failed to create logger: %v", err)
+	}
+
+	bucket := &fakeBucket{objects: make(map[string][]byte)}
+	ossServer := httptest.NewServer(bucket)
+	t.Cleanup(ossServer.Close)
+
+	cfg := config.DefaultConfig()
+	cfg.Storage.TempDirectory = t.TempDir()
+	cfg.OSS.Endpoint, cfg.OSS.Bucket = ossServer.URL, "exports"
+	cfg.OSS.AccessKeyID, cfg.OSS.AccessKeySecret = "key-id", "key-secret"
+	cfg.OSS.StaleUploadAge = 0
+	cfg.Downloads.LinkBaseURL, cfg.Downloads.LinkSecret = "https://exports.example.com", "link-secret"
+	cfg.Security.AuthEnabled = true
+	cfg.Security.APIKeys = map[string]string{"key-a": "client-a", "key-b": "client-b"}
+
+	storageMgr, err := storage.NewManager(cfg.Storage.TempDirectory, false, cfg.Storage.TempRetention, log)
+	if err != nil {
+		t.Fatalf("Failed to create storage manager: %v", err)
+	}
+	uploader, err := oss.NewUploader(&cfg.OSS, log)
+	if err != nil {
+		t.Fatalf("Failed to create uploader: %v", err)
+	}
+	taskMgr := taskmanager.NewManager(cfg, log, storageMgr, uploader, nil, nil)
+	links := download.NewLinks(&cfg.Downloads, cfg.Storage.TempDirectory, taskMgr, uploader, log)
+
+	s := NewServer(cfg, log, taskMgr, links)
+	lis := bufconn.Listen(1 << 20)
+	if err := s.serve(lis); err != nil {
+		t.Fatalf("Failed to start server: %v", err)
+	}
+
+	conn, err := grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+
+	t.Cleanup(func() {
+		conn.Close()
+		s.Stop()
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		taskMgr.Shutdown(ctx)
+		uploader.Close()
+	})
+
+	return &testService{server: s, bucket: bucket, conn: conn, client: pb.NewExportServiceClient(conn)}
+}
+
+// as returns a context authenticated with an API key
+func as(apiKey string) context.Context {
+	return metadata.AppendToOutgoingContext(context.Background(), apiKeyHeader, apiKey)
+}
+
+// export runs a small CSV export for the client of apiKey and returns its
+// task ID
+func (ts *testService) export(t *testing.T, apiKey string) string {
+	t.Helper()
+
+	stream, err := ts.client.StreamExport(as(apiKey))
+	if err != nil {
+		t.Fatalf("Failed to open export stream: %v", err)
+	}
+	messages := []*pb.ExportRequest{
+		{Payload: &pb.ExportRequest_Metadata{Metadata: &pb.ExportMetadata{
+			RequestId: "request-1",
+			Format:    pb.ExportFormat_FORMAT_CSV,
+			Filename:  "report.csv",
+			Columns: []*pb.ColumnDefinition{
+				{Name: "id", DataType: pb.DataType_DATA_TYPE_NUMBER},
+				{Name: "name", DataType: pb.DataType_DATA_TYPE_STRING},
+			},
+		}}},
+		{Payload: &pb.ExportRequest_Batch{Batch: &pb.DataBatch{
+			BatchSequence: 1,
+			Records:       []*pb.Record{{Values: []string{"1", "Alice"}}, {Values: []string{"2", "Bob"}}},
+		}}},
+	}
+	for _, msg := range messages {
+		if err := stream.Send(msg); err != nil {
+			t.Fatalf("Failed to send: %v", err)
+		}
+	}
+	response, err := stream.CloseAndRecv()
+	if err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+	if response.Status != pb.TaskStatus_TASK_STATUS_COMPLETED {
+		t.Fatalf("Expected completed export, got %v (%s)", response.Status, response.ErrorMessage)
+	}
+	return response.TaskId
+}
+
+func TestServer_Auth(t *testing.T) {
+	ts := newTestService(t)
+	req := &pb.TaskStatusRequest{TaskId: "0b9e8d7c-6f5a-4e3d-8c2b-1a0f9e8d7c6b"}
+
+	if _, err := ts.client.QueryTaskStatus(context.Background(), req); grpcStatus.Code(err) != codes.Unauthenticated {
+		t.Errorf("Expected Unauthenticated without credentials, got %v", err)
+	}
+	if _, err := ts.client.QueryTaskStatus(as("key-c"), req); grpcStatus.Code(err) != codes.Unauthenticated {
+		t.Errorf("Expected Unauthenticated with an unknown key, got %v", err)
+	}
+	if _, err := ts.client.QueryTaskStatus(as("key-a"), req); grpcStatus.Code(err) != codes.NotFound {
+		t.Errorf("Expected an authenticated call to reach the handler, got %v", err)
+	}
+}
+
+func TestServer_Health(t *testing.T) {
+	ts := newTestService(t)
+	health := healthpb.NewHealthClient(ts.conn)
+
+	// Health checks need no credentials
+	for _, service := range []string{"", exportServiceName} {
+		resp, err := health.Check(context.Background(), &healthpb.HealthCheckRequest{Service: service})
+		if err != nil || resp.Status != healthpb.HealthCheckResponse_SERVING {
+			t.Errorf("Service %q: expected SERVING, got %v (%v)", service, resp.GetStatus(), err)
+		}
+	}
+
+	ts.server.Drain()
+	resp, err := health.Check(context.Background(), &healthpb.HealthCheckRequest{Service: exportServiceName})
+	if err != nil || resp.Status != healthpb.HealthCheckResponse_NOT_SERVING {
+		t.Errorf("Expected NOT_SERVING after drain, got %v (%v)", resp.GetStatus(), err)
+	}
+}
+
+func TestServer_GetDownloadURL(t *testing.T) {
+	ts := newTestService(t)
+	taskID := ts.export(t, "key-a")
+
+	resp, err := ts.client.GetDownloadURL(as("key-a"), &pb.DownloadURLRequest{TaskId: taskID, ExpirySeconds: 60})
+	if err != nil {
+		t.Fatalf("Failed to get download URL: %v", err)
+	}
+	if !strings.Contains(resp.Url, "/exports/exports%2F") || !strings.Contains(resp.Url, taskID) {
+		t.Errorf("Expected a signed URL of the object, got %s", resp.Url)
+	}
+	if wait := time.Until(time.Unix(resp.ExpiresAt, 0)); wait <= 0 || wait > time.Minute {
+		t.Errorf("Expected the URL to expire within a minute, got %v", wait)
+	}
+
+	resp, err = ts.client.GetDownloadURL(as("key-a"), &pb.DownloadURLRequest{TaskId: taskID, SingleUse: true})
+	if err != nil {
+		t.Fatalf("Failed to get single-use link: %v", err)
+	}
+	if !resp.SingleUse || !strings.HasPrefix(resp.Url, "https://exports.example.com/download/") {
+		t.Errorf("Expected a single-use link, got %s", resp.Url)
+	}
+
+	for _, tt := range []struct {
+		name   string
+		apiKey string
+		taskID string
+		want   codes.Code
+	}{
+		{"other client", "key-b", taskID, codes.PermissionDenied},
+		{"invalid task ID", "key-a", "../task", codes.InvalidArgument},
+		{"unknown task", "key-a", "0b9e8d7c-6f5a-4e3d-8c2b-1a0f9e8d7c6b", codes.NotFound},
+	} {
+		_, err := ts.client.GetDownloadURL(as(tt.apiKey), &pb.DownloadURLRequest{TaskId: tt.taskID})
+		if code := grpcStatus.Code(err); code != tt.want {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.want, err)
+		}
+	}
+}
+
+func TestServer_DeleteExport(t *testing.T) {
+	ts := newTestService(t)
+	taskID := ts.export(t, "key-a")
+	if ts.bucket.count() != 1 {
+		t.Fatalf("Expected the export in the bucket, found %d objects", ts.bucket.count())
+	}
+
+	_, err := ts.client.DeleteExport(as("key-b"), &pb.DeleteExportRequest{TaskId: taskID})
+	if grpcStatus.Code(err) != codes.PermissionDenied {
+		t.Errorf("Expected PermissionDenied for another client, got %v", err)
+	}
+	_, err = ts.client.DeleteExport(as("key-a"), &pb.DeleteExportRequest{TaskId: "0b9e8d7c-6f5a-4e3d-8c2b-1a0f9e8d7c6b"})
+	if grpcStatus.Code(err) != codes.NotFound {
+		t.Errorf("Expected NotFound for an unknown task, got %v", err)
+	}
+
+	resp, err := ts.client.DeleteExport(as("key-a"), &pb.DeleteExportRequest{TaskId: taskID})
+	if err != nil {
+		t.Fatalf("Failed to delete export: %v", err)
+	}
+	if resp.Status != pb.TaskStatus_TASK_STATUS_DELETED {
+		t.Errorf("Expected DELETED, got %v", resp.Status)
+	}
+	if ts.bucket.count() != 0 {
+		t.Errorf("Expected the object to be deleted, found %d objects", ts.bucket.count())
+	}
+
+	// A deleted export can no longer be downloaded
+	_, err = ts.client.GetDownloadURL(as("key-a"), &pb.DownloadURLRequest{TaskId: taskID})
+	if grpcStatus.Code(err) != codes.FailedPrecondition {
+		t.Errorf("Expected FailedPrecondition after deletion, got %v", err)
+	}
+}
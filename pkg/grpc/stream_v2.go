@@ -0,0 +1,160 @@
+package grpcserver
+
+import (
+	"io"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	grpcStatus "google.golang.org/grpc/status"
+
+	"github.com/fluxo/export-middleware/pkg/logger"
+	pb "github.com/fluxo/export-middleware/proto"
+)
+
+// StreamExportV2 handles bidirectional export streams. Unlike StreamExport it
+// sends the task ID right after the task is created, acknowledges every
+// batch, pushes progress while data and the upload are in flight and sends
+// the final ExportResponse as the last event.
+func (s *Server) StreamExportV2(stream pb.ExportService_StreamExportV2Server) error {
+	ctx := stream.Context()
+
+	// Receive first message (metadata)
+	firstMsg, err := stream.Recv()
+	session, err := s.startSession(ctx, firstMsg, err)
+	if err != nil {
+		return err
+	}
+
+	accepted := &pb.TaskAccepted{
+		TaskId: session.task.ID,
+		Status: pb.TaskStatus_TASK_STATUS_QUEUED,
+	}
+	if status, err := s.taskManager.GetTaskStatus(session.task.ID); err == nil {
+		accepted.Status = status.Status
+	}
+	if err := stream.Send(&pb.ExportEvent{Event: &pb.ExportEvent_Accepted{Accepted: accepted}}); err != nil {
+		if session.existing {
+			return err
+		}
+		return s.abortSession(session, err)
+	}
+
+	if session.existing {
+		response, err := s.existingTaskResponse(session)
+		if err != nil {
+			return err
+		}
+		return stream.Send(&pb.ExportEvent{Event: &pb.ExportEvent_Result{Result: response}})
+	}
+
+	if err := s.openSession(ctx, session); err != nil {
+		return err
+	}
+
+	// Receive in a separate goroutine so progress can be pushed while the
+	// client is idle. The stream context is cancelled when this handler
+	// returns, which releases the goroutine.
+	msgs := make(chan *pb.ExportRequest)
+	recvErrs := make(chan error, 1)
+	go func() {
+		for {
+			msg, err := stream.Recv()
+			if err != nil {
+				recvErrs <- err
+				return
+			}
+			select {
+			case msgs <- msg:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	ticker := time.NewTicker(s.config.Server.ProgressInterval)
+	defer ticker.Stop()
+
+receive:
+	for {
+		select {
+		case msg := <-msgs:
+			batch := msg.GetBatch()
+			if batch == nil {
+				continue
+			}
+
+			duplicate, err := s.processBatch(session, batch)
+			if err != nil {
+				return err
+			}
+
+			ack := &pb.BatchAck{
+				BatchSequence:  batch.BatchSequence,
+				RecordsWritten: session.recordCount,
+				Duplicate:      duplicate,
+			}
+			if err := stream.Send(&pb.ExportEvent{Event: &pb.ExportEvent_Ack{Ack: ack}}); err != nil {
+				return s.abortSession(session, err)
+			}
+
+		case err := <-recvErrs:
+			if err == io.EOF {
+				// End of stream
+				break receive
+			}
+			return s.abortSession(session, err)
+
+		case <-ticker.C:
+			if err := s.sendProgress(stream, session); err != nil {
+				return s.abortSession(session, err)
+			}
+		}
+	}
+
+	// Keep pushing progress while the file is finalized and uploaded
+	var response *pb.ExportResponse
+	var finishErr error
+	done := make(chan struct{})
+	go func() {
+		response, finishErr = s.finishSession(session)
+		close(done)
+	}()
+
+	for {
+		select {
+		case <-done:
+			if finishErr != nil {
+				return finishErr
+			}
+			return stream.Send(&pb.ExportEvent{Event: &pb.ExportEvent_Result{Result: response}})
+
+		case <-ticker.C:
+			if err := s.sendProgress(stream, session); err != nil {
+				// The export continues; only the client stopped listening
+				session.logger.LogWarn("ProgressSendError", "Failed to send progress", logger.Fields{"error": err.Error()})
+				<-done
+				return grpcStatus.Error(codes.Unavailable, "stream closed during finalization")
+			}
+		}
+	}
+}
+
+// sendProgress pushes the current progress of a session to the client
+func (s *Server) sendProgress(stream pb.ExportService_StreamExportV2Server, session *exportSession) error {
+	progress := &pb.ExportProgress{
+		TaskId:         session.task.ID,
+		RecordsWritten: session.recordCount,
+		BytesWritten:   session.task.BytesWritten(),
+	}
+
+	if elapsed := time.Since(session.startTime).Seconds(); elapsed > 0 {
+		progress.RecordsPerSecond = float64(session.recordCount) / elapsed
+	}
+
+	if status, err := s.taskManager.GetTaskStatus(session.task.ID); err == nil {
+		progress.Status = status.Status
+		progress.ProgressPercent = status.ProgressPercent
+	}
+
+	return stream.Send(&pb.ExportEvent{Event: &pb.ExportEvent_Progress{Progress: progress}})
+}
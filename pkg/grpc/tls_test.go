@@ -0,0 +1,103 @@
+package grpcserver
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/fluxo/export-middleware/pkg/config"
+	"github.com/fluxo/export-middleware/pkg/logger"
+)
+
+// writeTestCert writes a self-signed certificate and key for commonName
+func writeTestCert(t *testing.T, certFile, keyFile, commonName string) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("Failed to create certificate: %v", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("Failed to marshal key: %v", err)
+	}
+
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600); err != nil {
+		t.Fatalf("Failed to write certificate: %v", err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600); err != nil {
+		t.Fatalf("Failed to write key: %v", err)
+	}
+}
+
+func TestCertReloader_ReloadsOnChange(t *testing.T) {
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "server.crt")
+	keyFile := filepath.Join(dir, "server.key")
+	writeTestCert(t, certFile, keyFile, "first")
+
+	log, err := logger.New("error", "json", "stderr", false)
+	if err != nil {
+		t.Fatalf("Failed to create logger: %v", err)
+	}
+
+	r, err := newCertReloader(&config.TLSConfig{
+		CertFile:       certFile,
+		KeyFile:        keyFile,
+		ReloadInterval: 10 * time.Millisecond,
+	}, log)
+	if err != nil {
+		t.Fatalf("Failed to create reloader: %v", err)
+	}
+	defer r.Close()
+
+	commonName := func() string {
+		cfg, err := r.tlsConfig().GetConfigForClient(nil)
+		if err != nil {
+			t.Fatalf("GetConfigForClient failed: %v", err)
+		}
+		leaf, err := x509.ParseCertificate(cfg.Certificates[0].Certificate[0])
+		if err != nil {
+			t.Fatalf("Failed to parse certificate: %v", err)
+		}
+		return leaf.Subject.CommonName
+	}
+
+	if cn := commonName(); cn != "first" {
+		t.Fatalf("Expected initial certificate, got %q", cn)
+	}
+
+	writeTestCert(t, certFile, keyFile, "second")
+	future := time.Now().Add(time.Minute)
+	for _, file := range []string{certFile, keyFile} {
+		if err := os.Chtimes(file, future, future); err != nil {
+			t.Fatalf("Failed to touch %s: %v", file, err)
+		}
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for commonName() != "second" {
+		if time.Now().After(deadline) {
+			t.Fatal("Certificate was not reloaded")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -9,6 +9,7 @@ import (
 	"runtime"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -59,12 +60,25 @@ func ParseLevel(s string) Level {
 	}
 }
 
+// Task lifecycle event names, shared with the event notifier
+const (
+	EventTaskCreated      = "TaskCreated"
+	EventTaskStarted      = "TaskStarted"
+	EventOSSUploadStarted = "OSSUploadStarted"
+	EventTaskCompleted    = "TaskCompleted"
+	EventTaskFailed       = "TaskFailed"
+	EventTaskCancelled    = "TaskCancelled"
+	EventTaskInterrupted  = "TaskInterrupted"
+	EventTaskExpired      = "TaskExpired"
+	EventTaskDeleted      = "TaskDeleted"
+)
+
 // Fields represents additional structured fields for logging
 type Fields map[string]interface{}
 
 // Logger provides structured logging with context propagation
 type Logger struct {
-	level         Level
+	level         atomic.Int32 // Level, changed on config reload
 	output        io.Writer
 	formatJSON    bool
 	enableTracing bool
@@ -110,12 +124,18 @@ func New(level string, format string, output string, enableTracing bool) (*Logge
 		out = file
 	}
 
-	return &Logger{
-		level:         ParseLevel(level),
+	l := &Logger{
 		output:        out,
 		formatJSON:    format == "json",
 		enableTracing: enableTracing,
-	}, nil
+	}
+	l.SetLevel(level)
+	return l, nil
+}
+
+// SetLevel changes the minimum level of logged entries
+func (l *Logger) SetLevel(level string) {
+	l.level.Store(int32(ParseLevel(level)))
 }
 
 // WithContext creates a new logger with context values
@@ -128,7 +148,7 @@ func (l *Logger) WithContext(ctx context.Context) *ContextLogger {
 
 // log writes a log entry
 func (l *Logger) log(level Level, msg string, fields Fields) {
-	if level < l.level {
+	if level < Level(l.level.Load()) {
 		return
 	}
 
@@ -219,7 +239,7 @@ func (cl *ContextLogger) WithComponent(component string) *ContextLogger {
 
 // log writes a contextualized log entry
 func (cl *ContextLogger) log(level Level, event string, msg string, fields Fields, duration int64, err *ErrorInfo) {
-	if level < cl.logger.level {
+	if level < Level(cl.logger.level.Load()) {
 		return
 	}
 
@@ -261,22 +281,32 @@ func (cl *ContextLogger) log(level Level, event string, msg string, fields Field
 
 // LogTaskCreated logs task creation
 func (cl *ContextLogger) LogTaskCreated(msg string, fields Fields) {
-	cl.log(InfoLevel, "TaskCreated", msg, fields, 0, nil)
+	cl.log(InfoLevel, EventTaskCreated, msg, fields, 0, nil)
 }
 
 // LogTaskCompleted logs task completion
 func (cl *ContextLogger) LogTaskCompleted(msg string, duration int64, fields Fields) {
-	cl.log(InfoLevel, "TaskCompleted", msg, fields, duration, nil)
+	cl.log(InfoLevel, EventTaskCompleted, msg, fields, duration, nil)
 }
 
 // LogTaskFailed logs task failure
 func (cl *ContextLogger) LogTaskFailed(msg string, errorCode string, errorMsg string, fields Fields) {
-	cl.log(ErrorLevel, "TaskFailed", msg, fields, 0, &ErrorInfo{
+	cl.log(ErrorLevel, EventTaskFailed, msg, fields, 0, &ErrorInfo{
 		Code:    errorCode,
 		Message: errorMsg,
 	})
 }
 
+// LogTaskCancelled logs task cancellation
+func (cl *ContextLogger) LogTaskCancelled(msg string, fields Fields) {
+	cl.log(WarnLevel, EventTaskCancelled, msg, fields, 0, nil)
+}
+
+// LogTaskInterrupted logs a task interrupted by shutdown
+func (cl *ContextLogger) LogTaskInterrupted(msg string, fields Fields) {
+	cl.log(WarnLevel, EventTaskInterrupted, msg, fields, 0, nil)
+}
+
 // LogBatchProcessed logs batch processing
 func (cl *ContextLogger) LogBatchProcessed(msg string, duration int64, fields Fields) {
 	cl.log(DebugLevel, "BatchProcessed", msg, fields, duration, nil)
@@ -294,7 +324,7 @@ func (cl *ContextLogger) LogFileFinalized(msg string, duration int64, fields Fie
 
 // LogOSSUploadStarted logs OSS upload start
 func (cl *ContextLogger) LogOSSUploadStarted(msg string, fields Fields) {
-	cl.log(InfoLevel, "OSSUploadStarted", msg, fields, 0, nil)
+	cl.log(InfoLevel, EventOSSUploadStarted, msg, fields, 0, nil)
 }
 
 // LogOSSUploadCompleted logs OSS upload completion
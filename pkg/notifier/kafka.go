@@ -0,0 +1,102 @@
+package notifier
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/fluxo/export-middleware/pkg/config"
+)
+
+// kafkaContentType is the Kafka REST Proxy v2 content type for JSON records
+const kafkaContentType = "application/vnd.kafka.json.v2+json"
+
+// KafkaRestProxyNotifier produces events to a Kafka topic through a Kafka
+// REST Proxy (v2 API), keyed by task ID so events of a task stay in one
+// partition. It does not talk to Kafka brokers itself; delivery is
+// acknowledged by the proxy per record.
+type KafkaRestProxyNotifier struct {
+	config *config.KafkaRestProxyConfig
+	client *http.Client
+}
+
+// kafkaRecord is a single record of a REST Proxy produce request
+type kafkaRecord struct {
+	Key   string `json:"key"`
+	Value *Event `json:"value"`
+}
+
+// kafkaProduceResponse is the REST Proxy reply to a produce request. The
+// proxy answers 200 even when records fail, reporting errors per record.
+type kafkaProduceResponse struct {
+	Offsets []struct {
+		Partition *int    `json:"partition"`
+		ErrorCode *int    `json:"error_code"`
+		Error     *string `json:"error"`
+	} `json:"offsets"`
+}
+
+// NewKafkaRestProxyNotifier creates a Kafka REST Proxy notifier
+func NewKafkaRestProxyNotifier(cfg *config.KafkaRestProxyConfig, timeout time.Duration) *KafkaRestProxyNotifier {
+	return &KafkaRestProxyNotifier{
+		config: cfg,
+		client: &http.Client{Timeout: timeout},
+	}
+}
+
+// Publish produces the event to the configured topic
+func (n *KafkaRestProxyNotifier) Publish(ctx context.Context, event *Event) error {
+	body, err := json.Marshal(map[string][]kafkaRecord{
+		"records": {{Key: event.TaskID, Value: event}},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to encode event: %w", err)
+	}
+
+	endpoint := strings.TrimRight(n.config.URL, "/") + "/topics/" + url.PathEscape(n.config.Topic)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create produce request: %w", err)
+	}
+	req.Header.Set("Content-Type", kafkaContentType)
+	req.Header.Set("Accept", "application/vnd.kafka.v2+json")
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("kafka produce request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	detail, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("kafka produce returned status %d: %s", resp.StatusCode, strings.TrimSpace(string(detail)))
+	}
+
+	var result kafkaProduceResponse
+	if err := json.Unmarshal(detail, &result); err != nil {
+		return fmt.Errorf("invalid kafka produce response: %w", err)
+	}
+	if len(result.Offsets) != 1 {
+		return fmt.Errorf("kafka produce acknowledged %d records, expected 1", len(result.Offsets))
+	}
+	if offset := result.Offsets[0]; offset.ErrorCode != nil || offset.Error != nil || offset.Partition == nil {
+		message := "record not stored"
+		if offset.Error != nil {
+			message = *offset.Error
+		}
+		return fmt.Errorf("kafka produce failed: %s", message)
+	}
+	return nil
+}
+
+// Close releases idle connections
+func (n *KafkaRestProxyNotifier) Close() error {
+	n.client.CloseIdleConnections()
+	return nil
+}
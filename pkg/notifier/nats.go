@@ -0,0 +1,236 @@
+package notifier
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fluxo/export-middleware/pkg/config"
+)
+
+// NATSNotifier publishes events to a NATS subject using the NATS text
+// protocol. Events are published to "<subject>.<event name>" so consumers
+// can subscribe to a single event type or to "<subject>.>" for all. The
+// connection runs in verbose mode, so every publish waits for the server
+// to acknowledge it with +OK or reject it with -ERR.
+type NATSNotifier struct {
+	config  *config.NATSNotifierConfig
+	timeout time.Duration
+	mu      sync.Mutex // serializes publishes and guards conn
+	writeMu sync.Mutex // serializes writes to conn
+	conn    net.Conn
+	acks    chan error // server replies to publishes on conn
+}
+
+// NewNATSNotifier creates a NATS notifier. The connection is opened lazily
+// on the first publish.
+func NewNATSNotifier(cfg *config.NATSNotifierConfig, timeout time.Duration) *NATSNotifier {
+	return &NATSNotifier{
+		config:  cfg,
+		timeout: timeout,
+	}
+}
+
+// Publish sends the event to the configured subject and waits for the
+// server to acknowledge it
+func (n *NATSNotifier) Publish(ctx context.Context, event *Event) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to encode event: %w", err)
+	}
+
+	subject := n.config.Subject + "." + event.Name
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if err := n.connect(ctx); err != nil {
+		return err
+	}
+
+	n.writeMu.Lock()
+	n.conn.SetWriteDeadline(time.Now().Add(n.timeout))
+	msg := fmt.Sprintf("PUB %s %d\r\n%s\r\n", subject, len(data), data)
+	_, err = n.conn.Write([]byte(msg))
+	n.writeMu.Unlock()
+	if err != nil {
+		n.reset()
+		return fmt.Errorf("nats publish failed: %w", err)
+	}
+
+	timer := time.NewTimer(n.timeout)
+	defer timer.Stop()
+
+	// Without an acknowledgement the connection state is unknown, so it is
+	// dropped and a late reply cannot be taken for the next publish
+	select {
+	case err := <-n.acks:
+		if err != nil {
+			n.reset()
+			return fmt.Errorf("nats publish rejected: %w", err)
+		}
+		return nil
+	case <-ctx.Done():
+		n.reset()
+		return fmt.Errorf("nats publish not acknowledged: %w", ctx.Err())
+	case <-timer.C:
+		n.reset()
+		return fmt.Errorf("nats publish not acknowledged within %s", n.timeout)
+	}
+}
+
+// connect opens the connection and performs the CONNECT handshake.
+// The caller must hold n.mu.
+func (n *NATSNotifier) connect(ctx context.Context) error {
+	if n.conn != nil {
+		return nil
+	}
+
+	dialer := net.Dialer{Timeout: n.timeout}
+	conn, err := dialer.DialContext(ctx, "tcp", n.config.Address)
+	if err != nil {
+		return fmt.Errorf("failed to connect to nats: %w", err)
+	}
+
+	reader := bufio.NewReader(conn)
+	conn.SetDeadline(time.Now().Add(n.timeout))
+
+	// The server greets with INFO
+	line, err := reader.ReadString('\n')
+	if err != nil || !strings.HasPrefix(line, "INFO") {
+		conn.Close()
+		return fmt.Errorf("unexpected nats greeting: %q", line)
+	}
+	var info struct {
+		TLSRequired bool `json:"tls_required"`
+	}
+	json.Unmarshal([]byte(strings.TrimSpace(strings.TrimPrefix(line, "INFO"))), &info)
+
+	// TLS is negotiated after the plain text INFO
+	if n.config.TLS.Enabled {
+		tlsConfig, err := clientTLSConfig(&n.config.TLS, n.config.Address)
+		if err != nil {
+			conn.Close()
+			return err
+		}
+		tlsConn := tls.Client(conn, tlsConfig)
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			conn.Close()
+			return fmt.Errorf("nats TLS handshake failed: %w", err)
+		}
+		conn = tlsConn
+		reader = bufio.NewReader(conn)
+	} else if info.TLSRequired {
+		conn.Close()
+		return fmt.Errorf("nats server requires TLS, set notifications.nats.tls.enabled")
+	}
+
+	options := map[string]interface{}{
+		"verbose":      true,
+		"pedantic":     false,
+		"tls_required": n.config.TLS.Enabled,
+		"name":         "export-middleware",
+		"lang":         "go",
+		"version":      "1.0.0",
+	}
+	if n.config.Username != "" {
+		options["user"] = n.config.Username
+		options["pass"] = n.config.Password
+	}
+	if n.config.Token != "" {
+		options["auth_token"] = n.config.Token
+	}
+	connectOpts, _ := json.Marshal(options)
+
+	// PING after CONNECT so authentication errors surface immediately. In
+	// verbose mode CONNECT is acknowledged with +OK before the PONG.
+	if _, err := fmt.Fprintf(conn, "CONNECT %s\r\nPING\r\n", connectOpts); err != nil {
+		conn.Close()
+		return fmt.Errorf("nats connect failed: %w", err)
+	}
+	for {
+		line, err = reader.ReadString('\n')
+		if err != nil {
+			conn.Close()
+			return fmt.Errorf("nats connect failed: %w", err)
+		}
+		if strings.HasPrefix(line, "PONG") {
+			break
+		}
+		if !strings.HasPrefix(line, "+OK") {
+			conn.Close()
+			return fmt.Errorf("nats connect rejected: %s", strings.TrimSpace(line))
+		}
+	}
+
+	conn.SetDeadline(time.Time{})
+	n.conn = conn
+	n.acks = make(chan error, 1)
+
+	go n.readLoop(conn, reader, n.acks)
+
+	return nil
+}
+
+// readLoop passes publish acknowledgements to acks, answers server PINGs
+// to keep the connection alive and drops the connection when the server
+// reports an error or goes away
+func (n *NATSNotifier) readLoop(conn net.Conn, reader *bufio.Reader, acks chan<- error) {
+	ack := func(err error) {
+		select {
+		case acks <- err:
+		default:
+		}
+	}
+
+	for {
+		line, err := reader.ReadString('\n')
+		if err == nil && strings.HasPrefix(line, "-ERR") {
+			err = errors.New(strings.TrimSpace(strings.TrimPrefix(line, "-ERR")))
+		}
+		if err != nil {
+			ack(err)
+			n.mu.Lock()
+			if n.conn == conn {
+				n.reset()
+			}
+			n.mu.Unlock()
+			return
+		}
+
+		switch {
+		case strings.HasPrefix(line, "+OK"):
+			ack(nil)
+		case strings.HasPrefix(line, "PING"):
+			n.writeMu.Lock()
+			conn.Write([]byte("PONG\r\n"))
+			n.writeMu.Unlock()
+		}
+	}
+}
+
+// reset drops the connection so the next publish reconnects.
+// The caller must hold n.mu.
+func (n *NATSNotifier) reset() {
+	if n.conn != nil {
+		n.conn.Close()
+	}
+	n.conn = nil
+	n.acks = nil
+}
+
+// Close closes the connection
+func (n *NATSNotifier) Close() error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	n.reset()
+	return nil
+}
@@ -0,0 +1,165 @@
+package notifier
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/fluxo/export-middleware/pkg/config"
+	"github.com/fluxo/export-middleware/pkg/logger"
+)
+
+// Event is a task lifecycle event. Name is one of the logger.EventTask*
+// constants so events can be correlated with the structured logs.
+type Event struct {
+	Name           string    `json:"event"`
+	TaskID         string    `json:"task_id"`
+	RequestID      string    `json:"request_id,omitempty"`
+	ClientID       string    `json:"client_id,omitempty"`
+	Status         string    `json:"status"`
+	Format         string    `json:"format,omitempty"`
+	Filename       string    `json:"filename,omitempty"`
+	OSSUrl         string    `json:"oss_url,omitempty"`
+	ChecksumSHA256 string    `json:"checksum_sha256,omitempty"`
+	FileSizeBytes  int64     `json:"file_size_bytes,omitempty"`
+	RecordCount    int64     `json:"record_count,omitempty"`
+	ErrorCode      string    `json:"error_code,omitempty"`
+	ErrorMessage   string    `json:"error_message,omitempty"`
+	Timestamp      time.Time `json:"timestamp"`
+}
+
+// Notifier publishes task lifecycle events to a message broker
+type Notifier interface {
+	// Publish sends a single event
+	Publish(ctx context.Context, event *Event) error
+
+	// Close releases broker connections
+	Close() error
+}
+
+// New creates the notifier configured in cfg, or nil if notifications are
+// disabled
+func New(cfg *config.NotificationsConfig) (Notifier, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+
+	switch cfg.Sink {
+	case "redis":
+		return NewRedisNotifier(&cfg.Redis, cfg.Timeout), nil
+	case "kafka_rest_proxy":
+		return NewKafkaRestProxyNotifier(&cfg.KafkaRestProxy, cfg.Timeout), nil
+	case "nats":
+		return NewNATSNotifier(&cfg.NATS, cfg.Timeout), nil
+	default:
+		return nil, fmt.Errorf("unknown notification sink: %s", cfg.Sink)
+	}
+}
+
+// clientTLSConfig returns the TLS settings for connecting to a sink at
+// address, verifying the server against the configured CA bundle or the
+// system roots
+func clientTLSConfig(cfg *config.NotifierTLSConfig, address string) (*tls.Config, error) {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		host = address
+	}
+	tlsConfig := &tls.Config{ServerName: host, MinVersion: tls.VersionTLS12}
+
+	if cfg.CAFile != "" {
+		pem, err := os.ReadFile(cfg.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read notifier CA file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in notifier CA file %s", cfg.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	return tlsConfig, nil
+}
+
+// Dispatcher publishes events asynchronously so that a slow or unavailable
+// broker never blocks task processing. Events are dropped with a warning
+// when the buffer is full.
+type Dispatcher struct {
+	notifier Notifier
+	events   chan *Event
+	logger   *logger.Logger
+	timeout  time.Duration
+	done     chan struct{}
+	mu       sync.RWMutex
+	closed   bool
+}
+
+// NewDispatcher creates a dispatcher and starts its delivery goroutine
+func NewDispatcher(n Notifier, bufferSize int, timeout time.Duration, log *logger.Logger) *Dispatcher {
+	d := &Dispatcher{
+		notifier: n,
+		events:   make(chan *Event, bufferSize),
+		logger:   log,
+		timeout:  timeout,
+		done:     make(chan struct{}),
+	}
+
+	go d.run()
+
+	return d
+}
+
+// Dispatch queues an event for delivery. Events dispatched after Close
+// are discarded.
+func (d *Dispatcher) Dispatch(event *Event) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	if d.closed {
+		return
+	}
+
+	select {
+	case d.events <- event:
+	default:
+		d.logger.WithContext(context.Background()).WithTaskID(event.TaskID).WithComponent("notifier").LogWarn(
+			"EventDropped",
+			"Notification buffer full, event dropped",
+			logger.Fields{"event": event.Name},
+		)
+	}
+}
+
+// run delivers queued events until the dispatcher is closed
+func (d *Dispatcher) run() {
+	defer close(d.done)
+
+	for event := range d.events {
+		ctx, cancel := context.WithTimeout(context.Background(), d.timeout)
+		if err := d.notifier.Publish(ctx, event); err != nil {
+			d.logger.WithContext(ctx).WithTaskID(event.TaskID).WithComponent("notifier").LogError(
+				"EventPublishError",
+				"Failed to publish task event",
+				"NOTIFIER_ERROR",
+				err.Error(),
+				logger.Fields{"event": event.Name},
+			)
+		}
+		cancel()
+	}
+}
+
+// Close delivers the remaining queued events and closes the notifier
+func (d *Dispatcher) Close() error {
+	d.mu.Lock()
+	d.closed = true
+	close(d.events)
+	d.mu.Unlock()
+
+	<-d.done
+	return d.notifier.Close()
+}
@@ -0,0 +1,214 @@
+package notifier
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fluxo/export-middleware/pkg/config"
+	"github.com/fluxo/export-middleware/pkg/logger"
+)
+
+func testEvent() *Event {
+	return &Event{
+		Name:      logger.EventTaskCompleted,
+		TaskID:    "task-1",
+		Status:    "TASK_STATUS_COMPLETED",
+		OSSUrl:    "https://bucket.example.com/exports/report.csv",
+		Timestamp: time.Now(),
+	}
+}
+
+// readRESPCommand reads one RESP array of bulk strings
+func readRESPCommand(r *bufio.Reader) ([]string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	count, _ := strconv.Atoi(strings.TrimSpace(line[1:]))
+	args := make([]string, 0, count)
+	for i := 0; i < count; i++ {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		size, _ := strconv.Atoi(strings.TrimSpace(line[1:]))
+		data := make([]byte, size+2)
+		if _, err := io.ReadFull(r, data); err != nil {
+			return nil, err
+		}
+		args = append(args, string(data[:size]))
+	}
+	return args, nil
+}
+
+func TestRedisNotifier_XAdd(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer lis.Close()
+
+	commands := make(chan []string, 4)
+	go func() {
+		conn, err := lis.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		for {
+			args, err := readRESPCommand(r)
+			if err != nil {
+				return
+			}
+			commands <- args
+			if args[0] == "XADD" {
+				conn.Write([]byte("$15\r\n1700000000000-0\r\n"))
+			} else {
+				conn.Write([]byte("+OK\r\n"))
+			}
+		}
+	}()
+
+	n := NewRedisNotifier(&config.RedisNotifierConfig{
+		Address:  lis.Addr().String(),
+		Password: "secret",
+		Stream:   "export-events",
+		MaxLen:   1000,
+	}, time.Second)
+	defer n.Close()
+
+	if err := n.Publish(context.Background(), testEvent()); err != nil {
+		t.Fatalf("Publish failed: %v", err)
+	}
+
+	if auth := <-commands; auth[0] != "AUTH" || auth[1] != "secret" {
+		t.Errorf("Expected AUTH first, got %v", auth)
+	}
+	xadd := <-commands
+	want := []string{"XADD", "export-events", "MAXLEN", "~", "1000", "*", "event", logger.EventTaskCompleted, "task_id", "task-1", "payload"}
+	if len(xadd) != len(want)+1 || fmt.Sprint(xadd[:len(want)]) != fmt.Sprint(want) {
+		t.Fatalf("Unexpected XADD command: %v", xadd)
+	}
+
+	var event Event
+	if err := json.Unmarshal([]byte(xadd[len(want)]), &event); err != nil || event.TaskID != "task-1" {
+		t.Errorf("Unexpected payload %q: %v", xadd[len(want)], err)
+	}
+}
+
+func TestKafkaRestProxyNotifier_Produce(t *testing.T) {
+	var body struct {
+		Records []struct {
+			Key   string `json:"key"`
+			Value Event  `json:"value"`
+		} `json:"records"`
+	}
+	reply := `{"offsets":[{"partition":0,"offset":42,"error_code":null,"error":null}]}`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/topics/export-events" {
+			t.Errorf("Unexpected path %s", r.URL.Path)
+		}
+		if ct := r.Header.Get("Content-Type"); ct != kafkaContentType {
+			t.Errorf("Unexpected content type %s", ct)
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(reply))
+	}))
+	defer server.Close()
+
+	n := NewKafkaRestProxyNotifier(&config.KafkaRestProxyConfig{URL: server.URL, Topic: "export-events"}, time.Second)
+	if err := n.Publish(context.Background(), testEvent()); err != nil {
+		t.Fatalf("Publish failed: %v", err)
+	}
+
+	if len(body.Records) != 1 || body.Records[0].Key != "task-1" || body.Records[0].Value.Name != logger.EventTaskCompleted {
+		t.Errorf("Unexpected produce request: %+v", body)
+	}
+
+	// The proxy reports failed records with status 200
+	reply = `{"offsets":[{"partition":null,"offset":null,"error_code":50003,"error":"Kafka error: timeout"}]}`
+	if err := n.Publish(context.Background(), testEvent()); err == nil || !strings.Contains(err.Error(), "Kafka error: timeout") {
+		t.Errorf("Expected record error to be reported, got %v", err)
+	}
+}
+
+// natsServer serves one NATS connection in verbose mode, replying to each
+// PUB with reply and passing published messages to the returned channel
+func natsServer(t *testing.T, reply string) (string, <-chan string) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	t.Cleanup(func() { lis.Close() })
+
+	published := make(chan string, 1)
+	go func() {
+		conn, err := lis.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		conn.Write([]byte("INFO {\"server_id\":\"test\"}\r\n"))
+		r := bufio.NewReader(conn)
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			switch {
+			case strings.HasPrefix(line, "CONNECT"):
+				conn.Write([]byte("+OK\r\n"))
+			case strings.HasPrefix(line, "PING"):
+				conn.Write([]byte("PONG\r\n"))
+			case strings.HasPrefix(line, "PUB"):
+				payload, _ := r.ReadString('\n')
+				published <- strings.TrimSpace(line) + " " + strings.TrimSpace(payload)
+				conn.Write([]byte(reply))
+			}
+		}
+	}()
+	return lis.Addr().String(), published
+}
+
+func TestNATSNotifier_Publish(t *testing.T) {
+	address, published := natsServer(t, "+OK\r\n")
+
+	n := NewNATSNotifier(&config.NATSNotifierConfig{Address: address, Subject: "export.events"}, time.Second)
+	defer n.Close()
+
+	if err := n.Publish(context.Background(), testEvent()); err != nil {
+		t.Fatalf("Publish failed: %v", err)
+	}
+
+	select {
+	case msg := <-published:
+		if !strings.HasPrefix(msg, "PUB export.events."+logger.EventTaskCompleted+" ") {
+			t.Errorf("Unexpected publish: %s", msg)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Timed out waiting for publish")
+	}
+}
+
+func TestNATSNotifier_Rejected(t *testing.T) {
+	address, _ := natsServer(t, "-ERR 'Permissions Violation for Publish to export.events.TaskCompleted'\r\n")
+
+	n := NewNATSNotifier(&config.NATSNotifierConfig{Address: address, Subject: "export.events"}, time.Second)
+	defer n.Close()
+
+	if err := n.Publish(context.Background(), testEvent()); err == nil || !strings.Contains(err.Error(), "Permissions Violation") {
+		t.Errorf("Expected rejected publish to fail, got %v", err)
+	}
+}
@@ -0,0 +1,182 @@
+package notifier
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/fluxo/export-middleware/pkg/config"
+)
+
+// RedisNotifier appends events to a Redis Stream with XADD. It speaks the
+// RESP protocol directly over a single reused connection, optionally over
+// TLS. A publish succeeds once Redis replies with the ID of the entry.
+type RedisNotifier struct {
+	config  *config.RedisNotifierConfig
+	timeout time.Duration
+	mu      sync.Mutex
+	conn    net.Conn
+	reader  *bufio.Reader
+}
+
+// NewRedisNotifier creates a Redis Streams notifier. The connection is
+// opened lazily on the first publish.
+func NewRedisNotifier(cfg *config.RedisNotifierConfig, timeout time.Duration) *RedisNotifier {
+	return &RedisNotifier{
+		config:  cfg,
+		timeout: timeout,
+	}
+}
+
+// Publish appends the event to the configured stream
+func (n *RedisNotifier) Publish(ctx context.Context, event *Event) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to encode event: %w", err)
+	}
+
+	args := []string{"XADD", n.config.Stream}
+	if n.config.MaxLen > 0 {
+		args = append(args, "MAXLEN", "~", strconv.FormatInt(n.config.MaxLen, 10))
+	}
+	args = append(args, "*", "event", event.Name, "task_id", event.TaskID, "payload", string(data))
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if err := n.connect(ctx); err != nil {
+		return err
+	}
+
+	if _, err := n.command(ctx, args...); err != nil {
+		n.reset()
+		return fmt.Errorf("redis XADD failed: %w", err)
+	}
+	return nil
+}
+
+// connect opens and authenticates the connection if needed.
+// The caller must hold n.mu.
+func (n *RedisNotifier) connect(ctx context.Context) error {
+	if n.conn != nil {
+		return nil
+	}
+
+	dialer := &net.Dialer{Timeout: n.timeout}
+	var conn net.Conn
+	var err error
+	if n.config.TLS.Enabled {
+		tlsConfig, tlsErr := clientTLSConfig(&n.config.TLS, n.config.Address)
+		if tlsErr != nil {
+			return tlsErr
+		}
+		conn, err = (&tls.Dialer{NetDialer: dialer, Config: tlsConfig}).DialContext(ctx, "tcp", n.config.Address)
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", n.config.Address)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to connect to redis: %w", err)
+	}
+	n.conn = conn
+	n.reader = bufio.NewReader(conn)
+
+	if n.config.Password != "" {
+		if _, err := n.command(ctx, "AUTH", n.config.Password); err != nil {
+			n.reset()
+			return fmt.Errorf("redis AUTH failed: %w", err)
+		}
+	}
+	if n.config.DB != 0 {
+		if _, err := n.command(ctx, "SELECT", strconv.Itoa(n.config.DB)); err != nil {
+			n.reset()
+			return fmt.Errorf("redis SELECT failed: %w", err)
+		}
+	}
+	return nil
+}
+
+// command sends a command and reads a single-line reply.
+// The caller must hold n.mu.
+func (n *RedisNotifier) command(ctx context.Context, args ...string) (string, error) {
+	deadline := time.Now().Add(n.timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	n.conn.SetDeadline(deadline)
+
+	if _, err := n.conn.Write(encodeRESP(args)); err != nil {
+		return "", err
+	}
+	return readRESPReply(n.reader)
+}
+
+// reset drops a broken connection so the next publish reconnects.
+// The caller must hold n.mu.
+func (n *RedisNotifier) reset() {
+	if n.conn != nil {
+		n.conn.Close()
+	}
+	n.conn = nil
+	n.reader = nil
+}
+
+// Close closes the connection
+func (n *RedisNotifier) Close() error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	n.reset()
+	return nil
+}
+
+// encodeRESP encodes a command as a RESP array of bulk strings
+func encodeRESP(args []string) []byte {
+	buf := []byte(fmt.Sprintf("*%d\r\n", len(args)))
+	for _, arg := range args {
+		buf = append(buf, fmt.Sprintf("$%d\r\n", len(arg))...)
+		buf = append(buf, arg...)
+		buf = append(buf, "\r\n"...)
+	}
+	return buf
+}
+
+// readRESPReply reads a simple string, error, integer or bulk string reply
+func readRESPReply(r *bufio.Reader) (string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	if len(line) < 3 {
+		return "", fmt.Errorf("malformed reply %q", line)
+	}
+	line = line[:len(line)-2]
+
+	switch line[0] {
+	case '+', ':':
+		return line[1:], nil
+	case '-':
+		return "", fmt.Errorf("%s", line[1:])
+	case '$':
+		size, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return "", fmt.Errorf("malformed bulk length %q", line)
+		}
+		if size < 0 {
+			return "", nil
+		}
+		data := make([]byte, size+2)
+		if _, err := io.ReadFull(r, data); err != nil {
+			return "", err
+		}
+		return string(data[:size]), nil
+	default:
+		return "", fmt.Errorf("unexpected reply %q", line)
+	}
+}
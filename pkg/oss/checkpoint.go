@@ -0,0 +1,176 @@
+package oss
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/aliyun/aliyun-oss-go-sdk/oss"
+	"github.com/fluxo/export-middleware/pkg/storage"
+)
+
+// checkpointSuffix is appended to the local file path to name its
+// checkpoint file. The storage manager removes checkpoints with their
+// temp files.
+const checkpointSuffix = storage.UploadCheckpointSuffix
+
+// uploadCheckpoint records the progress of a multi-part upload next to the
+// local file, so a retry resumes from the parts already uploaded instead
+// of starting over.
+type uploadCheckpoint struct {
+	ObjectKey string         `json:"object_key"`
+	UploadID  string         `json:"upload_id"`
+	FileSize  int64          `json:"file_size"`
+	ModTime   time.Time      `json:"mod_time"`
+	PartSize  int64          `json:"part_size"`
+	Parts     map[int]string `json:"parts"` // Part number -> ETag
+
+	path string
+	mu   sync.Mutex
+}
+
+// checkpointPath returns the checkpoint file of a local file
+func checkpointPath(localPath string) string {
+	return localPath + checkpointSuffix
+}
+
+// newCheckpoint creates a checkpoint for a new upload of a local file
+func newCheckpoint(localPath string, fileInfo os.FileInfo, objectKey string, partSize int64) *uploadCheckpoint {
+	return &uploadCheckpoint{
+		ObjectKey: objectKey,
+		FileSize:  fileInfo.Size(),
+		ModTime:   fileInfo.ModTime(),
+		PartSize:  partSize,
+		Parts:     make(map[int]string),
+		path:      checkpointPath(localPath),
+	}
+}
+
+// loadCheckpoint reads the checkpoint of a local file. It returns nil
+// without error when there is none.
+func loadCheckpoint(localPath string) (*uploadCheckpoint, error) {
+	path := checkpointPath(localPath)
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read checkpoint: %w", err)
+	}
+
+	cp := &uploadCheckpoint{}
+	if err := json.Unmarshal(data, cp); err != nil {
+		return nil, fmt.Errorf("failed to decode checkpoint: %w", err)
+	}
+	if cp.Parts == nil {
+		cp.Parts = make(map[int]string)
+	}
+	cp.path = path
+	return cp, nil
+}
+
+// matches reports whether the checkpoint was taken for the file as it is
+// now, split into parts of the same size
+func (cp *uploadCheckpoint) matches(fileInfo os.FileInfo, partSize int64) bool {
+	return cp.UploadID != "" &&
+		cp.FileSize == fileInfo.Size() &&
+		cp.ModTime.Equal(fileInfo.ModTime()) &&
+		cp.PartSize == partSize
+}
+
+// upload returns the multi-part upload the checkpoint belongs to
+func (cp *uploadCheckpoint) upload(bucket string) oss.InitiateMultipartUploadResult {
+	return oss.InitiateMultipartUploadResult{Bucket: bucket, Key: cp.ObjectKey, UploadID: cp.UploadID}
+}
+
+// start records a newly initiated upload, dropping the parts of any
+// previous one
+func (cp *uploadCheckpoint) start(uploadID string) error {
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+
+	cp.UploadID = uploadID
+	cp.Parts = make(map[int]string)
+	return cp.saveLocked()
+}
+
+// reset forgets the upload so a new one is started
+func (cp *uploadCheckpoint) reset() {
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+
+	cp.UploadID = ""
+	cp.Parts = make(map[int]string)
+}
+
+// done reports whether a part has been uploaded
+func (cp *uploadCheckpoint) done(partNumber int) bool {
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+
+	_, exists := cp.Parts[partNumber]
+	return exists
+}
+
+// complete records an uploaded part
+func (cp *uploadCheckpoint) complete(part oss.UploadPart) error {
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+
+	cp.Parts[part.PartNumber] = part.ETag
+	return cp.saveLocked()
+}
+
+// retain keeps only the recorded parts for which keep returns true
+func (cp *uploadCheckpoint) retain(keep func(partNumber int, etag string) bool) {
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+
+	for number, etag := range cp.Parts {
+		if !keep(number, etag) {
+			delete(cp.Parts, number)
+		}
+	}
+}
+
+// uploadedParts returns the recorded parts in part number order
+func (cp *uploadCheckpoint) uploadedParts() []oss.UploadPart {
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+
+	parts := make([]oss.UploadPart, 0, len(cp.Parts))
+	for number, etag := range cp.Parts {
+		parts = append(parts, oss.UploadPart{PartNumber: number, ETag: etag})
+	}
+	sort.Sort(oss.UploadParts(parts))
+	return parts
+}
+
+// saveLocked writes the checkpoint to disk, replacing the previous one
+// atomically. The caller must hold cp.mu.
+func (cp *uploadCheckpoint) saveLocked() error {
+	data, err := json.Marshal(cp)
+	if err != nil {
+		return fmt.Errorf("failed to encode checkpoint: %w", err)
+	}
+
+	tmpPath := cp.path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write checkpoint: %w", err)
+	}
+	if err := os.Rename(tmpPath, cp.path); err != nil {
+		return fmt.Errorf("failed to write checkpoint: %w", err)
+	}
+	return nil
+}
+
+// remove deletes the checkpoint file
+func (cp *uploadCheckpoint) remove() error {
+	if err := os.Remove(cp.path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
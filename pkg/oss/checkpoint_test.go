@@ -0,0 +1,73 @@
+package oss
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aliyun/aliyun-oss-go-sdk/oss"
+)
+
+func TestCheckpoint_SaveAndLoad(t *testing.T) {
+	localPath := filepath.Join(t.TempDir(), "export.csv")
+	if err := os.WriteFile(localPath, make([]byte, 25), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	fileInfo, err := os.Stat(localPath)
+	if err != nil {
+		t.Fatalf("Failed to stat file: %v", err)
+	}
+
+	if cp, err := loadCheckpoint(localPath); err != nil || cp != nil {
+		t.Fatalf("Expected no checkpoint, got %v, %v", cp, err)
+	}
+
+	cp := newCheckpoint(localPath, fileInfo, "exports/2024/01/01/export.csv", 10)
+	if err := cp.start("upload-1"); err != nil {
+		t.Fatalf("Failed to start checkpoint: %v", err)
+	}
+	for _, part := range []oss.UploadPart{{PartNumber: 3, ETag: "c"}, {PartNumber: 1, ETag: "a"}} {
+		if err := cp.complete(part); err != nil {
+			t.Fatalf("Failed to record part: %v", err)
+		}
+	}
+
+	loaded, err := loadCheckpoint(localPath)
+	if err != nil || loaded == nil {
+		t.Fatalf("Failed to load checkpoint: %v", err)
+	}
+	if !loaded.matches(fileInfo, 10) {
+		t.Error("Expected checkpoint to match the unchanged file")
+	}
+	if loaded.matches(fileInfo, 20) {
+		t.Error("Expected checkpoint not to match another part size")
+	}
+	if loaded.ObjectKey != cp.ObjectKey || loaded.UploadID != "upload-1" {
+		t.Errorf("Unexpected checkpoint: %+v", loaded)
+	}
+
+	parts := loaded.uploadedParts()
+	if len(parts) != 2 || parts[0].PartNumber != 1 || parts[1].PartNumber != 3 || parts[1].ETag != "c" {
+		t.Errorf("Expected parts 1 and 3 in order, got %v", parts)
+	}
+	if loaded.done(2) || !loaded.done(3) {
+		t.Error("Expected only parts 1 and 3 to be done")
+	}
+
+	// Touching the file invalidates the checkpoint
+	later := fileInfo.ModTime().Add(time.Second)
+	if err := os.Chtimes(localPath, later, later); err != nil {
+		t.Fatalf("Failed to touch file: %v", err)
+	}
+	if fileInfo, _ = os.Stat(localPath); loaded.matches(fileInfo, 10) {
+		t.Error("Expected checkpoint not to match a modified file")
+	}
+
+	if err := loaded.remove(); err != nil {
+		t.Fatalf("Failed to remove checkpoint: %v", err)
+	}
+	if cp, _ := loadCheckpoint(localPath); cp != nil {
+		t.Error("Expected checkpoint to be removed")
+	}
+}
@@ -0,0 +1,85 @@
+package oss
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"hash/crc64"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+
+	"github.com/aliyun/aliyun-oss-go-sdk/oss"
+)
+
+// ErrIntegrity is returned when the object stored in OSS does not match
+// the bytes that were uploaded
+var ErrIntegrity = errors.New("uploaded object does not match the local file")
+
+// integrityRetries is how many times a file is uploaded again when the
+// stored object does not match it. Failed requests are retried on their
+// own, so a mismatch is rare and not worth many attempts.
+const integrityRetries = 1
+
+// crcTable is the CRC-64/ECMA table OSS computes object checksums with
+var crcTable = crc64.MakeTable(crc64.ECMA)
+
+// contentMD5 returns the Content-MD5 header value of data
+func contentMD5(data []byte) string {
+	sum := md5.Sum(data)
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// fileDigest returns the Content-MD5 header value and the CRC64 of size
+// bytes of a file from offset
+func fileDigest(path string, offset int64, size int64) (string, uint64, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", 0, err
+	}
+	defer file.Close()
+
+	md5Hash := md5.New()
+	crcHash := crc64.New(crcTable)
+	n, err := io.Copy(io.MultiWriter(md5Hash, crcHash), io.NewSectionReader(file, offset, size))
+	if err != nil {
+		return "", 0, err
+	}
+	if n != size {
+		return "", 0, fmt.Errorf("read %d bytes of %s, expected %d", n, path, size)
+	}
+	return base64.StdEncoding.EncodeToString(md5Hash.Sum(nil)), crcHash.Sum64(), nil
+}
+
+// verifyObject compares the object stored in OSS with what was uploaded:
+// its size and the CRC64 OSS computed over the stored bytes. A mismatch is
+// reported as ErrIntegrity.
+func (u *Uploader) verifyObject(ctx context.Context, objectKey string, size int64, crc uint64) error {
+	header, err := u.bucket.GetObjectDetailedMeta(objectKey, oss.WithContext(ctx))
+	if err != nil {
+		return fmt.Errorf("failed to read uploaded object: %w", err)
+	}
+	return checkObject(header, size, crc)
+}
+
+// checkObject compares the headers of a stored object with what was
+// uploaded
+func checkObject(header http.Header, size int64, crc uint64) error {
+	stored, err := strconv.ParseInt(header.Get(oss.HTTPHeaderContentLength), 10, 64)
+	if err != nil || stored != size {
+		return fmt.Errorf("%w: size is %q, expected %d", ErrIntegrity, header.Get(oss.HTTPHeaderContentLength), size)
+	}
+
+	// OSS returns the CRC64 of every object uploaded in one request or
+	// by a multi-part upload
+	if value := header.Get(oss.HTTPHeaderOssCRC64); value != "" {
+		storedCRC, err := strconv.ParseUint(value, 10, 64)
+		if err != nil || storedCRC != crc {
+			return fmt.Errorf("%w: CRC64 is %q, expected %d", ErrIntegrity, value, crc)
+		}
+	}
+	return nil
+}
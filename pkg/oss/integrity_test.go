@@ -0,0 +1,69 @@
+package oss
+
+import (
+	"errors"
+	"hash/crc64"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+func TestFileDigest(t *testing.T) {
+	data := []byte("id,name\n1,alice\n2,bob\n")
+	path := filepath.Join(t.TempDir(), "export.csv")
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	md5, crc, err := fileDigest(path, 8, 8)
+	if err != nil {
+		t.Fatalf("fileDigest failed: %v", err)
+	}
+	if want := contentMD5(data[8:16]); md5 != want {
+		t.Errorf("Expected Content-MD5 %s, got %s", want, md5)
+	}
+	if want := crc64.Checksum(data[8:16], crcTable); crc != want {
+		t.Errorf("Expected CRC64 %d, got %d", want, crc)
+	}
+
+	if _, _, err := fileDigest(path, 16, 100); err == nil {
+		t.Error("Expected an error for a section past the end of the file")
+	}
+}
+
+func TestCheckObject(t *testing.T) {
+	data := []byte("id,name\n1,alice\n")
+	crc := crc64.Checksum(data, crcTable)
+	header := func(size int, crc string) http.Header {
+		h := http.Header{}
+		h.Set("Content-Length", strconv.Itoa(size))
+		if crc != "" {
+			h.Set("X-Oss-Hash-Crc64ecma", crc)
+		}
+		return h
+	}
+	crcValue := strconv.FormatUint(crc, 10)
+
+	tests := []struct {
+		name    string
+		header  http.Header
+		wantErr bool
+	}{
+		{"match", header(len(data), crcValue), false},
+		{"no crc returned", header(len(data), ""), false},
+		{"size", header(len(data)-1, crcValue), true},
+		{"crc", header(len(data), strconv.FormatUint(crc+1, 10)), true},
+		{"invalid crc", header(len(data), "abc"), true},
+	}
+	for _, tt := range tests {
+		err := checkObject(tt.header, int64(len(data)), crc)
+		if tt.wantErr && !errors.Is(err, ErrIntegrity) {
+			t.Errorf("%s: expected an integrity error, got %v", tt.name, err)
+		}
+		if !tt.wantErr && err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.name, err)
+		}
+	}
+}
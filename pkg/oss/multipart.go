@@ -0,0 +1,332 @@
+package oss
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/aliyun/aliyun-oss-go-sdk/oss"
+	"github.com/fluxo/export-middleware/pkg/logger"
+)
+
+// filePart is a byte range of a file uploaded as one part
+type filePart struct {
+	number int
+	offset int64
+	size   int64
+}
+
+// splitParts divides a file into parts of partSize bytes, the last one
+// holding the remainder
+func splitParts(fileSize int64, partSize int64) []filePart {
+	var parts []filePart
+	for offset := int64(0); offset < fileSize; offset += partSize {
+		size := partSize
+		if offset+size > fileSize {
+			size = fileSize - offset
+		}
+		parts = append(parts, filePart{number: len(parts) + 1, offset: offset, size: size})
+	}
+	return parts
+}
+
+// multiPartUpload uploads a file using multi-part upload. Up to
+// ParallelParts parts are sent at once, and a failed part is retried on
+// its own. Every uploaded part is recorded in the checkpoint, and parts it
+// already holds are not sent again.
+func (u *Uploader) multiPartUpload(ctx context.Context, localPath string, checkpoint *uploadCheckpoint, headers []oss.Option, progress *progressListener, contextLogger *logger.ContextLogger) error {
+	if checkpoint.UploadID != "" {
+		if err := u.resumeUpload(ctx, checkpoint, contextLogger); err != nil {
+			return err
+		}
+	}
+
+	// Initialize multi-part upload
+	if checkpoint.UploadID == "" {
+		var imur oss.InitiateMultipartUploadResult
+		err := u.retry(ctx, contextLogger, "initiate multi-part upload", func() error {
+			var err error
+			imur, err = u.bucket.InitiateMultipartUpload(checkpoint.ObjectKey, append([]oss.Option{oss.WithContext(ctx)}, headers...)...)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+		if err := checkpoint.start(imur.UploadID); err != nil {
+			contextLogger.LogWarn("OSSCheckpointError", "Failed to save upload checkpoint", logger.Fields{"error": err.Error()})
+		}
+	}
+
+	var pending []filePart
+	var resumedBytes int64
+	for _, part := range splitParts(checkpoint.FileSize, checkpoint.PartSize) {
+		if checkpoint.done(part.number) {
+			resumedBytes += part.size
+			continue
+		}
+		pending = append(pending, part)
+	}
+	progress.restart(resumedBytes)
+	if resumedBytes > 0 {
+		contextLogger.LogInfo("OSSUploadResumed", "Resuming multi-part upload", logger.Fields{
+			"upload_id":     checkpoint.UploadID,
+			"resumed_bytes": resumedBytes,
+			"pending_parts": len(pending),
+		})
+	}
+
+	imur := checkpoint.upload(u.config.Bucket)
+	if err := u.uploadParts(ctx, imur, localPath, pending, checkpoint, progress, contextLogger); err != nil {
+		return err
+	}
+
+	// Complete multi-part upload
+	parts := checkpoint.uploadedParts()
+	return u.retry(ctx, contextLogger, "complete multi-part upload", func() error {
+		_, err := u.bucket.CompleteMultipartUpload(imur, parts, oss.WithContext(ctx))
+		return err
+	})
+}
+
+// resumeUpload checks the parts recorded in the checkpoint against the
+// parts OSS holds for the upload. Parts OSS does not have are uploaded
+// again, and parts uploaded before a crash but missing from the checkpoint
+// are recorded. If the upload no longer exists, the checkpoint is reset
+// so a new upload is started.
+func (u *Uploader) resumeUpload(ctx context.Context, checkpoint *uploadCheckpoint, contextLogger *logger.ContextLogger) error {
+	imur := checkpoint.upload(u.config.Bucket)
+
+	var listed map[int]oss.UploadedPart
+	err := u.retry(ctx, contextLogger, "list uploaded parts", func() error {
+		var err error
+		listed, err = u.listUploadedParts(ctx, imur)
+		if isNoSuchUpload(err) {
+			listed = nil
+			return nil
+		}
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	if listed == nil {
+		contextLogger.LogWarn("OSSUploadRestarted", "Checkpointed upload no longer exists, starting over", logger.Fields{
+			"upload_id": checkpoint.UploadID,
+		})
+		checkpoint.reset()
+		return nil
+	}
+
+	checkpoint.retain(func(partNumber int, etag string) bool {
+		part, exists := listed[partNumber]
+		return exists && part.ETag == etag
+	})
+	for _, part := range splitParts(checkpoint.FileSize, checkpoint.PartSize) {
+		uploaded, exists := listed[part.number]
+		if !exists || int64(uploaded.Size) != part.size || checkpoint.done(part.number) {
+			continue
+		}
+		if err := checkpoint.complete(oss.UploadPart{PartNumber: part.number, ETag: uploaded.ETag}); err != nil {
+			contextLogger.LogWarn("OSSCheckpointError", "Failed to save upload checkpoint", logger.Fields{"error": err.Error()})
+		}
+	}
+	return nil
+}
+
+// listUploadedParts returns the parts OSS holds for an upload by number
+func (u *Uploader) listUploadedParts(ctx context.Context, imur oss.InitiateMultipartUploadResult) (map[int]oss.UploadedPart, error) {
+	parts := make(map[int]oss.UploadedPart)
+	marker := 0
+	for {
+		result, err := u.bucket.ListUploadedParts(imur, oss.PartNumberMarker(marker), oss.WithContext(ctx))
+		if err != nil {
+			return nil, err
+		}
+		for _, part := range result.UploadedParts {
+			parts[part.PartNumber] = part
+		}
+		if !result.IsTruncated {
+			return parts, nil
+		}
+		if marker, err = strconv.Atoi(result.NextPartNumberMarker); err != nil {
+			return nil, fmt.Errorf("invalid part number marker %q: %w", result.NextPartNumberMarker, err)
+		}
+	}
+}
+
+// isNoSuchUpload reports whether err says the multi-part upload does not
+// exist, because it was completed or aborted
+func isNoSuchUpload(err error) bool {
+	var serviceErr oss.ServiceError
+	return errors.As(err, &serviceErr) && serviceErr.Code == "NoSuchUpload"
+}
+
+// uploadParts uploads the parts with a pool of ParallelParts workers and
+// records each one in the checkpoint. The first part that fails after its
+// retries stops the remaining ones.
+func (u *Uploader) uploadParts(ctx context.Context, imur oss.InitiateMultipartUploadResult, localPath string, parts []filePart, checkpoint *uploadCheckpoint, progress *progressListener, contextLogger *logger.ContextLogger) error {
+	if len(parts) == 0 {
+		return nil
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	workers := u.config.ParallelParts
+	if workers < 1 {
+		workers = 1
+	}
+	if workers > len(parts) {
+		workers = len(parts)
+	}
+
+	var (
+		wg       sync.WaitGroup
+		failOnce sync.Once
+		failErr  error
+	)
+	jobs := make(chan filePart)
+
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for part := range jobs {
+				result, err := u.uploadPart(ctx, imur, localPath, part, progress, contextLogger)
+				if err != nil {
+					failOnce.Do(func() {
+						failErr = err
+						cancel()
+					})
+					continue
+				}
+				if err := checkpoint.complete(result); err != nil {
+					contextLogger.LogWarn("OSSCheckpointError", "Failed to save upload checkpoint", logger.Fields{"error": err.Error()})
+				}
+
+				contextLogger.LogDebug(
+					"OSSPartUploaded",
+					fmt.Sprintf("Uploaded part %d", part.number),
+					logger.Fields{
+						"part_number": part.number,
+						"part_size":   part.size,
+					},
+				)
+			}
+		}()
+	}
+
+feed:
+	for _, part := range parts {
+		select {
+		case jobs <- part:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	if failErr != nil {
+		return failErr
+	}
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("multi-part upload cancelled: %w", err)
+	}
+	return nil
+}
+
+// uploadPart uploads a single part, retrying it on failure. The part is
+// sent with its Content-MD5, so OSS rejects it if it arrives corrupted.
+func (u *Uploader) uploadPart(ctx context.Context, imur oss.InitiateMultipartUploadResult, localPath string, part filePart, progress *progressListener, contextLogger *logger.ContextLogger) (oss.UploadPart, error) {
+	var result oss.UploadPart
+	partMD5, _, err := fileDigest(localPath, part.offset, part.size)
+	if err != nil {
+		return result, fmt.Errorf("failed to checksum part %d: %w", part.number, err)
+	}
+
+	err = u.retry(ctx, contextLogger, fmt.Sprintf("upload of part %d", part.number), func() error {
+		attempt := progress.attempt()
+		var err error
+		options := append(attempt.options(ctx), oss.ContentMD5(partMD5))
+		result, err = u.bucket.UploadPartFromFile(imur, localPath, part.offset, part.size, part.number, options...)
+		if err != nil {
+			attempt.rewind()
+		}
+		return err
+	})
+	return result, err
+}
+
+// staleUploadInterval is how often unfinished multi-part uploads are
+// checked
+const staleUploadInterval = time.Hour
+
+// staleUploadLoop periodically aborts multi-part uploads left behind by
+// crashed runs
+func (u *Uploader) staleUploadLoop() {
+	ticker := time.NewTicker(staleUploadInterval)
+	defer ticker.Stop()
+
+	for {
+		u.abortStaleUploads()
+
+		select {
+		case <-ticker.C:
+		case <-u.stop:
+			return
+		}
+	}
+}
+
+// abortStaleUploads aborts the multi-part uploads below the fixed prefix of
+// the object key template that were initiated more than StaleUploadAge ago
+func (u *Uploader) abortStaleUploads() {
+	prefix := u.keys.prefix()
+	if prefix == "" {
+		return
+	}
+	contextLogger := u.logger.WithContext(context.Background()).WithComponent("oss_uploader")
+	cutoff := time.Now().Add(-u.config.StaleUploadAge)
+
+	keyMarker, uploadIDMarker := "", ""
+	for {
+		result, err := u.bucket.ListMultipartUploads(
+			oss.Prefix(prefix),
+			oss.KeyMarker(keyMarker),
+			oss.UploadIDMarker(uploadIDMarker),
+		)
+		if err != nil {
+			contextLogger.LogWarn("OSSStaleUploadError", "Failed to list multi-part uploads", logger.Fields{"error": err.Error()})
+			return
+		}
+
+		for _, upload := range result.Uploads {
+			if upload.Initiated.After(cutoff) {
+				continue
+			}
+			imur := oss.InitiateMultipartUploadResult{Bucket: u.config.Bucket, Key: upload.Key, UploadID: upload.UploadID}
+			if err := u.bucket.AbortMultipartUpload(imur); err != nil {
+				contextLogger.LogWarn("OSSStaleUploadError", "Failed to abort stale multi-part upload", logger.Fields{
+					"object_key": upload.Key,
+					"error":      err.Error(),
+				})
+				continue
+			}
+			contextLogger.LogInfo("OSSStaleUploadAborted", "Aborted stale multi-part upload", logger.Fields{
+				"object_key": upload.Key,
+				"upload_id":  upload.UploadID,
+				"initiated":  upload.Initiated,
+			})
+		}
+
+		if !result.IsTruncated {
+			return
+		}
+		keyMarker, uploadIDMarker = result.NextKeyMarker, result.NextUploadIDMarker
+	}
+}
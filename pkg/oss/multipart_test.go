@@ -0,0 +1,183 @@
+package oss
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aliyun/aliyun-oss-go-sdk/oss"
+	"github.com/fluxo/export-middleware/pkg/config"
+	"github.com/fluxo/export-middleware/pkg/logger"
+)
+
+func TestSplitParts(t *testing.T) {
+	parts := splitParts(25, 10)
+
+	want := []filePart{
+		{number: 1, offset: 0, size: 10},
+		{number: 2, offset: 10, size: 10},
+		{number: 3, offset: 20, size: 5},
+	}
+	if len(parts) != len(want) {
+		t.Fatalf("Expected %d parts, got %d: %v", len(want), len(parts), parts)
+	}
+	for i := range want {
+		if parts[i] != want[i] {
+			t.Errorf("Part %d: expected %+v, got %+v", i, want[i], parts[i])
+		}
+	}
+
+	if parts := splitParts(20, 10); len(parts) != 2 || parts[1].size != 10 {
+		t.Errorf("Expected two full parts, got %v", parts)
+	}
+}
+
+func TestAttemptProgress_RewindOnRetry(t *testing.T) {
+	var last int64
+	progress := &progressListener{total: 100, fn: func(uploaded int64, total int64) { last = uploaded }}
+
+	failed := progress.attempt()
+	failed.ProgressChanged(&oss.ProgressEvent{EventType: oss.TransferDataEvent, RwBytes: 30})
+	failed.rewind()
+	if last != 0 {
+		t.Errorf("Expected progress to be taken back, got %d", last)
+	}
+
+	retried := progress.attempt()
+	retried.ProgressChanged(&oss.ProgressEvent{EventType: oss.TransferDataEvent, RwBytes: 40})
+	if last != 40 {
+		t.Errorf("Expected 40 bytes uploaded, got %d", last)
+	}
+
+	// Progress is optional
+	var none *progressListener
+	none.attempt().rewind()
+	if options := none.attempt().options(context.Background()); len(options) != 1 {
+		t.Errorf("Expected only the context option, got %d", len(options))
+	}
+}
+
+// multipartBucket is an OSS bucket in memory holding one multi-part
+// upload at a time. failPart makes the next upload of that part fail with
+// a server error.
+type multipartBucket struct {
+	mu       sync.Mutex
+	parts    map[int][]byte
+	object   []byte
+	sent     []int // part numbers uploaded, in order
+	failPart int
+}
+
+func (b *multipartBucket) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	query := r.URL.Query()
+	switch {
+	case r.Method == http.MethodPost && query.Has("uploads"):
+		b.parts = make(map[int][]byte)
+		writeXML(w, oss.InitiateMultipartUploadResult{Bucket: "exports", UploadID: "upload-1"})
+	case r.Method == http.MethodPut && query.Has("partNumber"):
+		number, _ := strconv.Atoi(query.Get("partNumber"))
+		data, _ := io.ReadAll(r.Body)
+		b.sent = append(b.sent, number)
+		if number == b.failPart {
+			b.failPart = 0
+			w.WriteHeader(http.StatusInternalServerError)
+			io.WriteString(w, "<Error><Code>InternalError</Code></Error>")
+			return
+		}
+		b.parts[number] = data
+		w.Header().Set("ETag", fmt.Sprintf(`"part-%d"`, number))
+	case r.Method == http.MethodGet && query.Has("uploadId"):
+		result := oss.ListUploadedPartsResult{Bucket: "exports", UploadID: "upload-1"}
+		for number, data := range b.parts {
+			result.UploadedParts = append(result.UploadedParts, oss.UploadedPart{
+				PartNumber:   number,
+				LastModified: time.Now(),
+				ETag:         fmt.Sprintf(`"part-%d"`, number),
+				Size:         len(data),
+			})
+		}
+		writeXML(w, result)
+	case r.Method == http.MethodPost && query.Has("uploadId"):
+		b.object = nil
+		for number := 1; number <= len(b.parts); number++ {
+			b.object = append(b.object, b.parts[number]...)
+		}
+		writeXML(w, oss.CompleteMultipartUploadResult{Bucket: "exports"})
+	case r.Method == http.MethodHead:
+		w.Header().Set("Content-Length", strconv.Itoa(len(b.object)))
+	case r.Method == http.MethodDelete:
+		b.parts = nil
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func writeXML(w http.ResponseWriter, v any) {
+	data, _ := xml.Marshal(v)
+	w.Header().Set("Content-Type", "application/xml")
+	w.Write(data)
+}
+
+func TestUpload_ResumesAfterFailedPart(t *testing.T) {
+	log, err := logger.New("error", "json", "stderr", false)
+	if err != nil {
+		t.Fatalf("Failed to create logger: %v", err)
+	}
+	bucket := &multipartBucket{failPart: 3}
+	server := httptest.NewServer(bucket)
+	defer server.Close()
+
+	cfg := config.DefaultConfig().OSS
+	cfg.Endpoint, cfg.Bucket = server.URL, "exports"
+	cfg.AccessKeyID, cfg.AccessKeySecret = "id", "secret"
+	cfg.PartSize, cfg.ParallelParts, cfg.MaxRetries = 10, 1, 0
+	cfg.StaleUploadAge = 0
+	u, err := NewUploader(&cfg, log)
+	if err != nil {
+		t.Fatalf("Failed to create uploader: %v", err)
+	}
+	defer u.Close()
+
+	content := []byte("0123456789abcdefghijABCDEFGHIJ-tail")
+	localPath := filepath.Join(t.TempDir(), "export.csv")
+	if err := os.WriteFile(localPath, content, 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	info := &ObjectInfo{TaskID: "task-1", Filename: "export", Extension: "csv"}
+
+	if _, err := u.Upload(context.Background(), info, localPath, nil); err == nil {
+		t.Fatal("Expected the first upload to fail")
+	}
+	if checkpoint, _ := loadCheckpoint(localPath); checkpoint == nil || checkpoint.UploadID != "upload-1" {
+		t.Fatalf("Expected the checkpoint of the failed upload to be kept, got %+v", checkpoint)
+	}
+
+	bucket.sent = nil
+	if _, err := u.Upload(context.Background(), info, localPath, nil); err != nil {
+		t.Fatalf("Expected the resumed upload to succeed: %v", err)
+	}
+	if !slices.Equal(bucket.sent, []int{3, 4}) {
+		t.Errorf("Expected only parts 3 and 4 to be sent again, got %v", bucket.sent)
+	}
+	if !bytes.Equal(bucket.object, content) {
+		t.Errorf("Expected the stored object to match the file, got %q", bucket.object)
+	}
+	if checkpoint, _ := loadCheckpoint(localPath); checkpoint != nil {
+		t.Error("Expected the checkpoint to be removed after the upload")
+	}
+}
@@ -0,0 +1,130 @@
+package oss
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// ObjectInfo describes the export an object is uploaded for. It fills the
+// object key template and names the file users download.
+type ObjectInfo struct {
+	TaskID    string
+	RequestID string
+	ClientID  string
+	Filename  string // Filename requested by the client
+	Extension string // Extension of the export format, without the dot
+
+	// Known once the file is finalized, which is after a streamed upload
+	// has started. Empty Checksum means not known yet.
+	RecordCount int64
+	Checksum    string // SHA-256 of the file, hex encoded
+
+	DataKey []byte // Client-side encryption key, nil to upload the file as is
+}
+
+// DownloadName returns the filename users download: the requested
+// filename, with the format's extension added if it is missing
+func (o *ObjectInfo) DownloadName() string {
+	name := filepath.Base(strings.ReplaceAll(o.Filename, "\\", "/"))
+	if name == "." || name == "/" {
+		name = o.TaskID
+	}
+	if o.Extension != "" && !strings.EqualFold(filepath.Ext(name), "."+o.Extension) {
+		name += "." + o.Extension
+	}
+	return name
+}
+
+// placeholderPattern matches a placeholder in an object key template
+var placeholderPattern = regexp.MustCompile(`\{([a-z_]+)\}`)
+
+// keyPlaceholders are the placeholders object key templates may use
+var keyPlaceholders = map[string]bool{
+	"client_id":  true,
+	"request_id": true,
+	"task_id":    true,
+	"yyyy":       true,
+	"mm":         true,
+	"dd":         true,
+	"ext":        true,
+	"filename":   true, // Download name, including the extension
+	"basename":   true, // Download name without the extension
+}
+
+// keyTemplate renders object keys from a template such as
+// "exports/{yyyy}/{mm}/{dd}/{task_id}/{filename}"
+type keyTemplate struct {
+	template string
+}
+
+// parseKeyTemplate checks an object key template. Keys must contain
+// {task_id} so that exports never overwrite each other.
+func parseKeyTemplate(template string) (*keyTemplate, error) {
+	if strings.HasPrefix(template, "/") {
+		return nil, fmt.Errorf("object key template must not start with /")
+	}
+	if err := checkPlaceholders(template); err != nil {
+		return nil, fmt.Errorf("object key template: %w", err)
+	}
+	if !strings.Contains(template, "{task_id}") {
+		return nil, fmt.Errorf("object key template must contain {task_id}")
+	}
+	return &keyTemplate{template: template}, nil
+}
+
+// checkPlaceholders rejects unknown placeholders in a template
+func checkPlaceholders(template string) error {
+	for _, match := range placeholderPattern.FindAllStringSubmatch(template, -1) {
+		if !keyPlaceholders[match[1]] {
+			return fmt.Errorf("unknown placeholder %s", match[0])
+		}
+	}
+	return nil
+}
+
+// expandPlaceholders replaces the placeholders in a template with the
+// values of an export, passed through escape
+func expandPlaceholders(template string, info *ObjectInfo, now time.Time, escape func(string) string) string {
+	filename := info.DownloadName()
+	values := map[string]string{
+		"client_id":  info.ClientID,
+		"request_id": info.RequestID,
+		"task_id":    info.TaskID,
+		"yyyy":       now.Format("2006"),
+		"mm":         now.Format("01"),
+		"dd":         now.Format("02"),
+		"ext":        info.Extension,
+		"filename":   filename,
+		"basename":   strings.TrimSuffix(filename, filepath.Ext(filename)),
+	}
+
+	return placeholderPattern.ReplaceAllStringFunc(template, func(placeholder string) string {
+		return escape(values[placeholder[1:len(placeholder)-1]])
+	})
+}
+
+// render returns the object key for an export
+func (t *keyTemplate) render(info *ObjectInfo, now time.Time) string {
+	return expandPlaceholders(t.template, info, now, keySegment)
+}
+
+// prefix returns the fixed part of the keys, before the first placeholder
+func (t *keyTemplate) prefix() string {
+	if loc := placeholderPattern.FindStringIndex(t.template); loc != nil {
+		return t.template[:loc[0]]
+	}
+	return t.template
+}
+
+// keySegment makes a value safe to use in an object key: it cannot add
+// path levels or be empty
+func keySegment(value string) string {
+	value = strings.NewReplacer("/", "_", "\\", "_").Replace(strings.TrimSpace(value))
+	if value == "" || value == "." || value == ".." {
+		return "_"
+	}
+	return value
+}
@@ -0,0 +1,93 @@
+package oss
+
+import (
+	"testing"
+	"time"
+
+	"github.com/fluxo/export-middleware/pkg/config"
+)
+
+func TestKeyTemplate_Render(t *testing.T) {
+	now := time.Date(2024, 3, 7, 12, 0, 0, 0, time.UTC)
+	info := &ObjectInfo{
+		TaskID:    "task-1",
+		RequestID: "req/1",
+		ClientID:  "",
+		Filename:  "订单报表",
+		Extension: "csv",
+	}
+
+	tests := []struct {
+		template string
+		want     string
+	}{
+		{"exports/{yyyy}/{mm}/{dd}/{task_id}/{filename}", "exports/2024/03/07/task-1/订单报表.csv"},
+		{"{client_id}/{request_id}/{task_id}.{ext}", "_/req_1/task-1.csv"},
+		{"tenants/{basename}-{task_id}", "tenants/订单报表-task-1"},
+	}
+	for _, tt := range tests {
+		keys, err := parseKeyTemplate(tt.template)
+		if err != nil {
+			t.Fatalf("Failed to parse %q: %v", tt.template, err)
+		}
+		if got := keys.render(info, now); got != tt.want {
+			t.Errorf("Template %q: expected %q, got %q", tt.template, tt.want, got)
+		}
+	}
+}
+
+func TestKeyTemplate_Invalid(t *testing.T) {
+	for _, template := range []string{
+		"exports/{date}/{task_id}",
+		"exports/{filename}",
+		"/exports/{task_id}",
+	} {
+		if _, err := parseKeyTemplate(template); err == nil {
+			t.Errorf("Expected %q to be rejected", template)
+		}
+	}
+}
+
+func TestKeyTemplate_Prefix(t *testing.T) {
+	keys, _ := parseKeyTemplate("exports/{yyyy}/{task_id}")
+	if prefix := keys.prefix(); prefix != "exports/" {
+		t.Errorf("Expected prefix exports/, got %q", prefix)
+	}
+}
+
+func TestNewUploader_StaleCleanupNeedsPrefix(t *testing.T) {
+	cfg := config.DefaultConfig().OSS
+	cfg.Endpoint, cfg.Bucket = "oss-cn-hangzhou.aliyuncs.com", "exports"
+	cfg.AccessKeyID, cfg.AccessKeySecret = "id", "secret"
+	cfg.ObjectKeyTemplate = "{client_id}/{task_id}/{filename}"
+
+	if _, err := NewUploader(&cfg, nil); err == nil {
+		t.Error("Expected stale upload cleanup over the whole bucket to be rejected")
+	}
+
+	cfg.StaleUploadAge = 0
+	u, err := NewUploader(&cfg, nil)
+	if err != nil {
+		t.Fatalf("Expected template without prefix to be accepted without stale cleanup, got %v", err)
+	}
+	u.Close()
+}
+
+func TestObjectInfo_DownloadName(t *testing.T) {
+	tests := []struct {
+		filename string
+		want     string
+	}{
+		{"report.csv", "report.csv"},
+		{"report.CSV", "report.CSV"},
+		{"report", "report.csv"},
+		{"../../etc/report", "report.csv"},
+		{"", "task-1.csv"},
+	}
+	for _, tt := range tests {
+		info := &ObjectInfo{TaskID: "task-1", Filename: tt.filename, Extension: "csv"}
+		if got := info.DownloadName(); got != tt.want {
+			t.Errorf("Filename %q: expected %q, got %q", tt.filename, tt.want, got)
+		}
+	}
+}
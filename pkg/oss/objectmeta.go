@@ -0,0 +1,135 @@
+package oss
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aliyun/aliyun-oss-go-sdk/oss"
+	"github.com/fluxo/export-middleware/pkg/encryption"
+)
+
+// maxObjectTags is the number of tags OSS allows per object
+const maxObjectTags = 10
+
+// contentTypes maps export extensions to the Content-Type of their objects
+var contentTypes = map[string]string{
+	"csv":  "text/csv; charset=utf-8",
+	"xlsx": "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+}
+
+// contentType returns the Content-Type of an export's object
+func contentType(extension string) string {
+	if ct, ok := contentTypes[strings.ToLower(extension)]; ok {
+		return ct
+	}
+	return "application/octet-stream"
+}
+
+// objectTags renders the tags set on uploaded objects from tag templates,
+// whose values may use the object key placeholders
+type objectTags struct {
+	templates map[string]string
+	keys      []string // sorted, so tags are sent in a stable order
+}
+
+// parseObjectTags checks the configured object tags
+func parseObjectTags(templates map[string]string) (*objectTags, error) {
+	if len(templates) > maxObjectTags {
+		return nil, fmt.Errorf("at most %d object tags are allowed, got %d", maxObjectTags, len(templates))
+	}
+	tags := &objectTags{templates: templates}
+	for key, value := range templates {
+		if strings.TrimSpace(key) == "" {
+			return nil, fmt.Errorf("object tag keys cannot be empty")
+		}
+		if err := checkPlaceholders(value); err != nil {
+			return nil, fmt.Errorf("object tag %s: %w", key, err)
+		}
+		tags.keys = append(tags.keys, key)
+	}
+	sort.Strings(tags.keys)
+	return tags, nil
+}
+
+// render returns the tags of an export's object, or nil if no tags are
+// configured
+func (t *objectTags) render(info *ObjectInfo, now time.Time) []oss.Tag {
+	if t == nil || len(t.keys) == 0 {
+		return nil
+	}
+	tags := make([]oss.Tag, 0, len(t.keys))
+	for _, key := range t.keys {
+		tags = append(tags, oss.Tag{
+			Key:   key,
+			Value: expandPlaceholders(t.templates[key], info, now, strings.TrimSpace),
+		})
+	}
+	return tags
+}
+
+// objectMeta returns the x-oss-meta headers of an export's object. The
+// record count and checksum are only included once the file is finalized;
+// for encrypted files they describe the plaintext.
+func objectMeta(info *ObjectInfo) map[string]string {
+	meta := map[string]string{"task-id": info.TaskID}
+	if info.RequestID != "" {
+		meta["request-id"] = info.RequestID
+	}
+	if info.ClientID != "" {
+		meta["client-id"] = info.ClientID
+	}
+	if info.DataKey != nil {
+		meta["client-encryption"] = encryption.Algorithm
+	}
+	if info.Checksum != "" {
+		meta["record-count"] = strconv.FormatInt(info.RecordCount, 10)
+		meta["sha256"] = info.Checksum
+	}
+	return meta
+}
+
+// contentDisposition returns a Content-Disposition header that makes
+// browsers download the object under filename. Non-ASCII filenames are
+// sent as an RFC 5987 filename*, with an ASCII filename for old clients.
+func contentDisposition(filename string) string {
+	fallback := strings.Map(func(r rune) rune {
+		if r < 0x20 || r > 0x7e || r == '"' || r == '\\' {
+			return '_'
+		}
+		return r
+	}, filename)
+	return fmt.Sprintf(`attachment; filename="%s"; filename*=UTF-8''%s`, fallback, percentEncode(filename, isAttrChar))
+}
+
+// metaValue makes a value safe to send as an x-oss-meta header by
+// percent-encoding non-ASCII bytes
+func metaValue(value string) string {
+	return percentEncode(value, func(c byte) bool {
+		return c >= 0x20 && c <= 0x7e && c != '%'
+	})
+}
+
+// isAttrChar reports whether c may appear unencoded in an RFC 5987 value
+func isAttrChar(c byte) bool {
+	switch {
+	case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9':
+		return true
+	}
+	return strings.IndexByte("!#$&+-.^_`|~", c) >= 0
+}
+
+// percentEncode encodes the bytes of s for which keep returns false
+func percentEncode(s string, keep func(byte) bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if c := s[i]; keep(c) {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,57 @@
+package oss
+
+import (
+	"testing"
+	"time"
+)
+
+func TestContentDisposition(t *testing.T) {
+	tests := []struct {
+		filename string
+		want     string
+	}{
+		{"report.csv", `attachment; filename="report.csv"; filename*=UTF-8''report.csv`},
+		{"订单.csv", `attachment; filename="__.csv"; filename*=UTF-8''%E8%AE%A2%E5%8D%95.csv`},
+		{`a "b" c.csv`, `attachment; filename="a _b_ c.csv"; filename*=UTF-8''a%20%22b%22%20c.csv`},
+	}
+	for _, tt := range tests {
+		if got := contentDisposition(tt.filename); got != tt.want {
+			t.Errorf("Filename %q: expected %s, got %s", tt.filename, tt.want, got)
+		}
+	}
+}
+
+func TestObjectMeta(t *testing.T) {
+	info := &ObjectInfo{TaskID: "task-1", RequestID: "请求-1"}
+	meta := objectMeta(info)
+	if _, ok := meta["sha256"]; ok {
+		t.Error("Expected no checksum before the file is finalized")
+	}
+	if got := metaValue(meta["request-id"]); got != "%E8%AF%B7%E6%B1%82-1" {
+		t.Errorf("Expected percent-encoded request ID, got %q", got)
+	}
+
+	info.Checksum, info.RecordCount = "abc", 0
+	meta = objectMeta(info)
+	if meta["sha256"] != "abc" || meta["record-count"] != "0" {
+		t.Errorf("Expected checksum and record count, got %v", meta)
+	}
+}
+
+func TestObjectTags(t *testing.T) {
+	tags, err := parseObjectTags(map[string]string{"retention": "30d", "tenant": "{client_id}"})
+	if err != nil {
+		t.Fatalf("Failed to parse tags: %v", err)
+	}
+	rendered := tags.render(&ObjectInfo{TaskID: "task-1", ClientID: "acme"}, time.Now())
+	if len(rendered) != 2 || rendered[0].Key != "retention" || rendered[0].Value != "30d" || rendered[1].Value != "acme" {
+		t.Errorf("Unexpected tags: %v", rendered)
+	}
+
+	if _, err := parseObjectTags(map[string]string{"tenant": "{tenant}"}); err == nil {
+		t.Error("Expected unknown placeholder to be rejected")
+	}
+	if tags, _ := parseObjectTags(nil); tags.render(&ObjectInfo{}, time.Now()) != nil {
+		t.Error("Expected no tags when none are configured")
+	}
+}
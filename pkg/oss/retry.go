@@ -0,0 +1,167 @@
+package oss
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"math/rand/v2"
+	"net/http"
+	"time"
+
+	"github.com/aliyun/aliyun-oss-go-sdk/oss"
+	"github.com/fluxo/export-middleware/pkg/logger"
+)
+
+// Task error codes of failed uploads
+const (
+	ErrorCodeUpload       = "UPLOAD_ERROR"       // network errors and other failures
+	ErrorCodeCancelled    = "UPLOAD_CANCELLED"   // stopped, e.g. on shutdown
+	ErrorCodeTimeout      = "UPLOAD_TIMEOUT"     // oss.upload_timeout passed
+	ErrorCodeIntegrity    = "INTEGRITY_ERROR"    // stored bytes do not match the file
+	ErrorCodeAccessDenied = "OSS_ACCESS_DENIED"  // credentials or permissions
+	ErrorCodeNoSuchBucket = "OSS_NO_SUCH_BUCKET" // bucket does not exist
+	ErrorCodeUnavailable  = "OSS_UNAVAILABLE"    // throttling or server errors
+	ErrorCodeRejected     = "OSS_REJECTED"       // other client errors
+	ErrorCodeLocalFile    = "STORAGE_ERROR"      // the local file cannot be read
+	ErrorCodeEncryption   = "ENCRYPTION_ERROR"   // client-side encryption failed
+)
+
+// ErrEncryption is returned when a file cannot be encrypted for upload
+var ErrEncryption = errors.New("client-side encryption failed")
+
+// exhaustedError is the last failure of an operation that used up its
+// retries. Enclosing operations do not retry it again.
+type exhaustedError struct {
+	error
+}
+
+func (e exhaustedError) Unwrap() error {
+	return e.error
+}
+
+// classify returns the task error code of an upload failure and whether
+// the failed request is worth retrying
+func classify(err error) (string, bool) {
+	code, retryable := classifyCause(err)
+	if errors.As(err, &exhaustedError{}) {
+		retryable = false
+	}
+	return code, retryable
+}
+
+// classifyCause classifies an upload failure by its cause
+func classifyCause(err error) (string, bool) {
+	switch {
+	case errors.Is(err, context.Canceled):
+		return ErrorCodeCancelled, false
+	case errors.Is(err, context.DeadlineExceeded):
+		return ErrorCodeTimeout, false
+	case errors.Is(err, ErrEncryption):
+		return ErrorCodeEncryption, false
+	case errors.Is(err, ErrIntegrity):
+		// Retried by uploading the whole object again, not the request
+		return ErrorCodeIntegrity, false
+	case errors.As(err, &oss.CRCCheckError{}):
+		return ErrorCodeIntegrity, true
+	}
+
+	var pathErr *fs.PathError
+	if errors.As(err, &pathErr) {
+		return ErrorCodeLocalFile, false
+	}
+
+	var serviceErr oss.ServiceError
+	if errors.As(err, &serviceErr) {
+		switch serviceErr.Code {
+		case "AccessDenied", "InvalidAccessKeyId", "SignatureDoesNotMatch", "InvalidSecurityToken", "SecurityTokenExpired":
+			return ErrorCodeAccessDenied, false
+		case "NoSuchBucket":
+			return ErrorCodeNoSuchBucket, false
+		case "InvalidDigest", "BadDigest":
+			// Content-MD5 mismatch: the bytes were corrupted in transit
+			return ErrorCodeIntegrity, true
+		case "RequestTimeout":
+			return ErrorCodeUnavailable, true
+		}
+		return classifyStatus(serviceErr.StatusCode)
+	}
+
+	var statusErr oss.UnexpectedStatusCodeError
+	if errors.As(err, &statusErr) {
+		return classifyStatus(statusErr.Got())
+	}
+
+	// Network errors and anything unknown
+	return ErrorCodeUpload, true
+}
+
+// classifyStatus classifies a failed request by its HTTP status
+func classifyStatus(status int) (string, bool) {
+	switch {
+	case status == http.StatusForbidden:
+		return ErrorCodeAccessDenied, false
+	case status == http.StatusTooManyRequests || status >= 500:
+		return ErrorCodeUnavailable, true
+	case status >= 400:
+		return ErrorCodeRejected, false
+	}
+	return ErrorCodeUpload, true
+}
+
+// ErrorCode returns the task error code of an upload failure, e.g.
+// OSS_ACCESS_DENIED or INTEGRITY_ERROR
+func ErrorCode(err error) string {
+	code, _ := classify(err)
+	return code
+}
+
+// retry runs fn until it succeeds, up to MaxRetries+1 times, waiting with
+// exponential backoff and jitter between attempts. Permanent failures,
+// such as denied access or a missing bucket, are not retried, and it gives
+// up as soon as ctx is done. Once its attempts are used up, the failure is
+// permanent, so retries never multiply when operations are nested.
+func (u *Uploader) retry(ctx context.Context, contextLogger *logger.ContextLogger, operation string, fn func() error) error {
+	var lastErr error
+	for attempt := 0; attempt <= u.config.MaxRetries; attempt++ {
+		if attempt > 0 {
+			waitTime := u.backoff(attempt)
+			contextLogger.LogWarn(
+				"OSSUploadRetry",
+				fmt.Sprintf("Retrying %s (attempt %d/%d)", operation, attempt+1, u.config.MaxRetries+1),
+				logger.Fields{"wait_time": waitTime.String(), "error": lastErr.Error(), "error_code": ErrorCode(lastErr)},
+			)
+			timer := time.NewTimer(waitTime)
+			select {
+			case <-timer.C:
+			case <-ctx.Done():
+				timer.Stop()
+			}
+		}
+
+		// Cancellation aborts the operation instead of retrying it
+		if err := ctx.Err(); err != nil {
+			return fmt.Errorf("%s cancelled: %w", operation, err)
+		}
+
+		lastErr = fn()
+		if lastErr == nil {
+			return nil
+		}
+		if _, retryable := classify(lastErr); !retryable {
+			return fmt.Errorf("%s failed: %w", operation, lastErr)
+		}
+	}
+	return exhaustedError{fmt.Errorf("%s failed after %d attempts: %w", operation, u.config.MaxRetries+1, lastErr)}
+}
+
+// backoff returns the wait time before a retry attempt: initial_backoff
+// doubled on each attempt up to max_backoff, of which a random half is
+// taken off so parts failing together are not retried together
+func (u *Uploader) backoff(attempt int) time.Duration {
+	wait := u.config.InitialBackoff << uint(attempt-1)
+	if wait <= 0 || wait > u.config.MaxBackoff {
+		wait = u.config.MaxBackoff
+	}
+	return wait/2 + rand.N(wait/2+1)
+}
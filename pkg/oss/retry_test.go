@@ -0,0 +1,113 @@
+package oss
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/aliyun/aliyun-oss-go-sdk/oss"
+	"github.com/fluxo/export-middleware/pkg/config"
+	"github.com/fluxo/export-middleware/pkg/logger"
+)
+
+func TestClassify(t *testing.T) {
+	tests := []struct {
+		name      string
+		err       error
+		code      string
+		retryable bool
+	}{
+		{"network", errors.New("connection reset by peer"), ErrorCodeUpload, true},
+		{"access denied", oss.ServiceError{Code: "AccessDenied", StatusCode: http.StatusForbidden}, ErrorCodeAccessDenied, false},
+		{"no such bucket", fmt.Errorf("upload failed: %w", oss.ServiceError{Code: "NoSuchBucket", StatusCode: http.StatusNotFound}), ErrorCodeNoSuchBucket, false},
+		{"bad digest", oss.ServiceError{Code: "InvalidDigest", StatusCode: http.StatusBadRequest}, ErrorCodeIntegrity, true},
+		{"throttled", oss.ServiceError{Code: "Throttling", StatusCode: http.StatusTooManyRequests}, ErrorCodeUnavailable, true},
+		{"server error", oss.ServiceError{Code: "InternalError", StatusCode: http.StatusInternalServerError}, ErrorCodeUnavailable, true},
+		{"rejected", oss.ServiceError{Code: "EntityTooLarge", StatusCode: http.StatusBadRequest}, ErrorCodeRejected, false},
+		{"crc", oss.CRCCheckError{}, ErrorCodeIntegrity, true},
+		{"mismatch", fmt.Errorf("%w: size", ErrIntegrity), ErrorCodeIntegrity, false},
+		{"encryption", fmt.Errorf("%w: invalid key size", ErrEncryption), ErrorCodeEncryption, false},
+		{"local file", &fs.PathError{Op: "open", Path: "export.csv", Err: fs.ErrNotExist}, ErrorCodeLocalFile, false},
+		{"cancelled", fmt.Errorf("upload cancelled: %w", context.Canceled), ErrorCodeCancelled, false},
+		{"timeout", fmt.Errorf("upload cancelled: %w", context.DeadlineExceeded), ErrorCodeTimeout, false},
+	}
+	for _, tt := range tests {
+		code, retryable := classify(tt.err)
+		if code != tt.code || retryable != tt.retryable {
+			t.Errorf("%s: expected %s (retryable %v), got %s (retryable %v)", tt.name, tt.code, tt.retryable, code, retryable)
+		}
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	u := &Uploader{config: &config.OSSConfig{InitialBackoff: time.Second, MaxBackoff: 5 * time.Second}}
+
+	for attempt, ceiling := range map[int]time.Duration{1: time.Second, 2: 2 * time.Second, 3: 4 * time.Second, 4: 5 * time.Second, 40: 5 * time.Second} {
+		for range 20 {
+			wait := u.backoff(attempt)
+			if wait < ceiling/2 || wait > ceiling {
+				t.Fatalf("Attempt %d: expected a wait between %v and %v, got %v", attempt, ceiling/2, ceiling, wait)
+			}
+		}
+	}
+}
+
+func TestRetryStopsOnPermanentError(t *testing.T) {
+	log, err := logger.New("error", "json", "stderr", false)
+	if err != nil {
+		t.Fatalf("Failed to create logger: %v", err)
+	}
+	u := &Uploader{
+		config: &config.OSSConfig{MaxRetries: 3, InitialBackoff: time.Millisecond, MaxBackoff: time.Millisecond},
+		logger: log,
+	}
+	contextLogger := log.WithContext(context.Background())
+
+	calls := 0
+	err = u.retry(context.Background(), contextLogger, "upload", func() error {
+		calls++
+		return oss.ServiceError{Code: "AccessDenied", StatusCode: http.StatusForbidden}
+	})
+	if calls != 1 || ErrorCode(err) != ErrorCodeAccessDenied {
+		t.Errorf("Expected one attempt failing with %s, got %d attempts and %v", ErrorCodeAccessDenied, calls, err)
+	}
+
+	calls = 0
+	err = u.retry(context.Background(), contextLogger, "upload", func() error {
+		calls++
+		if calls < 3 {
+			return oss.ServiceError{Code: "InternalError", StatusCode: http.StatusInternalServerError}
+		}
+		return nil
+	})
+	if err != nil || calls != 3 {
+		t.Errorf("Expected success on the third attempt, got %d attempts and %v", calls, err)
+	}
+
+	// A nested operation that used up its attempts is not retried again
+	calls = 0
+	err = u.retry(context.Background(), contextLogger, "multi-part upload", func() error {
+		return u.retry(context.Background(), contextLogger, "upload of part 1", func() error {
+			calls++
+			return errors.New("connection reset by peer")
+		})
+	})
+	if calls != 4 || ErrorCode(err) != ErrorCodeUpload {
+		t.Errorf("Expected 4 attempts failing with %s, got %d attempts and %v", ErrorCodeUpload, calls, err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	calls = 0
+	err = u.retry(ctx, contextLogger, "upload", func() error {
+		calls++
+		return nil
+	})
+	if calls != 0 || ErrorCode(err) != ErrorCodeCancelled {
+		t.Errorf("Expected a cancelled context to stop the retry, got %d attempts and %v", calls, err)
+	}
+}
@@ -0,0 +1,403 @@
+package oss
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"hash/crc64"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/aliyun/aliyun-oss-go-sdk/oss"
+	"github.com/fluxo/export-middleware/pkg/encryption"
+	"github.com/fluxo/export-middleware/pkg/logger"
+)
+
+// Stream uploads a file while it is being written. Written bytes are
+// collected until a full part is available, which is then uploaded in the
+// background as part of a multi-part upload, up to ParallelParts at a
+// time. Only the parts in flight are held in memory. A file smaller than
+// one part is uploaded in a single request on Close.
+type Stream struct {
+	uploader  *Uploader
+	ctx       context.Context
+	cancel    context.CancelFunc
+	logger    *logger.ContextLogger
+	objectKey string
+	info      ObjectInfo
+	startTime time.Time
+
+	sealer   *encryption.Writer // encrypts writes into parts, nil without a data key
+	buf      []byte             // part being filled
+	nextPart int
+	written  atomic.Int64
+	crc      uint64 // CRC64 of the bytes written, after encryption
+
+	slots chan struct{} // one token per part in flight
+	wg    sync.WaitGroup
+
+	mu    sync.Mutex
+	imur  *oss.InitiateMultipartUploadResult // nil until the first part is full
+	parts []oss.UploadPart
+	err   error // first failure
+}
+
+// NewStream starts a streaming upload of the file of an export. The upload
+// stops when ctx is cancelled. The stream lives as long as the client
+// sends data, so UploadTimeout applies to each part and to Close rather
+// than to the whole stream.
+func (u *Uploader) NewStream(ctx context.Context, info *ObjectInfo) (*Stream, error) {
+	ctx, cancel := context.WithCancel(ctx)
+
+	s := &Stream{
+		uploader:  u,
+		ctx:       ctx,
+		cancel:    cancel,
+		logger:    u.logger.WithContext(ctx).WithTaskID(info.TaskID).WithComponent("oss_uploader"),
+		objectKey: u.generateObjectKey(info),
+		info:      *info,
+		startTime: time.Now(),
+		buf:       make([]byte, 0, u.config.PartSize),
+		slots:     make(chan struct{}, max(u.config.ParallelParts, 1)),
+	}
+
+	if info.DataKey != nil {
+		sealer, err := encryption.NewWriter(streamParts{s}, info.DataKey)
+		if err != nil {
+			cancel()
+			return nil, fmt.Errorf("%w: %w", ErrEncryption, err)
+		}
+		s.sealer = sealer
+	}
+	return s, nil
+}
+
+// streamParts writes to the parts of a stream, bypassing its encryption
+type streamParts struct {
+	s *Stream
+}
+
+func (p streamParts) Write(b []byte) (int, error) {
+	return p.s.writeParts(b)
+}
+
+// Write implements io.Writer. It blocks while ParallelParts parts are in
+// flight, and fails once a part could not be uploaded.
+func (s *Stream) Write(p []byte) (int, error) {
+	if s.sealer != nil {
+		return s.sealer.Write(p)
+	}
+	return s.writeParts(p)
+}
+
+// writeParts adds bytes to the part being filled, sending it once full
+func (s *Stream) writeParts(p []byte) (int, error) {
+	if err := s.failure(); err != nil {
+		return 0, err
+	}
+
+	written := 0
+	for written < len(p) {
+		n := min(cap(s.buf)-len(s.buf), len(p)-written)
+		s.buf = append(s.buf, p[written:written+n]...)
+		s.crc = crc64.Update(s.crc, crcTable, p[written:written+n])
+		written += n
+		s.written.Add(int64(n))
+
+		if len(s.buf) == cap(s.buf) {
+			if err := s.sendPart(); err != nil {
+				return written, err
+			}
+		}
+	}
+	return written, nil
+}
+
+// Written returns the number of bytes written to the stream, after
+// encryption
+func (s *Stream) Written() int64 {
+	return s.written.Load()
+}
+
+// ObjectKey returns the key the file is uploaded to
+func (s *Stream) ObjectKey() string {
+	return s.objectKey
+}
+
+// sendPart uploads the filled buffer as the next part in the background
+func (s *Stream) sendPart() error {
+	imur, err := s.upload()
+	if err != nil {
+		return err
+	}
+
+	select {
+	case s.slots <- struct{}{}:
+	case <-s.ctx.Done():
+		return s.fail(fmt.Errorf("streaming upload cancelled: %w", s.ctx.Err()))
+	}
+
+	s.nextPart++
+	number, data := s.nextPart, s.buf
+	s.buf = make([]byte, 0, s.uploader.config.PartSize)
+
+	s.wg.Add(1)
+	go func() {
+		defer func() {
+			<-s.slots
+			s.wg.Done()
+		}()
+
+		ctx, cancel := s.requestContext()
+		defer cancel()
+
+		var result oss.UploadPart
+		partMD5 := contentMD5(data)
+		err := s.uploader.retry(ctx, s.logger, fmt.Sprintf("upload of part %d", number), func() error {
+			var err error
+			result, err = s.uploader.bucket.UploadPart(imur, bytes.NewReader(data), int64(len(data)), number, oss.WithContext(ctx), oss.ContentMD5(partMD5))
+			return err
+		})
+		if err != nil {
+			s.fail(err)
+			return
+		}
+
+		s.mu.Lock()
+		s.parts = append(s.parts, result)
+		s.mu.Unlock()
+
+		s.logger.LogDebug(
+			"OSSPartUploaded",
+			fmt.Sprintf("Uploaded part %d while streaming", number),
+			logger.Fields{
+				"part_number": number,
+				"part_size":   len(data),
+			},
+		)
+	}()
+	return nil
+}
+
+// upload returns the multi-part upload, initiating it on first use
+func (s *Stream) upload() (oss.InitiateMultipartUploadResult, error) {
+	s.mu.Lock()
+	imur := s.imur
+	s.mu.Unlock()
+	if imur != nil {
+		return *imur, nil
+	}
+
+	ctx, cancel := s.requestContext()
+	defer cancel()
+
+	var result oss.InitiateMultipartUploadResult
+	err := s.uploader.retry(ctx, s.logger, "initiate multi-part upload", func() error {
+		var err error
+		result, err = s.uploader.bucket.InitiateMultipartUpload(s.objectKey, append([]oss.Option{oss.WithContext(ctx)}, s.uploader.objectOptions(&s.info)...)...)
+		return err
+	})
+	if err != nil {
+		return result, s.fail(err)
+	}
+
+	s.mu.Lock()
+	s.imur = &result
+	s.mu.Unlock()
+	return result, nil
+}
+
+// Close uploads the rest of the file, completes the upload and returns its
+// result. The checksum and record count of the finalized file are stored
+// with the object. The stream must not be written to afterwards.
+func (s *Stream) Close(checksum string, recordCount int64) (*UploadResult, error) {
+	defer s.cancel()
+
+	s.info.Checksum = checksum
+	s.info.RecordCount = recordCount
+
+	// UploadTimeout counts from Close, for the rest of the upload
+	ctx, cancel := s.requestContext()
+	defer cancel()
+
+	err := s.failure()
+	if err == nil && s.sealer != nil {
+		err = s.sealer.Close()
+	}
+	if err == nil {
+		err = s.finish(ctx)
+	}
+	if err != nil {
+		if errors.Is(err, ErrIntegrity) {
+			s.uploader.discardObject(s.objectKey, s.logger)
+		} else {
+			s.abortUpload()
+		}
+		s.logger.LogOSSUploadFailed(
+			"Streaming OSS upload failed",
+			ErrorCode(err),
+			err.Error(),
+			logger.Fields{"object_key": s.objectKey},
+		)
+		return nil, fmt.Errorf("failed to upload: %w", err)
+	}
+
+	signedURL, err := s.uploader.generateSignedURL(s.objectKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate signed URL: %w", err)
+	}
+
+	duration := time.Since(s.startTime)
+	s.logger.LogOSSUploadCompleted(
+		"Streaming OSS upload completed successfully",
+		duration.Milliseconds(),
+		logger.Fields{
+			"object_key": s.objectKey,
+			"signed_url": signedURL,
+			"file_size":  s.Written(),
+			"parts":      s.nextPart,
+		},
+	)
+
+	return &UploadResult{
+		ObjectKey:  s.objectKey,
+		SignedURL:  signedURL,
+		Size:       s.Written(),
+		UploadTime: duration,
+	}, nil
+}
+
+// finish sends the buffered bytes, waits for all parts and verifies the
+// stored object. Parts are sent with their Content-MD5, so OSS rejects
+// corrupted ones.
+func (s *Stream) finish(ctx context.Context) error {
+	s.mu.Lock()
+	multiPart := s.imur != nil
+	s.mu.Unlock()
+
+	// Smaller than a part, upload in a single request
+	if !multiPart {
+		data := s.buf
+		options := append([]oss.Option{oss.WithContext(ctx), oss.ContentMD5(contentMD5(data))}, s.uploader.objectOptions(&s.info)...)
+		err := s.uploader.retry(ctx, s.logger, "upload", func() error {
+			return s.uploader.bucket.PutObject(s.objectKey, bytes.NewReader(data), options...)
+		})
+		if err != nil {
+			return err
+		}
+		return s.uploader.verifyObject(ctx, s.objectKey, s.Written(), s.crc)
+	}
+
+	if len(s.buf) > 0 {
+		if err := s.sendPart(); err != nil {
+			s.wg.Wait()
+			return err
+		}
+	}
+	s.wg.Wait()
+
+	if err := s.failure(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	imur := *s.imur
+	parts := append([]oss.UploadPart(nil), s.parts...)
+	s.mu.Unlock()
+	sort.Sort(oss.UploadParts(parts))
+
+	err := s.uploader.retry(ctx, s.logger, "complete multi-part upload", func() error {
+		_, err := s.uploader.bucket.CompleteMultipartUpload(imur, parts, oss.WithContext(ctx))
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	s.updateMeta(ctx)
+	return s.uploader.verifyObject(ctx, s.objectKey, s.Written(), s.crc)
+}
+
+// maxMetaCopySize is the largest object SetObjectMeta can update: it copies
+// the object onto itself, and OSS rejects CopyObject above 1GB
+const maxMetaCopySize = 1 << 30
+
+// updateMeta adds the checksum and record count, unknown when the upload
+// was initiated, to the metadata of the completed object. The object is
+// usable without them, so failures are only logged. Objects too large to
+// copy keep the metadata they were initiated with.
+func (s *Stream) updateMeta(ctx context.Context) {
+	if s.Written() > maxMetaCopySize {
+		s.logger.LogInfo("OSSMetadataSkipped", "Streamed object is too large to add checksum metadata", logger.Fields{
+			"object_key": s.objectKey,
+			"size":       s.Written(),
+		})
+		return
+	}
+
+	err := s.uploader.retry(ctx, s.logger, "update object metadata", func() error {
+		options := append([]oss.Option{oss.WithContext(ctx)}, objectHeaders(&s.info)...)
+		return s.uploader.bucket.SetObjectMeta(s.objectKey, append(options, s.uploader.encryptionOptions(&s.info)...)...)
+	})
+	if err != nil {
+		s.logger.LogWarn("OSSMetadataError", "Failed to store checksum with streamed object", logger.Fields{
+			"object_key": s.objectKey,
+			"error":      err.Error(),
+		})
+	}
+}
+
+// requestContext returns a context for uploading a part, or for closing
+// the stream, limited to UploadTimeout
+func (s *Stream) requestContext() (context.Context, context.CancelFunc) {
+	if s.uploader.config.UploadTimeout > 0 {
+		return context.WithTimeout(s.ctx, s.uploader.config.UploadTimeout)
+	}
+	return context.WithCancel(s.ctx)
+}
+
+// Abort stops the upload and discards the parts sent so far. It may be
+// called while the stream is being written to.
+func (s *Stream) Abort() {
+	s.fail(errors.New("streaming upload aborted"))
+	s.abortUpload()
+}
+
+// abortUpload aborts the multi-part upload, if one was started
+func (s *Stream) abortUpload() {
+	s.mu.Lock()
+	imur := s.imur
+	s.mu.Unlock()
+	if imur == nil {
+		return
+	}
+
+	if err := s.uploader.bucket.AbortMultipartUpload(*imur); err != nil {
+		s.logger.LogWarn("OSSAbortError", "Failed to abort multi-part upload", logger.Fields{"error": err.Error()})
+	}
+}
+
+// fail records the first failure and stops the parts in flight. It
+// returns the recorded failure.
+func (s *Stream) fail(err error) error {
+	s.mu.Lock()
+	if s.err == nil {
+		s.err = err
+	}
+	err = s.err
+	s.mu.Unlock()
+
+	s.cancel()
+	return err
+}
+
+// failure returns the first failure, if any
+func (s *Stream) failure() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.err
+}
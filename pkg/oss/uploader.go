@@ -2,24 +2,43 @@ package oss
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
-	"path/filepath"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/aliyun/aliyun-oss-go-sdk/oss"
 	"github.com/fluxo/export-middleware/pkg/config"
+	"github.com/fluxo/export-middleware/pkg/encryption"
 	"github.com/fluxo/export-middleware/pkg/logger"
 )
 
+// encryptedSuffix is appended to the local path of a file to name its
+// encrypted copy
+const encryptedSuffix = ".enc"
+
 // Uploader handles file uploads to Alibaba Cloud OSS
 type Uploader struct {
 	client *oss.Client
 	bucket *oss.Bucket
 	config *config.OSSConfig
 	logger *logger.Logger
+
+	keys            *keyTemplate
+	tags            *objectTags
+	signedURLExpiry atomic.Int64 // time.Duration, changed on config reload
+
+	stop      chan struct{}
+	closeOnce sync.Once
 }
 
+// ErrInterrupted is the cause to cancel an upload's context with when the
+// upload is stopped to be resumed later, e.g. on shutdown. Its multi-part
+// upload and checkpoint are kept for the next Upload of the same file.
+var ErrInterrupted = errors.New("upload interrupted")
+
 // UploadResult contains the result of an upload operation
 type UploadResult struct {
 	ObjectKey  string
@@ -28,10 +47,83 @@ type UploadResult struct {
 	UploadTime time.Duration
 }
 
+// ProgressFunc receives the number of bytes uploaded so far and the total
+// file size
+type ProgressFunc func(uploaded int64, total int64)
+
+// progressListener adapts the SDK progress events to a ProgressFunc,
+// accumulating bytes across the parts of a multi-part upload
+type progressListener struct {
+	total    int64
+	uploaded int64
+	fn       ProgressFunc
+}
+
+// add records n more bytes sent, or n fewer if negative
+func (l *progressListener) add(n int64) {
+	uploaded := atomic.AddInt64(&l.uploaded, n)
+	l.fn(uploaded, l.total)
+}
+
+// restart sets the count to the bytes already uploaded when a new round
+// of requests starts
+func (l *progressListener) restart(uploaded int64) {
+	if l == nil {
+		return
+	}
+	atomic.StoreInt64(&l.uploaded, uploaded)
+	l.fn(uploaded, l.total)
+}
+
+// attempt returns a listener for a single request. It returns nil when
+// progress is not reported.
+func (l *progressListener) attempt() *attemptProgress {
+	if l == nil {
+		return nil
+	}
+	return &attemptProgress{parent: l}
+}
+
+// attemptProgress counts the bytes of one request so they can be taken
+// back when the request fails and is retried
+type attemptProgress struct {
+	parent *progressListener
+	sent   int64
+}
+
+// ProgressChanged implements oss.ProgressListener
+func (a *attemptProgress) ProgressChanged(event *oss.ProgressEvent) {
+	if event.EventType != oss.TransferDataEvent {
+		return
+	}
+	atomic.AddInt64(&a.sent, event.RwBytes)
+	a.parent.add(event.RwBytes)
+}
+
+// rewind takes back the bytes of a failed request
+func (a *attemptProgress) rewind() {
+	if a == nil {
+		return
+	}
+	if sent := atomic.SwapInt64(&a.sent, 0); sent > 0 {
+		a.parent.add(-sent)
+	}
+}
+
+// options returns the request options for the attempt
+func (a *attemptProgress) options(ctx context.Context) []oss.Option {
+	options := []oss.Option{oss.WithContext(ctx)}
+	if a != nil {
+		options = append(options, oss.Progress(a))
+	}
+	return options
+}
+
 // NewUploader creates a new OSS uploader
 func NewUploader(cfg *config.OSSConfig, log *logger.Logger) (*Uploader, error) {
-	// Create OSS client
-	client, err := oss.New(cfg.Endpoint, cfg.AccessKeyID, cfg.AccessKeySecret)
+	// Create OSS client. CRC64 checking makes every request compare the
+	// checksum OSS computed with the bytes sent.
+	client, err := oss.New(cfg.Endpoint, cfg.AccessKeyID, cfg.AccessKeySecret, oss.EnableCRC(true))
 	if err != nil {
 		return nil, fmt.Errorf("failed to create OSS client: %w", err)
 	}
@@ -42,28 +134,78 @@ func NewUploader(cfg *config.OSSConfig, log *logger.Logger) (*Uploader, error) {
 		return nil, fmt.Errorf("failed to get OSS bucket: %w", err)
 	}
 
-	return &Uploader{
+	keys, err := parseKeyTemplate(cfg.ObjectKeyTemplate)
+	if err != nil {
+		return nil, err
+	}
+	// Stale uploads are aborted below the fixed prefix of the keys only,
+	// so the uploads of other applications in the bucket are left alone
+	if cfg.StaleUploadAge > 0 && keys.prefix() == "" {
+		return nil, fmt.Errorf("OSS stale upload cleanup needs an object key template starting with a fixed prefix, e.g. exports/, or stale_upload_age 0")
+	}
+	tags, err := parseObjectTags(cfg.ObjectTags)
+	if err != nil {
+		return nil, err
+	}
+
+	u := &Uploader{
 		client: client,
 		bucket: bucket,
 		config: cfg,
 		logger: log,
-	}, nil
+		keys:   keys,
+		tags:   tags,
+		stop:   make(chan struct{}),
+	}
+	u.SetSignedURLExpiry(cfg.SignedURLExpiry)
+
+	if cfg.StaleUploadAge > 0 {
+		go u.staleUploadLoop()
+	}
+
+	return u, nil
 }
 
-// Upload uploads a file to OSS with retry logic
-func (u *Uploader) Upload(ctx context.Context, taskID string, localPath string) (*UploadResult, error) {
+// SetSignedURLExpiry changes the validity of signed URLs generated from
+// now on
+func (u *Uploader) SetSignedURLExpiry(expiry time.Duration) {
+	u.signedURLExpiry.Store(int64(expiry))
+}
+
+// Upload uploads the file of an export to OSS with retry logic. onProgress
+// is optional and is called as bytes are sent.
+func (u *Uploader) Upload(ctx context.Context, info *ObjectInfo, localPath string, onProgress ProgressFunc) (*UploadResult, error) {
 	startTime := time.Now()
 
+	// Upload an encrypted copy when the export has a data key
+	if info.DataKey != nil {
+		encryptedPath := localPath + encryptedSuffix
+		if err := encryption.EncryptFile(localPath, encryptedPath, info.DataKey); err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrEncryption, err)
+		}
+		defer os.Remove(encryptedPath)
+		localPath = encryptedPath
+	}
+
 	// Get file info
 	fileInfo, err := os.Stat(localPath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to stat file: %w", err)
 	}
 
-	// Generate object key (path in OSS)
-	objectKey := u.generateObjectKey(localPath)
+	contextLogger := u.logger.WithContext(ctx).WithTaskID(info.TaskID).WithComponent("oss_uploader")
+
+	// Generate object key (path in OSS). A resumed multi-part upload keeps
+	// the key it was started with.
+	objectKey := u.generateObjectKey(info)
+	headers := u.objectOptions(info)
+	multiPart := fileInfo.Size() > u.config.PartSize
+	var checkpoint *uploadCheckpoint
+	if multiPart {
+		checkpoint = u.openCheckpoint(localPath, fileInfo, objectKey, contextLogger)
+		objectKey = checkpoint.ObjectKey
+	}
 
-	contextLogger := u.logger.WithContext(ctx).WithTaskID(taskID).WithComponent("oss_uploader")
 	contextLogger.LogOSSUploadStarted(
 		"Starting OSS upload",
 		logger.Fields{
@@ -73,41 +215,69 @@ func (u *Uploader) Upload(ctx context.Context, taskID string, localPath string)
 		},
 	)
 
-	var lastErr error
-	for attempt := 0; attempt <= u.config.MaxRetries; attempt++ {
-		if attempt > 0 {
-			waitTime := time.Duration(attempt) * time.Second
-			contextLogger.LogWarn(
-				"OSSUploadRetry",
-				fmt.Sprintf("Retrying upload (attempt %d/%d)", attempt+1, u.config.MaxRetries+1),
-				logger.Fields{"wait_time": waitTime.String()},
-			)
-			time.Sleep(waitTime)
-		}
+	uploadCtx := ctx
+	if u.config.UploadTimeout > 0 {
+		var cancel context.CancelFunc
+		uploadCtx, cancel = context.WithTimeout(ctx, u.config.UploadTimeout)
+		defer cancel()
+	}
 
-		// Choose upload strategy based on file size
-		if fileInfo.Size() > u.config.PartSize {
-			lastErr = u.multiPartUpload(ctx, taskID, localPath, objectKey, contextLogger)
+	var progress *progressListener
+	if onProgress != nil {
+		progress = &progressListener{total: fileInfo.Size(), fn: onProgress}
+	}
+
+	// Checksums of the file, sent with the upload and compared with the
+	// stored object afterwards
+	fileMD5, fileCRC, err := fileDigest(localPath, 0, fileInfo.Size())
+	if err != nil {
+		return nil, fmt.Errorf("failed to checksum file: %w", err)
+	}
+
+	// Choose upload strategy based on file size. Each request retries on
+	// its own, and the parts of a multi-part upload are recorded in its
+	// checkpoint. An object that does not match the file is uploaded again,
+	// once; its multi-part upload was completed, so that takes a new one.
+	for attempt := 0; ; attempt++ {
+		if multiPart {
+			if attempt > 0 {
+				checkpoint = newCheckpoint(localPath, fileInfo, objectKey, u.config.PartSize)
+			}
+			err = u.multiPartUpload(uploadCtx, localPath, checkpoint, headers, progress, contextLogger)
+			// An encrypted copy is made anew by every Upload, so its
+			// parts cannot be resumed
+			keep := err != nil && info.DataKey == nil && resumable(uploadCtx, err)
+			u.settleCheckpoint(checkpoint, err, keep, contextLogger)
 		} else {
-			lastErr = u.simpleUpload(ctx, localPath, objectKey)
+			err = u.simpleUpload(uploadCtx, localPath, objectKey, fileMD5, headers, progress, contextLogger)
 		}
-
-		if lastErr == nil {
+		if err == nil {
+			err = u.verifyObject(uploadCtx, objectKey, fileInfo.Size(), fileCRC)
+		}
+		if !errors.Is(err, ErrIntegrity) || attempt >= integrityRetries {
 			break
 		}
+		contextLogger.LogWarn("OSSIntegrityRetry", "Uploaded object does not match the file, uploading again", logger.Fields{
+			"object_key": objectKey,
+			"attempt":    attempt + 2,
+			"error":      err.Error(),
+		})
 	}
 
-	if lastErr != nil {
+	if err != nil {
+		if errors.Is(err, ErrIntegrity) {
+			u.discardObject(objectKey, contextLogger)
+		}
 		contextLogger.LogOSSUploadFailed(
-			"OSS upload failed after retries",
-			"UPLOAD_ERROR",
-			lastErr.Error(),
+			"OSS upload failed",
+			ErrorCode(err),
+			err.Error(),
 			logger.Fields{
-				"object_key": objectKey,
-				"attempts":   u.config.MaxRetries + 1,
+				"object_key":  objectKey,
+				"max_retries": u.config.MaxRetries,
 			},
 		)
-		return nil, fmt.Errorf("failed to upload after %d attempts: %w", u.config.MaxRetries+1, lastErr)
+		return nil, fmt.Errorf("failed to upload: %w", err)
 	}
 
 	// Generate signed URL
@@ -135,102 +305,177 @@ func (u *Uploader) Upload(ctx context.Context, taskID string, localPath string)
 	}, nil
 }
 
-// simpleUpload uploads a file in a single request
-func (u *Uploader) simpleUpload(ctx context.Context, localPath string, objectKey string) error {
-	return u.bucket.PutObjectFromFile(objectKey, localPath)
-}
-
-// multiPartUpload uploads a file using multi-part upload
-func (u *Uploader) multiPartUpload(ctx context.Context, taskID string, localPath string, objectKey string, contextLogger *logger.ContextLogger) error {
-	// Initialize multi-part upload
-	imur, err := u.bucket.InitiateMultipartUpload(objectKey)
+// openCheckpoint returns the checkpoint to resume a multi-part upload of a
+// local file from, or a new one. A checkpoint taken for another version of
+// the file is discarded, and its upload is aborted.
+func (u *Uploader) openCheckpoint(localPath string, fileInfo os.FileInfo, objectKey string, contextLogger *logger.ContextLogger) *uploadCheckpoint {
+	checkpoint, err := loadCheckpoint(localPath)
 	if err != nil {
-		return fmt.Errorf("failed to initiate multi-part upload: %w", err)
+		contextLogger.LogWarn("OSSCheckpointError", "Ignoring unreadable upload checkpoint", logger.Fields{"error": err.Error()})
+		return newCheckpoint(localPath, fileInfo, objectKey, u.config.PartSize)
 	}
-
-	// Open file
-	file, err := os.Open(localPath)
-	if err != nil {
-		return fmt.Errorf("failed to open file: %w", err)
+	if checkpoint == nil {
+		return newCheckpoint(localPath, fileInfo, objectKey, u.config.PartSize)
+	}
+	if checkpoint.matches(fileInfo, u.config.PartSize) {
+		return checkpoint
 	}
-	defer file.Close()
 
-	// Get file size
-	fileInfo, err := file.Stat()
-	if err != nil {
-		return fmt.Errorf("failed to stat file: %w", err)
+	if checkpoint.UploadID != "" {
+		u.bucket.AbortMultipartUpload(checkpoint.upload(u.config.Bucket))
 	}
+	contextLogger.LogInfo("OSSCheckpointDiscarded", "File changed since the upload checkpoint, starting over", logger.Fields{
+		"object_key": checkpoint.ObjectKey,
+	})
+	return newCheckpoint(localPath, fileInfo, objectKey, u.config.PartSize)
+}
 
-	// Calculate part count
-	partSize := u.config.PartSize
-	partCount := int(fileInfo.Size() / partSize)
-	if fileInfo.Size()%partSize != 0 {
-		partCount++
+// resumable reports whether a failed multi-part upload is worth resuming:
+// it was interrupted, it timed out, or it failed in a way that may pass.
+// Cancelled uploads and permanent failures are not.
+func resumable(ctx context.Context, err error) bool {
+	switch {
+	case errors.Is(context.Cause(ctx), ErrInterrupted):
+		return true
+	case errors.Is(err, context.Canceled):
+		return false
+	case errors.Is(err, context.DeadlineExceeded):
+		return true
 	}
+	_, retryable := classifyCause(err)
+	return retryable
+}
 
-	// Upload parts
-	var parts []oss.UploadPart
-	for partNum := 1; partNum <= partCount; partNum++ {
-		offset := int64(partNum-1) * partSize
-		size := partSize
-		if offset+size > fileInfo.Size() {
-			size = fileInfo.Size() - offset
+// settleCheckpoint cleans up after a multi-part upload ended. With keep,
+// the failed upload and its checkpoint stay, so the next Upload of the
+// file sends only the missing parts. Otherwise the checkpoint is removed,
+// and a failed upload is aborted so its parts do not linger in the bucket.
+func (u *Uploader) settleCheckpoint(checkpoint *uploadCheckpoint, uploadErr error, keep bool, contextLogger *logger.ContextLogger) {
+	if keep {
+		contextLogger.LogInfo("OSSUploadKept", "Keeping multi-part upload to resume it later", logger.Fields{
+			"upload_id": checkpoint.UploadID,
+			"parts":     len(checkpoint.uploadedParts()),
+			"error":     uploadErr.Error(),
+		})
+		return
+	}
+	if uploadErr != nil && checkpoint.UploadID != "" {
+		if err := u.bucket.AbortMultipartUpload(checkpoint.upload(u.config.Bucket)); err != nil {
+			contextLogger.LogWarn("OSSAbortError", "Failed to abort multi-part upload", logger.Fields{"error": err.Error()})
 		}
+	}
+	if err := checkpoint.remove(); err != nil {
+		contextLogger.LogWarn("OSSCheckpointError", "Failed to remove upload checkpoint", logger.Fields{"error": err.Error()})
+	}
+}
 
-		part, err := u.bucket.UploadPartFromFile(imur, localPath, offset, size, partNum)
+// simpleUpload uploads a file in a single request, with its Content-MD5
+func (u *Uploader) simpleUpload(ctx context.Context, localPath string, objectKey string, fileMD5 string, headers []oss.Option, progress *progressListener, contextLogger *logger.ContextLogger) error {
+	return u.retry(ctx, contextLogger, "upload", func() error {
+		attempt := progress.attempt()
+		options := append(attempt.options(ctx), oss.ContentMD5(fileMD5))
+		err := u.bucket.PutObjectFromFile(objectKey, localPath, append(options, headers...)...)
 		if err != nil {
-			// Abort multi-part upload on error
-			u.bucket.AbortMultipartUpload(imur)
-			return fmt.Errorf("failed to upload part %d: %w", partNum, err)
+			attempt.rewind()
 		}
+		return err
+	})
+}
 
-		parts = append(parts, part)
+// generateObjectKey creates the object key of an export from the key
+// template
+func (u *Uploader) generateObjectKey(info *ObjectInfo) string {
+	return u.keys.render(info, time.Now())
+}
 
-		contextLogger.LogDebug(
-			"OSSPartUploaded",
-			fmt.Sprintf("Uploaded part %d/%d", partNum, partCount),
-			logger.Fields{
-				"part_number": partNum,
-				"part_size":   size,
-			},
-		)
+// objectOptions returns the headers and tags stored with the object of
+// an export
+func (u *Uploader) objectOptions(info *ObjectInfo) []oss.Option {
+	options := append(objectHeaders(info), u.encryptionOptions(info)...)
+	if tags := u.tags.render(info, time.Now()); tags != nil {
+		options = append(options, oss.SetTagging(oss.Tagging{Tags: tags}))
 	}
+	return options
+}
 
-	// Complete multi-part upload
-	_, err = u.bucket.CompleteMultipartUpload(imur, parts)
-	if err != nil {
-		u.bucket.AbortMultipartUpload(imur)
-		return fmt.Errorf("failed to complete multi-part upload: %w", err)
+// encryptionOptions returns the server-side encryption headers of the
+// object of an export
+func (u *Uploader) encryptionOptions(info *ObjectInfo) []oss.Option {
+	sse := u.config.Encryption.ServerSide
+	if sse == "" {
+		return nil
 	}
-
-	return nil
+	options := []oss.Option{oss.ServerSideEncryption(sse)}
+	if sse == config.ServerSideEncryptionKMS {
+		if keyID := u.config.Encryption.KMSKeyFor(info.ClientID); keyID != "" {
+			options = append(options, oss.ServerSideEncryptionKeyID(keyID))
+		}
+	}
+	return options
 }
 
-// generateObjectKey creates an object key from local path
-func (u *Uploader) generateObjectKey(localPath string) string {
-	// Use filename with date prefix
-	filename := filepath.Base(localPath)
-	datePrefix := time.Now().Format("2006/01/02")
-	return fmt.Sprintf("exports/%s/%s", datePrefix, filename)
+// objectHeaders returns the Content-Type, Content-Disposition and
+// metadata of the object of an export
+func objectHeaders(info *ObjectInfo) []oss.Option {
+	ct := contentType(info.Extension)
+	if info.DataKey != nil {
+		ct = "application/octet-stream"
+	}
+	options := []oss.Option{
+		oss.ContentType(ct),
+		oss.ContentDisposition(contentDisposition(info.DownloadName())),
+	}
+	for key, value := range objectMeta(info) {
+		options = append(options, oss.Meta(key, metaValue(value)))
+	}
+	return options
 }
 
 // generateSignedURL creates a signed URL for downloading
 func (u *Uploader) generateSignedURL(objectKey string) (string, error) {
-	expiry := int64(u.config.SignedURLExpiry.Seconds())
-	signedURL, err := u.bucket.SignURL(objectKey, oss.HTTPGet, expiry)
+	return u.SignURL(objectKey, time.Duration(u.signedURLExpiry.Load()))
+}
+
+// SignURL creates a signed URL for downloading an object, valid for expiry
+func (u *Uploader) SignURL(objectKey string, expiry time.Duration) (string, error) {
+	signedURL, err := u.bucket.SignURL(objectKey, oss.HTTPGet, int64(expiry.Seconds()))
 	if err != nil {
 		return "", fmt.Errorf("failed to sign URL: %w", err)
 	}
 	return signedURL, nil
 }
 
+// discardObject deletes an object that does not match what was uploaded,
+// so it cannot be downloaded
+func (u *Uploader) discardObject(objectKey string, contextLogger *logger.ContextLogger) {
+	if err := u.bucket.DeleteObject(objectKey); err != nil {
+		contextLogger.LogWarn("OSSDeleteError", "Failed to delete mismatching object", logger.Fields{
+			"object_key": objectKey,
+			"error":      err.Error(),
+		})
+	}
+}
+
+// DiscardUpload aborts the multi-part upload kept for a local file and
+// removes its checkpoint. It is called before a file is deleted without
+// having been uploaded.
+func (u *Uploader) DiscardUpload(localPath string) {
+	checkpoint, err := loadCheckpoint(localPath)
+	if err != nil || checkpoint == nil {
+		return
+	}
+	contextLogger := u.logger.WithContext(context.Background()).WithComponent("oss_uploader")
+	u.settleCheckpoint(checkpoint, ErrInterrupted, false, contextLogger)
+}
+
 // DeleteObject deletes an object from OSS
 func (u *Uploader) DeleteObject(objectKey string) error {
 	return u.bucket.DeleteObject(objectKey)
 }
 
-// Close cleans up resources
+// Close stops the stale upload cleanup. The OSS client doesn't need
+// explicit cleanup.
 func (u *Uploader) Close() error {
-	// OSS client doesn't need explicit cleanup
+	u.closeOnce.Do(func() { close(u.stop) })
 	return nil
 }
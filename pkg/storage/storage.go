@@ -10,6 +10,10 @@ import (
 	"github.com/fluxo/export-middleware/pkg/logger"
 )
 
+// UploadCheckpointSuffix names the checkpoint a multi-part upload keeps
+// next to a temporary file. Checkpoints are deleted with their file.
+const UploadCheckpointSuffix = ".upload.json"
+
 // Manager handles temporary file storage operations
 type Manager struct {
 	tempDir        string
@@ -76,6 +80,23 @@ func (m *Manager) CreateTempFile(taskID string, filename string) (string, error)
 	return filePath, nil
 }
 
+// TrackFile registers an existing temporary file, e.g. one kept for an
+// interrupted task, so it is deleted and expired like a created one
+func (m *Manager) TrackFile(taskID string, path string) {
+	var size int64
+	if info, err := os.Stat(path); err == nil {
+		size = info.Size()
+	}
+
+	m.mu.Lock()
+	m.files[taskID] = &FileInfo{
+		Path:      path,
+		CreatedAt: time.Now(),
+		Size:      size,
+	}
+	m.mu.Unlock()
+}
+
 // UpdateFileSize updates the size of a temporary file
 func (m *Manager) UpdateFileSize(taskID string, size int64) {
 	m.mu.Lock()
@@ -111,6 +132,7 @@ func (m *Manager) DeleteFile(taskID string) error {
 	if err := os.Remove(info.Path); err != nil && !os.IsNotExist(err) {
 		return fmt.Errorf("failed to delete file: %w", err)
 	}
+	removeCheckpoint(info.Path)
 
 	m.logger.WithContext(nil).WithTaskID(taskID).LogInfo(
 		"TempFileDeleted",
@@ -154,6 +176,7 @@ func (m *Manager) cleanup() {
 	for taskID, info := range m.files {
 		if now.Sub(info.CreatedAt) > m.retention {
 			if err := os.Remove(info.Path); err == nil {
+				removeCheckpoint(info.Path)
 				delete(m.files, taskID)
 				m.logger.WithContext(nil).WithTaskID(taskID).LogInfo(
 					"TempFileCleanup",
@@ -163,6 +186,29 @@ func (m *Manager) cleanup() {
 			}
 		}
 	}
+
+	// Checkpoints left behind by a crashed process belong to no tracked
+	// file
+	checkpoints, _ := filepath.Glob(filepath.Join(m.tempDir, "*"+UploadCheckpointSuffix))
+	for _, path := range checkpoints {
+		if info, err := os.Stat(path); err == nil && now.Sub(info.ModTime()) > m.retention {
+			os.Remove(path)
+		}
+	}
+}
+
+// removeCheckpoint deletes the upload checkpoint of a temporary file, if
+// there is one
+func removeCheckpoint(path string) {
+	os.Remove(path + UploadCheckpointSuffix)
+}
+
+// SetRetention changes how long temporary files are kept
+func (m *Manager) SetRetention(retention time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.retention = retention
 }
 
 // Close stops the cleanup loop and cleans up resources
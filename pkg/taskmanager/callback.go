@@ -0,0 +1,113 @@
+package taskmanager
+
+import (
+	"context"
+	"time"
+
+	"github.com/fluxo/export-middleware/pkg/logger"
+	"github.com/fluxo/export-middleware/pkg/webhook"
+	pb "github.com/fluxo/export-middleware/proto"
+)
+
+// callbackDrainTimeout is how long Shutdown waits for callbacks still being
+// delivered before cancelling them
+const callbackDrainTimeout = 10 * time.Second
+
+// FinalizeTaskAsync finalizes and uploads the task in the background so the
+// client stream can close as soon as the last batch has been received.
+// Completion is reported through the task status and the callback URL.
+func (m *Manager) FinalizeTaskAsync(task *Task) {
+	m.wg.Add(1)
+	go func() {
+		defer m.wg.Done()
+
+		// Failures are recorded on the task and reported by failTask
+		if err := m.FinalizeTask(task); err != nil {
+			m.logger.WithContext(m.shutdownCtx).WithTaskID(task.ID).WithComponent("task_manager").LogDebug(
+				"AsyncFinalizeFailed",
+				"Background finalization failed",
+				logger.Fields{"error": err.Error()},
+			)
+		}
+	}()
+}
+
+// dispatchCallback posts the final task state to the callback URL given in
+// the export metadata, if any
+func (m *Manager) dispatchCallback(task *Task) {
+	callbackURL := task.Metadata.GetCallbackUrl()
+	if callbackURL == "" || m.webhook == nil {
+		return
+	}
+
+	status := m.buildStatus(task)
+	event := "task.completed"
+	switch status.Status {
+	case pb.TaskStatus_TASK_STATUS_FAILED:
+		event = "task.failed"
+	case pb.TaskStatus_TASK_STATUS_CANCELLED:
+		event = "task.cancelled"
+	case pb.TaskStatus_TASK_STATUS_INTERRUPTED:
+		event = "task.interrupted"
+	}
+
+	payload := &webhook.Payload{
+		Event:          event,
+		TaskID:         status.TaskId,
+		RequestID:      status.RequestId,
+		Status:         status.Status.String(),
+		OSSUrl:         status.OssUrl,
+		ChecksumSHA256: status.ChecksumSha256,
+		FileSizeBytes:  status.FileSizeBytes,
+		RecordCount:    status.RecordsProcessed,
+		ErrorCode:      status.ErrorCode,
+		ErrorMessage:   status.ErrorMessage,
+		CompletionTime: status.CompletionTime,
+	}
+
+	m.mu.Lock()
+	if m.callbacksClosed {
+		m.mu.Unlock()
+		m.logger.WithContext(context.Background()).WithTaskID(task.ID).WithComponent("task_manager").LogWarn(
+			"CallbackDropped",
+			"Task ended after shutdown, callback not sent",
+			logger.Fields{"event": event},
+		)
+		return
+	}
+	m.callbacks.Add(1)
+	m.mu.Unlock()
+
+	go func() {
+		defer m.callbacks.Done()
+		m.webhook.Notify(m.callbackCtx, callbackURL, payload)
+	}()
+}
+
+// drainCallbacks stops dispatching callbacks and waits for those being
+// delivered, for up to callbackDrainTimeout or until ctx is done. Callbacks
+// still running then are cancelled.
+func (m *Manager) drainCallbacks(ctx context.Context) {
+	m.mu.Lock()
+	m.callbacksClosed = true
+	m.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		m.callbacks.Wait()
+		close(done)
+	}()
+
+	timer := time.NewTimer(callbackDrainTimeout)
+	defer timer.Stop()
+	select {
+	case <-done:
+		return
+	case <-timer.C:
+	case <-ctx.Done():
+	}
+
+	m.logger.Warn("Cancelling undelivered task callbacks")
+	m.stopCallbacks()
+	<-done
+}
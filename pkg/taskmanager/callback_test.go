@@ -0,0 +1,50 @@
+package taskmanager
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/fluxo/export-middleware/pkg/config"
+	"github.com/fluxo/export-middleware/pkg/webhook"
+	pb "github.com/fluxo/export-middleware/proto"
+)
+
+func TestShutdown_WaitsForCallbacks(t *testing.T) {
+	var delivered atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(100 * time.Millisecond)
+		delivered.Add(1)
+	}))
+	defer server.Close()
+
+	m := newTestManager(t)
+	m.webhook = webhook.NewNotifier(&config.WebhookConfig{
+		Enabled:              true,
+		Secret:               "test-secret",
+		Timeout:              time.Second,
+		AllowPrivateNetworks: true,
+	}, m.logger)
+
+	task := &Task{ID: "a", Status: StatusFailed, Metadata: &pb.ExportMetadata{CallbackUrl: server.URL}}
+	m.dispatchCallback(task)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := m.Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown failed: %v", err)
+	}
+	if delivered.Load() != 1 {
+		t.Fatal("Expected the callback to be delivered before Shutdown returned")
+	}
+
+	// Tasks ending after shutdown send no callback
+	m.dispatchCallback(task)
+	time.Sleep(150 * time.Millisecond)
+	if delivered.Load() != 1 {
+		t.Error("Expected no callback after shutdown")
+	}
+}
@@ -0,0 +1,21 @@
+package taskmanager
+
+import "context"
+
+// clientIDKey is the context key for the calling client's identity
+type clientIDKey struct{}
+
+// WithClientID returns a context carrying the identity of the calling client
+func WithClientID(ctx context.Context, clientID string) context.Context {
+	return context.WithValue(ctx, clientIDKey{}, clientID)
+}
+
+// ClientIDFromContext returns the client identity stored in the context, or
+// an empty string for anonymous callers
+func ClientIDFromContext(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	clientID, _ := ctx.Value(clientIDKey{}).(string)
+	return clientID
+}
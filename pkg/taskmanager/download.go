@@ -0,0 +1,61 @@
+package taskmanager
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrNotDownloadable is returned for tasks that have no uploaded file
+var ErrNotDownloadable = errors.New("task has no file to download")
+
+// DownloadExpiry returns the validity of a download URL: the requested
+// expiry, or signed_url_expiry when 0, capped at downloads.max_url_expiry
+func (m *Manager) DownloadExpiry(requested time.Duration) time.Duration {
+	cfg := m.config()
+	if requested <= 0 {
+		requested = cfg.OSS.SignedURLExpiry
+	}
+	return min(requested, cfg.Downloads.MaxURLExpiry)
+}
+
+// ObjectKey returns the object key of the file of a completed task. Tasks
+// no longer in memory are found through their export records until they
+// expire.
+func (m *Manager) ObjectKey(taskID string) (string, error) {
+	m.mu.RLock()
+	task, exists := m.tasks[taskID]
+	m.mu.RUnlock()
+	if !exists {
+		record, err := m.loadRecord(taskID)
+		if err != nil {
+			return "", err
+		}
+		if record == nil {
+			return "", fmt.Errorf("task not found: %s", taskID)
+		}
+		// Not yet removed by expireExports
+		if !record.ExpiresAt.IsZero() && record.ExpiresAt.Before(time.Now()) {
+			return "", ErrNotDownloadable
+		}
+		return record.ObjectKey, nil
+	}
+
+	task.mu.RLock()
+	defer task.mu.RUnlock()
+
+	if task.Status != StatusCompleted || task.ObjectKey == "" {
+		return "", ErrNotDownloadable
+	}
+	return task.ObjectKey, nil
+}
+
+// SignDownloadURL signs a new download URL for the file of a completed
+// task, valid for expiry
+func (m *Manager) SignDownloadURL(taskID string, expiry time.Duration) (string, error) {
+	objectKey, err := m.ObjectKey(taskID)
+	if err != nil {
+		return "", err
+	}
+	return m.ossUploader.SignURL(objectKey, expiry)
+}
@@ -0,0 +1,294 @@
+package taskmanager
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/fluxo/export-middleware/pkg/logger"
+	"github.com/fluxo/export-middleware/pkg/oss"
+	pb "github.com/fluxo/export-middleware/proto"
+)
+
+// ErrDraining is returned by CreateTask once the manager is draining
+var ErrDraining = errors.New("task manager is draining, not accepting new tasks")
+
+// interruptedDir is the directory below the temp directory holding the
+// state of interrupted tasks
+const interruptedDir = "interrupted"
+
+// phaseUploading is the phase of tasks interrupted after their file was
+// written, whose upload ResumeInterrupted restarts
+const phaseUploading = "uploading"
+
+// interruptedState is the persisted state of an interrupted task. The
+// local file is kept so the export can be inspected or uploaded later.
+type interruptedState struct {
+	TaskID           string    `json:"task_id"`
+	RequestID        string    `json:"request_id,omitempty"`
+	ClientID         string    `json:"client_id,omitempty"`
+	Format           string    `json:"format"`
+	Filename         string    `json:"filename"`
+	Phase            string    `json:"phase"` // Status when interrupted
+	LocalPath        string    `json:"local_path,omitempty"`
+	RecordsProcessed int64     `json:"records_processed"`
+	BatchesProcessed int64     `json:"batches_processed"`
+	FileSizeBytes    int64     `json:"file_size_bytes,omitempty"`
+	Checksum         string    `json:"checksum,omitempty"`
+	RetentionSeconds int64     `json:"retention_seconds,omitempty"`
+	CallbackURL      string    `json:"callback_url,omitempty"`
+	Encrypted        bool      `json:"encrypted,omitempty"` // Client-side; the data key is not persisted
+	StartTime        time.Time `json:"start_time"`
+	InterruptedAt    time.Time `json:"interrupted_at"`
+}
+
+// Drain stops accepting new tasks and waits for queued and running tasks
+// to finish. If ctx expires first, the remaining tasks are interrupted:
+// their uploads are aborted, and their state is written to the interrupted
+// directory under the temp directory.
+func (m *Manager) Drain(ctx context.Context) error {
+	m.mu.Lock()
+	m.draining = true
+	pending := make([]*Task, 0)
+	for _, task := range m.tasks {
+		if !task.Finished() {
+			pending = append(pending, task)
+		}
+	}
+	m.mu.Unlock()
+
+	m.logger.Info("Draining task manager", logger.Fields{"pending_tasks": len(pending)})
+
+	for _, task := range pending {
+		select {
+		case <-task.done:
+		case <-ctx.Done():
+			interrupted := m.interruptPending()
+			return fmt.Errorf("drain deadline exceeded, %d tasks interrupted", interrupted)
+		}
+	}
+
+	m.logger.Info("Task manager drained")
+	return nil
+}
+
+// interruptPending marks every unfinished task as interrupted and aborts
+// uploads in flight. It returns the number of interrupted tasks.
+func (m *Manager) interruptPending() int {
+	m.mu.RLock()
+	tasks := make([]*Task, 0, len(m.tasks))
+	for _, task := range m.tasks {
+		tasks = append(tasks, task)
+	}
+	m.mu.RUnlock()
+
+	interrupted := 0
+	for _, task := range tasks {
+		if m.interruptTask(task) {
+			interrupted++
+		}
+	}
+
+	// Statuses are set first so that failing uploads do not mark the
+	// tasks as failed. Their multi-part uploads are kept for
+	// ResumeInterrupted.
+	m.abortUploads(oss.ErrInterrupted)
+
+	return interrupted
+}
+
+// interruptTask marks an unfinished task as interrupted and persists its
+// state. It returns false if the task had already finished.
+func (m *Manager) interruptTask(task *Task) bool {
+	task.mu.Lock()
+	if task.finishedLocked() {
+		task.mu.Unlock()
+		return false
+	}
+	phase := strings.ToLower(strings.TrimPrefix(m.convertStatus(task.Status).String(), "TASK_STATUS_"))
+	task.Status = StatusInterrupted
+	task.ErrorCode = "INTERRUPTED"
+	task.ErrorMessage = fmt.Sprintf("Server shut down while the task was %s", phase)
+	task.CompletionTime = time.Now()
+	state := &interruptedState{
+		TaskID:           task.ID,
+		RequestID:        task.Metadata.GetRequestId(),
+		ClientID:         task.ClientID,
+		Format:           task.Format.String(),
+		Filename:         task.Filename,
+		Phase:            phase,
+		LocalPath:        task.LocalPath,
+		RecordsProcessed: task.RecordsProcessed,
+		BatchesProcessed: task.BatchesProcessed,
+		FileSizeBytes:    task.FileSizeBytes,
+		Checksum:         task.Checksum,
+		RetentionSeconds: task.Metadata.GetRetentionSeconds(),
+		CallbackURL:      task.Metadata.GetCallbackUrl(),
+		Encrypted:        task.dataKey != nil,
+		StartTime:        task.StartTime,
+		InterruptedAt:    task.CompletionTime,
+	}
+	task.mu.Unlock()
+	m.endTask(task)
+	m.notifyWatchers(task)
+	m.dispatchCallback(task)
+	m.publishEvent(task, logger.EventTaskInterrupted)
+
+	// Parts streamed while writing cannot be resumed
+	if task.stream != nil {
+		task.stream.Abort()
+	}
+
+	contextLogger := m.logger.WithContext(context.Background()).WithTaskID(task.ID).WithComponent("task_manager")
+	fields := logger.Fields{"phase": phase, "records": state.RecordsProcessed}
+	if err := m.persistInterrupted(state); err != nil {
+		contextLogger.LogError("InterruptedStateError", "Failed to persist interrupted task", "STORAGE_ERROR", err.Error(), nil)
+	} else {
+		fields["state_file"] = m.interruptedPath(task.ID)
+	}
+	contextLogger.LogTaskInterrupted("Export task interrupted by shutdown", fields)
+
+	return true
+}
+
+// persistInterrupted writes the state of an interrupted task to disk
+func (m *Manager) persistInterrupted(state *interruptedState) error {
+	path := m.interruptedPath(state.TaskID)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create interrupted directory: %w", err)
+	}
+
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode state: %w", err)
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// interruptedPath returns the state file of an interrupted task
+func (m *Manager) interruptedPath(taskID string) string {
+	return filepath.Join(m.config().Storage.TempDirectory, interruptedDir, taskID+".json")
+}
+
+// loadInterrupted reads the persisted state of an interrupted task
+func (m *Manager) loadInterrupted(taskID string) (*interruptedState, error) {
+	data, err := os.ReadFile(m.interruptedPath(taskID))
+	if err != nil {
+		return nil, err
+	}
+	state := &interruptedState{}
+	if err := json.Unmarshal(data, state); err != nil {
+		return nil, fmt.Errorf("failed to decode state: %w", err)
+	}
+	return state, nil
+}
+
+// ResumeInterrupted restarts the uploads of tasks an earlier shutdown
+// interrupted while uploading. Their files were kept, and a multi-part
+// upload resumes from the parts sent before the shutdown. Tasks
+// interrupted while their file was written, or encrypted on the client,
+// stay interrupted. It returns the number of resumed uploads.
+func (m *Manager) ResumeInterrupted() int {
+	contextLogger := m.logger.WithContext(context.Background()).WithComponent("task_manager")
+
+	entries, err := os.ReadDir(filepath.Join(m.config().Storage.TempDirectory, interruptedDir))
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			contextLogger.LogWarn("InterruptedStateError", "Failed to list interrupted tasks", logger.Fields{"error": err.Error()})
+		}
+		return 0
+	}
+
+	resumed := 0
+	for _, entry := range entries {
+		taskID, ok := strings.CutSuffix(entry.Name(), ".json")
+		if !ok || !ValidTaskID(taskID) {
+			continue
+		}
+		state, err := m.loadInterrupted(taskID)
+		if err != nil {
+			contextLogger.LogWarn("InterruptedStateError", "Ignoring unreadable interrupted task", logger.Fields{"task_id": taskID, "error": err.Error()})
+			continue
+		}
+		if state.Phase != phaseUploading || state.LocalPath == "" || state.Encrypted {
+			continue
+		}
+		if _, err := os.Stat(state.LocalPath); err != nil {
+			continue
+		}
+
+		task := &Task{
+			ID:     state.TaskID,
+			Status: StatusUploading,
+			Format: pb.ExportFormat(pb.ExportFormat_value[state.Format]),
+			Metadata: &pb.ExportMetadata{
+				RequestId:        state.RequestID,
+				Filename:         state.Filename,
+				RetentionSeconds: state.RetentionSeconds,
+				CallbackUrl:      state.CallbackURL,
+			},
+			Filename:         state.Filename,
+			ClientID:         state.ClientID,
+			StartTime:        state.StartTime,
+			LocalPath:        state.LocalPath,
+			RecordsProcessed: state.RecordsProcessed,
+			BatchesProcessed: state.BatchesProcessed,
+			FileSizeBytes:    state.FileSizeBytes,
+			Checksum:         state.Checksum,
+			ProgressPercent:  writePhaseWeight,
+			sequencer:        newBatchSequencer(),
+			ready:            make(chan struct{}),
+			done:             make(chan struct{}),
+		}
+		task.Metadata.Format = task.Format
+		task.writtenBytes.Store(state.FileSizeBytes)
+		task.markReady()
+
+		m.mu.Lock()
+		if _, exists := m.tasks[task.ID]; exists {
+			m.mu.Unlock()
+			continue
+		}
+		m.tasks[task.ID] = task
+		m.requests[requestKey(task.ClientID, state.RequestID)] = task.ID
+		m.addUsageLocked(task)
+		m.mu.Unlock()
+		m.storage.TrackFile(task.ID, task.LocalPath)
+
+		m.wg.Add(1)
+		go m.resumeUpload(task)
+		resumed++
+	}
+	return resumed
+}
+
+// resumeUpload uploads the kept file of an interrupted task and completes
+// the task
+func (m *Manager) resumeUpload(task *Task) {
+	defer m.wg.Done()
+
+	contextLogger := m.logger.WithContext(context.Background()).WithTaskID(task.ID).WithComponent("task_manager")
+	contextLogger.LogInfo("UploadResumed", "Resuming upload of interrupted task", logger.Fields{"local_path": task.LocalPath})
+	m.publishEvent(task, logger.EventOSSUploadStarted)
+
+	result, err := m.ossUploader.Upload(m.uploadCtx, m.objectInfo(task), task.LocalPath, func(uploaded int64, total int64) {
+		m.updateUploadProgress(task, uploaded, total)
+	})
+	if err != nil {
+		// Interrupted again, with its state written anew
+		if errors.Is(context.Cause(m.uploadCtx), oss.ErrInterrupted) {
+			return
+		}
+		m.failTask(task, oss.ErrorCode(err), fmt.Sprintf("Failed to upload to OSS: %v", err), contextLogger)
+	} else {
+		m.completeUpload(task, result, contextLogger)
+	}
+	if err := os.Remove(m.interruptedPath(task.ID)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		contextLogger.LogWarn("InterruptedStateError", "Failed to remove interrupted state", logger.Fields{"error": err.Error()})
+	}
+}
@@ -0,0 +1,76 @@
+package taskmanager
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	pb "github.com/fluxo/export-middleware/proto"
+)
+
+func TestDrain_WaitsForRunningTasks(t *testing.T) {
+	m := newTestManager(t)
+	task := &Task{ID: "task-1", Status: StatusProcessing, StartTime: time.Now(), ready: make(chan struct{}), done: make(chan struct{})}
+	m.tasks[task.ID] = task
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		task.mu.Lock()
+		task.Status = StatusCompleted
+		task.mu.Unlock()
+		task.markDone()
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := m.Drain(ctx); err != nil {
+		t.Fatalf("Expected drain to complete, got %v", err)
+	}
+	if m.uploadCtx.Err() != nil {
+		t.Error("Expected uploads not to be aborted")
+	}
+
+	if _, _, err := m.CreateTask(context.Background(), &pb.ExportMetadata{}); !errors.Is(err, ErrDraining) {
+		t.Errorf("Expected ErrDraining after drain, got %v", err)
+	}
+}
+
+func TestDrain_InterruptsAtDeadline(t *testing.T) {
+	m := newTestManager(t)
+	running := &Task{ID: "task-1", ClientID: "client-a", Status: StatusUploading, StartTime: time.Now(), RecordsProcessed: 42, ready: make(chan struct{}), done: make(chan struct{})}
+	finished := &Task{ID: "task-2", Status: StatusCompleted, StartTime: time.Now(), ready: make(chan struct{}), done: make(chan struct{})}
+	finished.markDone()
+	m.tasks[running.ID] = running
+	m.tasks[finished.ID] = finished
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := m.Drain(ctx); err == nil {
+		t.Fatal("Expected drain to report interrupted tasks")
+	}
+
+	if running.Status != StatusInterrupted || running.ErrorCode != "INTERRUPTED" {
+		t.Errorf("Expected running task to be interrupted, got %v (%s)", running.Status, running.ErrorCode)
+	}
+	if finished.Status != StatusCompleted {
+		t.Errorf("Expected finished task to keep its status, got %v", finished.Status)
+	}
+	if m.uploadCtx.Err() == nil {
+		t.Error("Expected uploads to be aborted")
+	}
+
+	data, err := os.ReadFile(m.interruptedPath(running.ID))
+	if err != nil {
+		t.Fatalf("Expected interrupted state file: %v", err)
+	}
+	var state interruptedState
+	if err := json.Unmarshal(data, &state); err != nil {
+		t.Fatalf("Failed to decode state: %v", err)
+	}
+	if state.Phase != "uploading" || state.RecordsProcessed != 42 || state.ClientID != "client-a" {
+		t.Errorf("Unexpected state: %+v", state)
+	}
+}
@@ -0,0 +1,24 @@
+package taskmanager
+
+import "testing"
+
+func TestDataKey_OnlyForCreator(t *testing.T) {
+	m := newTestManager(t)
+	key := []byte("0123456789abcdef0123456789abcdef")
+	m.tasks["task-1"] = &Task{ID: "task-1", ClientID: "client-a", dataKey: key}
+	m.tasks["task-2"] = &Task{ID: "task-2", dataKey: key} // created without a client ID
+
+	if got := m.DataKey("task-1", "client-a"); string(got) != string(key) {
+		t.Errorf("Expected the key for the creator, got %x", got)
+	}
+	for _, tt := range []struct{ taskID, clientID string }{
+		{"task-1", "client-b"},
+		{"task-1", ""},
+		{"task-2", ""},
+		{"task-3", "client-a"},
+	} {
+		if got := m.DataKey(tt.taskID, tt.clientID); got != nil {
+			t.Errorf("Task %s, client %q: expected no key, got %x", tt.taskID, tt.clientID, got)
+		}
+	}
+}
@@ -0,0 +1,33 @@
+package taskmanager
+
+import (
+	"time"
+
+	"github.com/fluxo/export-middleware/pkg/notifier"
+)
+
+// publishEvent sends a lifecycle event for a task to the event sink, if one
+// is configured. name is one of the logger.EventTask* constants.
+func (m *Manager) publishEvent(task *Task, name string) {
+	if m.events == nil {
+		return
+	}
+
+	status := m.buildStatus(task)
+	m.events.Dispatch(&notifier.Event{
+		Name:           name,
+		TaskID:         status.TaskId,
+		RequestID:      status.RequestId,
+		ClientID:       status.ClientId,
+		Status:         status.Status.String(),
+		Format:         status.Format.String(),
+		Filename:       status.Filename,
+		OSSUrl:         status.OssUrl,
+		ChecksumSHA256: status.ChecksumSha256,
+		FileSizeBytes:  status.FileSizeBytes,
+		RecordCount:    status.RecordsProcessed,
+		ErrorCode:      status.ErrorCode,
+		ErrorMessage:   status.ErrorMessage,
+		Timestamp:      time.Now(),
+	})
+}
@@ -0,0 +1,112 @@
+package taskmanager
+
+import (
+	"fmt"
+	"time"
+)
+
+// evictInterval is how often expired request keys and old finished tasks
+// are evicted
+const evictInterval = time.Minute
+
+// requestKey builds the idempotency key of a request
+func requestKey(clientID string, requestID string) string {
+	return clientID + "\x00" + requestID
+}
+
+// findRequestLocked returns the latest task created for a request key.
+// The caller must hold m.mu.
+func (m *Manager) findRequestLocked(key string) *Task {
+	taskID, exists := m.requests[key]
+	if !exists {
+		return nil
+	}
+	return m.tasks[taskID]
+}
+
+// reusable reports whether an existing task should be returned for a
+// re-sent request instead of creating a new one
+func (m *Manager) reusable(task *Task) bool {
+	window := m.config().Concurrency.IdempotencyWindow
+	if window <= 0 {
+		return false
+	}
+
+	task.mu.RLock()
+	defer task.mu.RUnlock()
+
+	// A re-sent request gets a new export once the file is gone
+	switch task.Status {
+	case StatusFailed, StatusCancelled, StatusExpired, StatusDeleted:
+		return false
+	}
+	return time.Since(task.StartTime) < window
+}
+
+// FindTaskByRequestID returns the latest task created by a client for a
+// request ID. Request keys are forgotten after the idempotency window, so
+// older tasks are found among those kept for task_history.
+func (m *Manager) FindTaskByRequestID(clientID string, requestID string) (*Task, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	task := m.findRequestLocked(requestKey(clientID, requestID))
+	if task == nil {
+		for _, candidate := range m.tasks {
+			if candidate.ClientID != clientID || candidate.Metadata.GetRequestId() != requestID {
+				continue
+			}
+			if task == nil || candidate.StartTime.After(task.StartTime) {
+				task = candidate
+			}
+		}
+	}
+	if task == nil {
+		return nil, fmt.Errorf("task not found for request: %s", requestID)
+	}
+	return task, nil
+}
+
+// evictLoop evicts expired request keys and old finished tasks every
+// evictInterval
+func (m *Manager) evictLoop() {
+	defer m.wg.Done()
+
+	ticker := time.NewTicker(evictInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-m.shutdownCtx.Done():
+			return
+		case now := <-ticker.C:
+			m.evict(now)
+		}
+	}
+}
+
+// evict forgets request keys whose idempotency window has passed and
+// finished tasks that ended more than task_history ago. The objects of
+// evicted tasks are still found through their export records.
+func (m *Manager) evict(now time.Time) {
+	cfg := m.config().Concurrency
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for key, taskID := range m.requests {
+		task := m.tasks[taskID]
+		if task == nil || now.Sub(task.StartTime) >= cfg.IdempotencyWindow {
+			delete(m.requests, key)
+		}
+	}
+
+	for taskID, task := range m.tasks {
+		task.mu.RLock()
+		ended := task.finishedLocked() && now.Sub(task.CompletionTime) >= cfg.TaskHistory
+		task.mu.RUnlock()
+		if ended {
+			delete(m.tasks, taskID)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package taskmanager
+
+import (
+	"testing"
+	"time"
+
+	"github.com/fluxo/export-middleware/pkg/config"
+	pb "github.com/fluxo/export-middleware/proto"
+)
+
+func TestEvict(t *testing.T) {
+	m := newTestManager(t, func(cfg *config.Config) {
+		cfg.Concurrency.IdempotencyWindow = time.Hour
+		cfg.Concurrency.TaskHistory = 24 * time.Hour
+	})
+
+	now := time.Now()
+	tasks := map[string]*Task{
+		"recent":  {ID: "recent", Status: StatusCompleted, StartTime: now.Add(-30 * time.Minute), CompletionTime: now.Add(-20 * time.Minute)},
+		"old":     {ID: "old", Status: StatusCompleted, StartTime: now.Add(-2 * time.Hour), CompletionTime: now.Add(-2 * time.Hour)},
+		"ended":   {ID: "ended", Status: StatusFailed, StartTime: now.Add(-48 * time.Hour), CompletionTime: now.Add(-47 * time.Hour)},
+		"running": {ID: "running", Status: StatusProcessing, StartTime: now.Add(-48 * time.Hour)},
+	}
+	for id, task := range tasks {
+		m.tasks[id] = task
+		m.requests[requestKey("client", id)] = id
+	}
+
+	m.evict(now)
+
+	for id, want := range map[string]bool{"recent": true, "old": true, "ended": false, "running": true} {
+		if _, ok := m.tasks[id]; ok != want {
+			t.Errorf("Task %s: expected kept=%v", id, want)
+		}
+	}
+	if len(m.requests) != 1 || m.requests[requestKey("client", "recent")] != "recent" {
+		t.Errorf("Expected only the request key within the window to remain, got %v", m.requests)
+	}
+}
+
+func TestFindTaskByRequestID_AfterWindow(t *testing.T) {
+	m := newTestManager(t, func(cfg *config.Config) {
+		cfg.Concurrency.IdempotencyWindow = 0
+	})
+
+	now := time.Now()
+	for _, task := range []*Task{
+		{ID: "first", ClientID: "client", StartTime: now.Add(-time.Hour), Metadata: &pb.ExportMetadata{RequestId: "req-1"}},
+		{ID: "retry", ClientID: "client", StartTime: now, Metadata: &pb.ExportMetadata{RequestId: "req-1"}},
+		{ID: "other", ClientID: "other", StartTime: now, Metadata: &pb.ExportMetadata{RequestId: "req-1"}},
+	} {
+		m.tasks[task.ID] = task
+	}
+
+	task, err := m.FindTaskByRequestID("client", "req-1")
+	if err != nil {
+		t.Fatalf("Expected the task to be found after the window: %v", err)
+	}
+	if task.ID != "retry" {
+		t.Errorf("Expected the latest task of the client, got %s", task.ID)
+	}
+	if _, err := m.FindTaskByRequestID("client", "req-2"); err == nil {
+		t.Error("Expected unknown request ID not to be found")
+	}
+}
@@ -0,0 +1,348 @@
+package taskmanager
+
+import (
+	"encoding/base64"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	pb "github.com/fluxo/export-middleware/proto"
+	"github.com/google/uuid"
+)
+
+// Page size limits for ListTasks
+const (
+	defaultListPageSize = 50
+	maxListPageSize     = 500
+)
+
+// Limits of the listing snapshots kept for paging by values that change
+const (
+	listSnapshotTTL  = 10 * time.Minute
+	maxListSnapshots = 1000
+)
+
+// listEntry is a task snapshot together with its sort key
+type listEntry struct {
+	status *pb.TaskStatusResponse
+	key    int64
+}
+
+// listSnapshot fixes the tasks of a listing and their order when its first
+// page is requested, for sort fields whose values change while tasks run
+type listSnapshot struct {
+	taskIDs []string
+	request string // filters and order the snapshot was taken for
+	expires time.Time
+}
+
+// ListTasks returns the tasks matching the request filters, sorted and
+// paginated. Listings by start time page with a cursor pointing after the
+// last task of the previous page, so pages stay stable while tasks are
+// added. Completion time, records and file size change while tasks run, so
+// listings by them page through a snapshot of the matching tasks and their
+// order, taken for the first page and kept for listSnapshotTTL. Snapshots
+// are held in memory and do not survive a restart.
+func (m *Manager) ListTasks(req *pb.ListTasksRequest) (*pb.ListTasksResponse, error) {
+	pageSize := int(req.PageSize)
+	if pageSize <= 0 {
+		pageSize = defaultListPageSize
+	}
+	if pageSize > maxListPageSize {
+		pageSize = maxListPageSize
+	}
+
+	var cursor *listCursor
+	if req.PageToken != "" {
+		c, err := decodeListCursor(req.PageToken)
+		if err != nil {
+			return nil, err
+		}
+		if c.sortBy != req.SortBy || c.descending != req.Descending {
+			return nil, fmt.Errorf("page token does not match the requested sort order")
+		}
+		cursor = c
+	}
+
+	if req.SortBy != pb.TaskSortField_TASK_SORT_FIELD_START_TIME {
+		if cursor != nil {
+			return m.listSnapshotPage(req, cursor, pageSize)
+		}
+		return m.listFirstSnapshotPage(req, pageSize), nil
+	}
+
+	entries, less := m.listEntries(req)
+	resp := &pb.ListTasksResponse{TotalCount: int32(len(entries))}
+
+	start := 0
+	if cursor != nil {
+		last := listEntry{status: &pb.TaskStatusResponse{TaskId: cursor.taskID}, key: cursor.key}
+		start = sort.Search(len(entries), func(i int) bool { return less(last, entries[i]) })
+	}
+
+	end := start + pageSize
+	if end > len(entries) {
+		end = len(entries)
+	}
+	for _, entry := range entries[start:end] {
+		resp.Tasks = append(resp.Tasks, entry.status)
+	}
+
+	if end < len(entries) {
+		last := entries[end-1]
+		resp.NextPageToken = encodeListCursor(&listCursor{
+			sortBy:     req.SortBy,
+			descending: req.Descending,
+			key:        last.key,
+			taskID:     last.status.TaskId,
+		})
+	}
+
+	return resp, nil
+}
+
+// listEntries returns the tasks matching the request filters in the
+// requested order, and the order
+func (m *Manager) listEntries(req *pb.ListTasksRequest) ([]listEntry, func(a, b listEntry) bool) {
+	m.mu.RLock()
+	tasks := make([]*Task, 0, len(m.tasks))
+	for _, task := range m.tasks {
+		tasks = append(tasks, task)
+	}
+	m.mu.RUnlock()
+
+	entries := make([]listEntry, 0, len(tasks))
+	for _, task := range tasks {
+		status := m.buildStatus(task)
+		if !matchesListFilter(status, req) {
+			continue
+		}
+		entries = append(entries, listEntry{status: status, key: sortKey(status, req.SortBy)})
+	}
+
+	less := func(a, b listEntry) bool {
+		if a.key != b.key {
+			return a.key < b.key
+		}
+		return a.status.TaskId < b.status.TaskId
+	}
+	if req.Descending {
+		asc := less
+		less = func(a, b listEntry) bool { return asc(b, a) }
+	}
+	sort.Slice(entries, func(i, j int) bool { return less(entries[i], entries[j]) })
+	return entries, less
+}
+
+// listFirstSnapshotPage returns the first page of a listing by a changing
+// value, and snapshots the listing if there are more pages
+func (m *Manager) listFirstSnapshotPage(req *pb.ListTasksRequest, pageSize int) *pb.ListTasksResponse {
+	entries, _ := m.listEntries(req)
+	resp := &pb.ListTasksResponse{TotalCount: int32(len(entries))}
+
+	end := min(pageSize, len(entries))
+	for _, entry := range entries[:end] {
+		resp.Tasks = append(resp.Tasks, entry.status)
+	}
+	if end == len(entries) {
+		return resp
+	}
+
+	snapshot := &listSnapshot{
+		taskIDs: make([]string, len(entries)),
+		request: listRequestKey(req),
+		expires: time.Now().Add(listSnapshotTTL),
+	}
+	for i, entry := range entries {
+		snapshot.taskIDs[i] = entry.status.TaskId
+	}
+	id := uuid.New().String()
+
+	m.mu.Lock()
+	m.storeListSnapshotLocked(id, snapshot)
+	m.mu.Unlock()
+
+	resp.NextPageToken = encodeListCursor(&listCursor{
+		sortBy:     req.SortBy,
+		descending: req.Descending,
+		snapshot:   id,
+		offset:     end,
+	})
+	return resp
+}
+
+// listSnapshotPage returns a later page of a snapshotted listing. Tasks
+// keep their snapshot position but report their current status; tasks
+// removed since are skipped.
+func (m *Manager) listSnapshotPage(req *pb.ListTasksRequest, cursor *listCursor, pageSize int) (*pb.ListTasksResponse, error) {
+	m.mu.RLock()
+	snapshot := m.listSnapshots[cursor.snapshot]
+	m.mu.RUnlock()
+	if snapshot == nil || time.Now().After(snapshot.expires) {
+		return nil, fmt.Errorf("page token expired, list again from the first page")
+	}
+	if snapshot.request != listRequestKey(req) {
+		return nil, fmt.Errorf("page token does not match the request filters")
+	}
+	if cursor.offset < 0 || cursor.offset > len(snapshot.taskIDs) {
+		return nil, fmt.Errorf("invalid page token")
+	}
+
+	end := min(cursor.offset+pageSize, len(snapshot.taskIDs))
+	resp := &pb.ListTasksResponse{TotalCount: int32(len(snapshot.taskIDs))}
+	for _, taskID := range snapshot.taskIDs[cursor.offset:end] {
+		if task, err := m.GetTask(taskID); err == nil {
+			resp.Tasks = append(resp.Tasks, m.buildStatus(task))
+		}
+	}
+
+	if end < len(snapshot.taskIDs) {
+		resp.NextPageToken = encodeListCursor(&listCursor{
+			sortBy:     req.SortBy,
+			descending: req.Descending,
+			snapshot:   cursor.snapshot,
+			offset:     end,
+		})
+	}
+	return resp, nil
+}
+
+// storeListSnapshotLocked keeps a listing snapshot, dropping expired ones
+// and, at maxListSnapshots, the one expiring first. The caller must hold
+// m.mu for writing.
+func (m *Manager) storeListSnapshotLocked(id string, snapshot *listSnapshot) {
+	if m.listSnapshots == nil {
+		m.listSnapshots = make(map[string]*listSnapshot)
+	}
+
+	now := time.Now()
+	oldest := ""
+	for existing, s := range m.listSnapshots {
+		if now.After(s.expires) {
+			delete(m.listSnapshots, existing)
+			continue
+		}
+		if oldest == "" || s.expires.Before(m.listSnapshots[oldest].expires) {
+			oldest = existing
+		}
+	}
+	if len(m.listSnapshots) >= maxListSnapshots {
+		delete(m.listSnapshots, oldest)
+	}
+	m.listSnapshots[id] = snapshot
+}
+
+// listRequestKey identifies the filters and order of a list request, so a
+// snapshot is only paged through by the listing it was taken for
+func listRequestKey(req *pb.ListTasksRequest) string {
+	return fmt.Sprintf("%v|%d|%q|%q|%q|%d|%d|%d|%t",
+		req.Statuses, req.Format, req.RequestId, req.FilenamePrefix, req.ClientId,
+		req.CreatedAfter, req.CreatedBefore, req.SortBy, req.Descending)
+}
+
+// matchesListFilter reports whether a task matches all filters of a request
+func matchesListFilter(status *pb.TaskStatusResponse, req *pb.ListTasksRequest) bool {
+	if len(req.Statuses) > 0 {
+		found := false
+		for _, s := range req.Statuses {
+			if s == status.Status {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if req.Format != pb.ExportFormat_FORMAT_UNSPECIFIED && req.Format != status.Format {
+		return false
+	}
+	if req.RequestId != "" && req.RequestId != status.RequestId {
+		return false
+	}
+	if req.FilenamePrefix != "" && !strings.HasPrefix(status.Filename, req.FilenamePrefix) {
+		return false
+	}
+	if req.ClientId != "" && req.ClientId != status.ClientId {
+		return false
+	}
+	if req.CreatedAfter > 0 && status.StartTime < req.CreatedAfter {
+		return false
+	}
+	if req.CreatedBefore > 0 && status.StartTime >= req.CreatedBefore {
+		return false
+	}
+	return true
+}
+
+// sortKey returns the value a task is ordered by
+func sortKey(status *pb.TaskStatusResponse, sortBy pb.TaskSortField) int64 {
+	switch sortBy {
+	case pb.TaskSortField_TASK_SORT_FIELD_COMPLETION_TIME:
+		return status.CompletionTime
+	case pb.TaskSortField_TASK_SORT_FIELD_RECORDS_PROCESSED:
+		return status.RecordsProcessed
+	case pb.TaskSortField_TASK_SORT_FIELD_FILE_SIZE:
+		return status.FileSizeBytes
+	default:
+		return status.StartTime
+	}
+}
+
+// listCursor identifies where the next page starts: after a task in start
+// time order, or at an offset of a listing snapshot
+type listCursor struct {
+	sortBy     pb.TaskSortField
+	descending bool
+	key        int64
+	taskID     string
+	snapshot   string
+	offset     int
+}
+
+// encodeListCursor serializes a cursor into an opaque page token
+func encodeListCursor(c *listCursor) string {
+	raw := fmt.Sprintf("%d|%t|%d|%d|%s|%s", c.sortBy, c.descending, c.key, c.offset, c.snapshot, c.taskID)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// decodeListCursor parses a page token produced by encodeListCursor
+func decodeListCursor(token string) (*listCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, fmt.Errorf("invalid page token")
+	}
+
+	parts := strings.SplitN(string(raw), "|", 6)
+	if len(parts) != 6 {
+		return nil, fmt.Errorf("invalid page token")
+	}
+
+	sortBy, err := strconv.ParseInt(parts[0], 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("invalid page token")
+	}
+	descending, err := strconv.ParseBool(parts[1])
+	if err != nil {
+		return nil, fmt.Errorf("invalid page token")
+	}
+	key, err := strconv.ParseInt(parts[2], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid page token")
+	}
+	offset, err := strconv.Atoi(parts[3])
+	if err != nil {
+		return nil, fmt.Errorf("invalid page token")
+	}
+
+	return &listCursor{
+		sortBy:     pb.TaskSortField(sortBy),
+		descending: descending,
+		key:        key,
+		offset:     offset,
+		snapshot:   parts[4],
+		taskID:     parts[5],
+	}, nil
+}
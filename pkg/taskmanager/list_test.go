@@ -0,0 +1,109 @@
+package taskmanager
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	pb "github.com/fluxo/export-middleware/proto"
+)
+
+func TestListTasks_FilterAndPaginate(t *testing.T) {
+	m := newTestManager(t)
+	base := time.Unix(1700000000, 0)
+	for i := 0; i < 7; i++ {
+		format := pb.ExportFormat_FORMAT_CSV
+		if i%2 == 1 {
+			format = pb.ExportFormat_FORMAT_EXCEL
+		}
+		id := fmt.Sprintf("task-%d", i)
+		m.tasks[id] = &Task{
+			ID:        id,
+			Status:    StatusCompleted,
+			Format:    format,
+			Filename:  fmt.Sprintf("report_%d.csv", i),
+			StartTime: base.Add(time.Duration(i) * time.Minute),
+		}
+	}
+
+	req := &pb.ListTasksRequest{
+		Format:     pb.ExportFormat_FORMAT_CSV,
+		PageSize:   2,
+		Descending: true,
+	}
+
+	var got []string
+	for page := 0; page < 10; page++ {
+		resp, err := m.ListTasks(req)
+		if err != nil {
+			t.Fatalf("Failed to list tasks: %v", err)
+		}
+		if resp.TotalCount != 4 {
+			t.Errorf("Expected total count 4, got %d", resp.TotalCount)
+		}
+		for _, task := range resp.Tasks {
+			got = append(got, task.TaskId)
+		}
+		if resp.NextPageToken == "" {
+			break
+		}
+		req.PageToken = resp.NextPageToken
+	}
+
+	want := []string{"task-6", "task-4", "task-2", "task-0"}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+}
+
+func TestListTasks_InvalidPageToken(t *testing.T) {
+	m := newTestManager(t)
+
+	if _, err := m.ListTasks(&pb.ListTasksRequest{PageToken: "not-a-token"}); err == nil {
+		t.Error("Expected error for invalid page token")
+	}
+
+	token := encodeListCursor(&listCursor{sortBy: pb.TaskSortField_TASK_SORT_FIELD_FILE_SIZE, taskID: "task-1"})
+	if _, err := m.ListTasks(&pb.ListTasksRequest{PageToken: token}); err == nil {
+		t.Error("Expected error for page token with a different sort order")
+	}
+}
+
+func TestListTasks_SnapshotForChangingValues(t *testing.T) {
+	m := newTestManager(t)
+	for i := 0; i < 5; i++ {
+		id := fmt.Sprintf("task-%d", i)
+		m.tasks[id] = &Task{ID: id, Status: StatusProcessing, RecordsProcessed: int64(i * 10), StartTime: time.Now()}
+	}
+
+	req := &pb.ListTasksRequest{SortBy: pb.TaskSortField_TASK_SORT_FIELD_RECORDS_PROCESSED, PageSize: 2}
+	resp, err := m.ListTasks(req)
+	if err != nil {
+		t.Fatalf("Failed to list tasks: %v", err)
+	}
+	got := []string{resp.Tasks[0].TaskId, resp.Tasks[1].TaskId}
+
+	// Records change between pages: task-0 overtakes every task, which
+	// would repeat it on a later page if pages were computed afresh
+	m.tasks["task-0"].RecordsProcessed = 1000
+	for resp.NextPageToken != "" {
+		req.PageToken = resp.NextPageToken
+		if resp, err = m.ListTasks(req); err != nil {
+			t.Fatalf("Failed to list tasks: %v", err)
+		}
+		for _, task := range resp.Tasks {
+			got = append(got, task.TaskId)
+		}
+	}
+
+	want := []string{"task-0", "task-1", "task-2", "task-3", "task-4"}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+
+	// A token is only valid for the listing it came from
+	req.PageToken = encodeListCursor(&listCursor{sortBy: req.SortBy, snapshot: "unknown", offset: 2})
+	if _, err := m.ListTasks(req); err == nil {
+		t.Error("Expected error for unknown snapshot")
+	}
+}
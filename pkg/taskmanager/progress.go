@@ -0,0 +1,164 @@
+package taskmanager
+
+import (
+	"time"
+)
+
+const (
+	// writePhaseWeight is the share of progress attributed to writing
+	// records; the remainder covers the upload
+	writePhaseWeight = 80.0
+
+	// rateSmoothing is the weight of the newest sample in the exponentially
+	// weighted moving average of throughput
+	rateSmoothing = 0.3
+
+	// undeclaredHalfway is the number of batches after which a task without
+	// declared totals reports half of the write phase
+	undeclaredHalfway = 10.0
+)
+
+// progressTracker keeps smoothed throughput estimates for a task
+type progressTracker struct {
+	lastRecordSample time.Time
+	lastRecords      int64
+	recordRate       float64 // records per second
+	lastUploadSample time.Time
+	lastUploaded     int64
+	uploadRate       float64 // bytes per second
+	uploadedBytes    int64
+}
+
+// sampleRecords updates the record throughput with a new total
+func (p *progressTracker) sampleRecords(now time.Time, records int64) {
+	if !p.lastRecordSample.IsZero() {
+		if elapsed := now.Sub(p.lastRecordSample).Seconds(); elapsed > 0 {
+			p.recordRate = smooth(p.recordRate, float64(records-p.lastRecords)/elapsed)
+		}
+	}
+	p.lastRecordSample = now
+	p.lastRecords = records
+}
+
+// sampleUpload updates the upload throughput with a new uploaded byte count
+func (p *progressTracker) sampleUpload(now time.Time, uploaded int64) {
+	if uploaded < p.lastUploaded {
+		// Upload restarted after a failed attempt
+		p.lastUploaded = 0
+	}
+	if !p.lastUploadSample.IsZero() {
+		if elapsed := now.Sub(p.lastUploadSample).Seconds(); elapsed > 0 {
+			p.uploadRate = smooth(p.uploadRate, float64(uploaded-p.lastUploaded)/elapsed)
+		}
+	}
+	p.lastUploadSample = now
+	p.lastUploaded = uploaded
+	p.uploadedBytes = uploaded
+}
+
+// smooth folds a new sample into a moving average
+func smooth(avg float64, sample float64) float64 {
+	if avg == 0 {
+		return sample
+	}
+	return rateSmoothing*sample + (1-rateSmoothing)*avg
+}
+
+// UpdateTaskProgress records the number of records and batches written so
+// far and recomputes the task progress from the totals declared in the
+// export metadata
+func (m *Manager) UpdateTaskProgress(taskID string, recordsProcessed int64, batchesProcessed int64) {
+	m.mu.RLock()
+	task, exists := m.tasks[taskID]
+	m.mu.RUnlock()
+
+	if !exists {
+		return
+	}
+
+	task.mu.Lock()
+	task.RecordsProcessed = recordsProcessed
+	task.BatchesProcessed = batchesProcessed
+	task.progress.sampleRecords(time.Now(), recordsProcessed)
+	task.ProgressPercent = float32(task.writeFraction() * writePhaseWeight)
+	task.mu.Unlock()
+	task.writtenBytes.Store(task.BytesWritten())
+
+	m.notifyProgress(task)
+}
+
+// updateUploadProgress records the number of bytes uploaded so far
+func (m *Manager) updateUploadProgress(task *Task, uploaded int64, total int64) {
+	task.mu.Lock()
+	task.progress.sampleUpload(time.Now(), uploaded)
+	if total > 0 {
+		fraction := float64(uploaded) / float64(total)
+		if fraction > 1 {
+			fraction = 1
+		}
+		task.ProgressPercent = float32(writePhaseWeight + fraction*(100-writePhaseWeight))
+	}
+	task.mu.Unlock()
+
+	m.notifyProgress(task)
+}
+
+// writeFraction returns the share of declared records or batches already
+// written. When the client declared no totals it estimates the share from
+// the batch count, growing towards but never reaching 1.
+// The caller must hold t.mu.
+func (t *Task) writeFraction() float64 {
+	var fraction float64
+	switch {
+	case t.Metadata.GetTotalRecords() > 0:
+		fraction = float64(t.RecordsProcessed) / float64(t.Metadata.GetTotalRecords())
+	case t.Metadata.GetTotalBatches() > 0:
+		fraction = float64(t.BatchesProcessed) / float64(t.Metadata.GetTotalBatches())
+	default:
+		batches := float64(t.BatchesProcessed)
+		return batches / (batches + undeclaredHalfway)
+	}
+	if fraction > 1 {
+		fraction = 1
+	}
+	return fraction
+}
+
+// estimateTimeRemaining returns the estimated number of seconds until the
+// current phase completes, or 0 if no estimate is available.
+// The caller must hold t.mu.
+func (t *Task) estimateTimeRemaining() int64 {
+	switch t.Status {
+	case StatusProcessing:
+		if t.progress.recordRate <= 0 {
+			return 0
+		}
+		var remaining float64
+		switch {
+		case t.Metadata.GetTotalRecords() > 0:
+			remaining = float64(t.Metadata.GetTotalRecords() - t.RecordsProcessed)
+		case t.Metadata.GetTotalBatches() > 0 && t.BatchesProcessed > 0:
+			perBatch := float64(t.RecordsProcessed) / float64(t.BatchesProcessed)
+			remaining = float64(t.Metadata.GetTotalBatches()-t.BatchesProcessed) * perBatch
+		default:
+			return 0
+		}
+		if remaining <= 0 {
+			return 0
+		}
+		return int64(remaining / t.progress.recordRate)
+
+	case StatusUploading:
+		if t.progress.uploadRate <= 0 || t.FileSizeBytes <= 0 {
+			return 0
+		}
+		remaining := float64(t.FileSizeBytes - t.progress.uploadedBytes)
+		if remaining <= 0 {
+			return 0
+		}
+		return int64(remaining / t.progress.uploadRate)
+
+	default:
+		return 0
+	}
+}
@@ -0,0 +1,67 @@
+package taskmanager
+
+import (
+	"testing"
+	"time"
+
+	pb "github.com/fluxo/export-middleware/proto"
+)
+
+func TestUpdateTaskProgress_DeclaredTotal(t *testing.T) {
+	m := newTestManager(t)
+	task := &Task{
+		ID:       "task-1",
+		Status:   StatusProcessing,
+		Metadata: &pb.ExportMetadata{TotalRecords: 1000},
+	}
+	m.tasks[task.ID] = task
+
+	m.UpdateTaskProgress(task.ID, 500, 5)
+	if task.ProgressPercent != writePhaseWeight/2 {
+		t.Errorf("Expected %v%% at half the records, got %v%%", writePhaseWeight/2, task.ProgressPercent)
+	}
+
+	m.UpdateTaskProgress(task.ID, 2000, 20)
+	if task.ProgressPercent != writePhaseWeight {
+		t.Errorf("Expected progress capped at %v%%, got %v%%", writePhaseWeight, task.ProgressPercent)
+	}
+}
+
+func TestUpdateTaskProgress_NoTotal(t *testing.T) {
+	m := newTestManager(t)
+	task := &Task{ID: "task-1", Status: StatusProcessing, Metadata: &pb.ExportMetadata{}}
+	m.tasks[task.ID] = task
+
+	// Progress is estimated from the batch count and keeps moving without
+	// reaching the end of the write phase
+	m.UpdateTaskProgress(task.ID, 500, undeclaredHalfway)
+	if task.ProgressPercent != writePhaseWeight/2 {
+		t.Errorf("Expected %v%% after %v batches, got %v%%", writePhaseWeight/2, undeclaredHalfway, task.ProgressPercent)
+	}
+
+	m.UpdateTaskProgress(task.ID, 100000, 1000)
+	if task.ProgressPercent <= writePhaseWeight/2 || task.ProgressPercent >= writePhaseWeight {
+		t.Errorf("Expected progress between %v%% and %v%%, got %v%%", writePhaseWeight/2, writePhaseWeight, task.ProgressPercent)
+	}
+}
+
+func TestEstimateTimeRemaining(t *testing.T) {
+	task := &Task{
+		Status:           StatusProcessing,
+		Metadata:         &pb.ExportMetadata{TotalRecords: 1000},
+		RecordsProcessed: 200,
+	}
+	task.progress.recordRate = 100
+	if eta := task.estimateTimeRemaining(); eta != 8 {
+		t.Errorf("Expected 8s remaining while writing, got %d", eta)
+	}
+
+	task.Status = StatusUploading
+	task.FileSizeBytes = 1000
+	now := time.Now()
+	task.progress.sampleUpload(now, 0)
+	task.progress.sampleUpload(now.Add(time.Second), 250)
+	if eta := task.estimateTimeRemaining(); eta != 3 {
+		t.Errorf("Expected 3s remaining while uploading, got %d", eta)
+	}
+}
@@ -0,0 +1,137 @@
+package taskmanager
+
+import (
+	"sort"
+	"time"
+
+	pb "github.com/fluxo/export-middleware/proto"
+)
+
+// durationSamples is the number of recent task durations used to estimate
+// queue wait times
+const durationSamples = 50
+
+// positionRefresh is how long queue positions are reused while the queue
+// does not change, bounding how stale aging can make them
+const positionRefresh = time.Second
+
+// position returns the 1-based position of a task in dispatch order, or
+// false if the task is not queued. Positions are computed for the whole
+// queue at once and reused until the queue changes or positionRefresh
+// passes, so listing many queued tasks sorts the queue once.
+func (s *scheduler) position(taskID string) (int, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	if s.positions == nil || now.Sub(s.positionsAt) >= positionRefresh {
+		s.positions = s.orderLocked(now)
+		s.positionsAt = now
+	}
+	position, ok := s.positions[taskID]
+	return position, ok
+}
+
+// orderLocked returns the 1-based dispatch position of every queued task.
+// Tasks that ended while queued, for example because they were cancelled,
+// are skipped; workers drop them when they are popped. The caller must
+// hold s.mu.
+func (s *scheduler) orderLocked(now time.Time) map[string]int {
+	ordered := make([]*queuedTask, 0, len(s.queue))
+	for _, q := range s.queue {
+		if !q.task.Finished() {
+			ordered = append(ordered, q)
+		}
+	}
+	sort.SliceStable(ordered, func(i, j int) bool { return s.beforeLocked(ordered[i], ordered[j], now) })
+
+	positions := make(map[string]int, len(ordered))
+	for i, q := range ordered {
+		positions[q.task.ID] = i + 1
+	}
+	return positions
+}
+
+// invalidate drops the cached queue positions
+func (s *scheduler) invalidate() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.positions = nil
+}
+
+// recordDurationLocked remembers how long a task held its concurrency slot.
+// The caller must hold m.mu.
+func (m *Manager) recordDurationLocked(d time.Duration) {
+	if len(m.recentDurations) < durationSamples {
+		m.recentDurations = append(m.recentDurations, d)
+		return
+	}
+	copy(m.recentDurations, m.recentDurations[1:])
+	m.recentDurations[len(m.recentDurations)-1] = d
+}
+
+// fillQueueInfo adds the queue position and the estimated start time to
+// the status of a queued task
+func (m *Manager) fillQueueInfo(status *pb.TaskStatusResponse) {
+	position, ok := m.scheduler.position(status.TaskId)
+	if !ok {
+		return
+	}
+
+	status.QueuePosition = int32(position)
+	status.TasksAhead = int32(position - 1)
+	if start, ok := m.estimateStart(position - 1); ok {
+		status.EstimatedStartTime = start.Unix()
+	}
+}
+
+// estimateStart estimates when a task with the given number of queued
+// tasks ahead of it will start. Every slot is assumed to hold a task for
+// the average duration of recent tasks; running tasks free their slot once
+// they reach that average. It reports false until a task has finished.
+func (m *Manager) estimateStart(ahead int) (time.Time, bool) {
+	now := time.Now()
+
+	m.mu.RLock()
+	var total time.Duration
+	for _, d := range m.recentDurations {
+		total += d
+	}
+	samples := len(m.recentDurations)
+	elapsed := make([]time.Duration, 0, len(m.running))
+	for _, started := range m.running {
+		elapsed = append(elapsed, now.Sub(started))
+	}
+	slots := m.maxConcurrent
+	m.mu.RUnlock()
+
+	if samples == 0 || slots <= 0 {
+		return time.Time{}, false
+	}
+	average := total / time.Duration(samples)
+
+	// Time from now until each slot frees up
+	free := make([]time.Duration, slots)
+	for i := 0; i < len(elapsed) && i < len(free); i++ {
+		if remaining := average - elapsed[i]; remaining > 0 {
+			free[i] = remaining
+		}
+	}
+
+	// Hand out slots to the tasks ahead, each taking the earliest free slot
+	earliest := func() int {
+		best := 0
+		for i := range free {
+			if free[i] < free[best] {
+				best = i
+			}
+		}
+		return best
+	}
+	for n := 0; n < ahead; n++ {
+		free[earliest()] += average
+	}
+
+	return now.Add(free[earliest()]), true
+}
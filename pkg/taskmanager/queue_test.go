@@ -0,0 +1,46 @@
+package taskmanager
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFillQueueInfo(t *testing.T) {
+	m := newTestManager(t)
+	m.maxConcurrent = 2
+
+	for _, id := range []string{"a", "b", "c"} {
+		task := &Task{ID: id, Status: StatusQueued, StartTime: time.Now(), ready: make(chan struct{}), done: make(chan struct{})}
+		m.tasks[id] = task
+		m.scheduler.push(task, 5, time.Second)
+	}
+
+	// Without finished tasks there is no estimate yet
+	status := m.buildStatus(m.tasks["c"])
+	if status.QueuePosition != 3 || status.TasksAhead != 2 {
+		t.Errorf("Expected position 3 with 2 ahead, got %d and %d", status.QueuePosition, status.TasksAhead)
+	}
+	if status.EstimatedStartTime != 0 {
+		t.Errorf("Expected no start estimate, got %d", status.EstimatedStartTime)
+	}
+
+	// One slot is busy for another minute, the other is free: a and b take
+	// the free slot and the busy one, c waits for a to finish
+	m.recentDurations = []time.Duration{2 * time.Minute}
+	m.running["running"] = time.Now().Add(-time.Minute)
+
+	now := time.Now()
+	status = m.buildStatus(m.tasks["c"])
+	wait := time.Unix(status.EstimatedStartTime, 0).Sub(now)
+	if wait < 110*time.Second || wait > 130*time.Second {
+		t.Errorf("Expected start in about 2 minutes, got %v", wait)
+	}
+
+	// Tasks cancelled while queued no longer count as ahead
+	a := m.tasks["a"]
+	a.Status = StatusCancelled
+	m.endTask(a)
+	if status := m.buildStatus(m.tasks["c"]); status.QueuePosition != 2 {
+		t.Errorf("Expected position 2 after cancelling a task ahead, got %d", status.QueuePosition)
+	}
+}
@@ -0,0 +1,162 @@
+package taskmanager
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/fluxo/export-middleware/pkg/config"
+	pb "github.com/fluxo/export-middleware/proto"
+)
+
+// Quota error codes
+const (
+	QuotaConcurrentTasks = "QUOTA_CONCURRENT_TASKS"
+	QuotaTasksPerHour    = "QUOTA_TASKS_PER_HOUR"
+	QuotaRecordsPerTask  = "QUOTA_RECORDS_PER_TASK"
+	QuotaBytesPerDay     = "QUOTA_BYTES_PER_DAY"
+)
+
+// QuotaError reports a request rejected by a client quota
+type QuotaError struct {
+	Code     string
+	ClientID string
+	Limit    int64
+	Current  int64
+}
+
+func (e *QuotaError) Error() string {
+	return fmt.Sprintf("%s: client %q is at %d of its limit of %d", e.Code, e.ClientID, e.Current, e.Limit)
+}
+
+// QuotaUsage is the current consumption of a client
+type QuotaUsage struct {
+	ConcurrentTasks int   // Tasks not yet completed, failed or cancelled
+	TasksLastHour   int   // Tasks created in the last hour
+	BytesLastDay    int64 // File bytes written by tasks created in the last 24 hours
+}
+
+// taskQuota holds the per-task limits resolved when the task was created
+type taskQuota struct {
+	clientID   string
+	maxRecords int64 // 0 = unlimited
+	maxBytes   int64 // Daily byte limit, 0 = unlimited
+}
+
+// clientUsage tracks the tasks of a client as they are created and end,
+// so quota checks do not have to visit the tasks of every client
+type clientUsage struct {
+	active int     // tasks that have not ended
+	recent []*Task // tasks created in the last 24 hours, oldest first
+}
+
+// GetQuotaUsage returns the limits and current usage of a client
+func (m *Manager) GetQuotaUsage(clientID string) (config.ClientQuota, QuotaUsage) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.config().Quotas.For(clientID), m.clientUsageLocked(clientID, time.Now())
+}
+
+// clientUsageLocked computes the usage of a client from its counters,
+// forgetting tasks created more than a day ago. The caller must hold m.mu
+// for writing.
+func (m *Manager) clientUsageLocked(clientID string, now time.Time) QuotaUsage {
+	counters := m.usage[clientID]
+	if counters == nil {
+		return QuotaUsage{}
+	}
+
+	expired := 0
+	for expired < len(counters.recent) && now.Sub(counters.recent[expired].StartTime) >= 24*time.Hour {
+		expired++
+	}
+	counters.recent = counters.recent[expired:]
+	if counters.active == 0 && len(counters.recent) == 0 {
+		delete(m.usage, clientID)
+		return QuotaUsage{}
+	}
+
+	usage := QuotaUsage{ConcurrentTasks: counters.active}
+	for _, task := range counters.recent {
+		if now.Sub(task.StartTime) < time.Hour {
+			usage.TasksLastHour++
+		}
+		usage.BytesLastDay += task.writtenBytes.Load()
+	}
+	return usage
+}
+
+// addUsageLocked counts a new task against its client. The caller must
+// hold m.mu for writing.
+func (m *Manager) addUsageLocked(task *Task) {
+	if m.usage == nil {
+		m.usage = make(map[string]*clientUsage)
+	}
+	counters := m.usage[task.ClientID]
+	if counters == nil {
+		counters = &clientUsage{}
+		m.usage[task.ClientID] = counters
+	}
+	counters.active++
+	counters.recent = append(counters.recent, task)
+}
+
+// checkCreateQuotaLocked verifies that a client may create another task
+// and resolves the limits of the new task. It returns nil limits when
+// quotas are disabled. The caller must hold m.mu.
+func (m *Manager) checkCreateQuotaLocked(clientID string, metadata *pb.ExportMetadata) (*taskQuota, error) {
+	if !m.config().Quotas.Enabled {
+		return nil, nil
+	}
+
+	limits := m.config().Quotas.For(clientID)
+	usage := m.clientUsageLocked(clientID, time.Now())
+
+	if limits.MaxConcurrentTasks > 0 && usage.ConcurrentTasks >= limits.MaxConcurrentTasks {
+		return nil, &QuotaError{Code: QuotaConcurrentTasks, ClientID: clientID, Limit: int64(limits.MaxConcurrentTasks), Current: int64(usage.ConcurrentTasks)}
+	}
+	if limits.MaxTasksPerHour > 0 && usage.TasksLastHour >= limits.MaxTasksPerHour {
+		return nil, &QuotaError{Code: QuotaTasksPerHour, ClientID: clientID, Limit: int64(limits.MaxTasksPerHour), Current: int64(usage.TasksLastHour)}
+	}
+	if limits.MaxRecordsPerTask > 0 && metadata.GetTotalRecords() > limits.MaxRecordsPerTask {
+		return nil, &QuotaError{Code: QuotaRecordsPerTask, ClientID: clientID, Limit: limits.MaxRecordsPerTask, Current: metadata.GetTotalRecords()}
+	}
+	if limits.MaxBytesPerDay > 0 && usage.BytesLastDay >= limits.MaxBytesPerDay {
+		return nil, &QuotaError{Code: QuotaBytesPerDay, ClientID: clientID, Limit: limits.MaxBytesPerDay, Current: usage.BytesLastDay}
+	}
+
+	return &taskQuota{
+		clientID:   clientID,
+		maxRecords: limits.MaxRecordsPerTask,
+		maxBytes:   limits.MaxBytesPerDay,
+	}, nil
+}
+
+// CheckBatchQuota verifies that a batch can be written without exceeding
+// the record limit of the task or the daily byte limit of its client.
+// The byte limit is checked against the bytes the client has written so
+// far, by this task and its other recent and running tasks, so each running
+// task may overshoot it by at most one batch.
+func (m *Manager) CheckBatchQuota(task *Task, batch *pb.DataBatch) error {
+	task.mu.RLock()
+	quota := task.quota
+	records := task.RecordsProcessed
+	task.mu.RUnlock()
+
+	if quota == nil {
+		return nil
+	}
+
+	if quota.maxRecords > 0 && records+int64(len(batch.Records)) > quota.maxRecords {
+		return &QuotaError{Code: QuotaRecordsPerTask, ClientID: quota.clientID, Limit: quota.maxRecords, Current: records + int64(len(batch.Records))}
+	}
+	if quota.maxBytes > 0 {
+		m.mu.Lock()
+		written := m.clientUsageLocked(quota.clientID, time.Now()).BytesLastDay
+		m.mu.Unlock()
+		if written >= quota.maxBytes {
+			return &QuotaError{Code: QuotaBytesPerDay, ClientID: quota.clientID, Limit: quota.maxBytes, Current: written}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,149 @@
+package taskmanager
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/fluxo/export-middleware/pkg/config"
+	pb "github.com/fluxo/export-middleware/proto"
+)
+
+// addQuotaTask adds a task of a client created at start, ended or not
+func addQuotaTask(m *Manager, id, clientID string, start time.Time, ended bool) *Task {
+	task := &Task{ID: id, ClientID: clientID, Status: StatusProcessing, StartTime: start, ready: make(chan struct{}), done: make(chan struct{})}
+	m.mu.Lock()
+	m.tasks[id] = task
+	m.addUsageLocked(task)
+	m.mu.Unlock()
+	if ended {
+		task.Status = StatusCompleted
+		m.endTask(task)
+	}
+	return task
+}
+
+func TestCheckCreateQuota(t *testing.T) {
+	m := newTestManager(t, func(cfg *config.Config) {
+		cfg.Quotas = config.QuotasConfig{
+			Enabled: true,
+			Default: config.ClientQuota{MaxConcurrentTasks: 2, MaxTasksPerHour: 3, MaxRecordsPerTask: 100},
+			Clients: map[string]config.ClientQuota{"bulk": {}},
+		}
+	})
+
+	now := time.Now()
+	addQuotaTask(m, "a", "tenant", now, false)
+	addQuotaTask(m, "b", "tenant", now, true)
+	addQuotaTask(m, "c", "other", now, false)
+
+	quota, err := m.checkCreateQuotaLocked("tenant", &pb.ExportMetadata{})
+	if err != nil {
+		t.Fatalf("Expected task to be allowed, got %v", err)
+	}
+	if quota.maxRecords != 100 {
+		t.Errorf("Expected record limit 100, got %d", quota.maxRecords)
+	}
+
+	var quotaErr *QuotaError
+	if _, err := m.checkCreateQuotaLocked("tenant", &pb.ExportMetadata{TotalRecords: 101}); !errors.As(err, &quotaErr) || quotaErr.Code != QuotaRecordsPerTask {
+		t.Errorf("Expected %s, got %v", QuotaRecordsPerTask, err)
+	}
+
+	d := addQuotaTask(m, "d", "tenant", now, false)
+	if _, err := m.checkCreateQuotaLocked("tenant", &pb.ExportMetadata{}); !errors.As(err, &quotaErr) || quotaErr.Code != QuotaConcurrentTasks {
+		t.Errorf("Expected %s, got %v", QuotaConcurrentTasks, err)
+	}
+
+	d.Status = StatusFailed
+	m.endTask(d)
+	m.endTask(d) // ending twice releases one slot
+	if _, err := m.checkCreateQuotaLocked("tenant", &pb.ExportMetadata{}); !errors.As(err, &quotaErr) || quotaErr.Code != QuotaTasksPerHour {
+		t.Errorf("Expected %s, got %v", QuotaTasksPerHour, err)
+	}
+
+	// Client overrides replace the default limits
+	for i := 0; i < 5; i++ {
+		addQuotaTask(m, string(rune('e'+i)), "bulk", now, false)
+	}
+	if _, err := m.checkCreateQuotaLocked("bulk", &pb.ExportMetadata{}); err != nil {
+		t.Errorf("Expected unlimited client to be allowed, got %v", err)
+	}
+}
+
+func TestClientUsage_Window(t *testing.T) {
+	m := newTestManager(t)
+	now := time.Now()
+
+	old := addQuotaTask(m, "old", "tenant", now.Add(-25*time.Hour), true)
+	old.writtenBytes.Store(1000)
+	earlier := addQuotaTask(m, "earlier", "tenant", now.Add(-2*time.Hour), true)
+	earlier.writtenBytes.Store(300)
+	running := addQuotaTask(m, "running", "tenant", now, false)
+	running.writtenBytes.Store(20)
+
+	_, usage := m.GetQuotaUsage("tenant")
+	if usage.ConcurrentTasks != 1 || usage.TasksLastHour != 1 || usage.BytesLastDay != 320 {
+		t.Errorf("Unexpected usage %+v", usage)
+	}
+
+	// Clients without recent or running tasks are forgotten
+	m.endTask(running)
+	running.StartTime = now.Add(-25 * time.Hour)
+	earlier.StartTime = now.Add(-25 * time.Hour)
+	if _, usage := m.GetQuotaUsage("tenant"); usage != (QuotaUsage{}) {
+		t.Errorf("Expected no usage, got %+v", usage)
+	}
+	if _, ok := m.usage["tenant"]; ok {
+		t.Error("Expected idle client to be forgotten")
+	}
+}
+
+func TestCheckBatchQuota_Records(t *testing.T) {
+	m := newTestManager(t)
+	task := &Task{ID: "a", RecordsProcessed: 8, quota: &taskQuota{clientID: "tenant", maxRecords: 10}}
+
+	if err := m.CheckBatchQuota(task, &pb.DataBatch{Records: make([]*pb.Record, 2)}); err != nil {
+		t.Errorf("Expected batch within limit to pass, got %v", err)
+	}
+
+	var quotaErr *QuotaError
+	if err := m.CheckBatchQuota(task, &pb.DataBatch{Records: make([]*pb.Record, 3)}); !errors.As(err, &quotaErr) || quotaErr.Code != QuotaRecordsPerTask {
+		t.Errorf("Expected %s, got %v", QuotaRecordsPerTask, err)
+	}
+}
+
+func TestCheckBatchQuota_BytesAcrossTasks(t *testing.T) {
+	m := newTestManager(t, func(cfg *config.Config) {
+		cfg.Quotas = config.QuotasConfig{Enabled: true, Default: config.ClientQuota{MaxBytesPerDay: 100}}
+	})
+
+	// Both tasks start with the whole daily budget left
+	now := time.Now()
+	a := addQuotaTask(m, "a", "tenant", now, false)
+	b := addQuotaTask(m, "b", "tenant", now, false)
+	for _, task := range []*Task{a, b} {
+		m.mu.Lock()
+		quota, err := m.checkCreateQuotaLocked("tenant", &pb.ExportMetadata{})
+		m.mu.Unlock()
+		if err != nil {
+			t.Fatalf("Expected task to be allowed, got %v", err)
+		}
+		task.quota = quota
+	}
+
+	batch := &pb.DataBatch{Records: make([]*pb.Record, 1)}
+	a.writtenBytes.Store(60)
+	if err := m.CheckBatchQuota(a, batch); err != nil {
+		t.Errorf("Expected batch within the daily limit to pass, got %v", err)
+	}
+
+	// The bytes of the other running task count too
+	b.writtenBytes.Store(50)
+	var quotaErr *QuotaError
+	for _, task := range []*Task{a, b} {
+		if err := m.CheckBatchQuota(task, batch); !errors.As(err, &quotaErr) || quotaErr.Code != QuotaBytesPerDay || quotaErr.Current != 110 {
+			t.Errorf("Task %s: expected %s at 110 bytes, got %v", task.ID, QuotaBytesPerDay, err)
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package taskmanager
+
+import (
+	"github.com/fluxo/export-middleware/pkg/config"
+	"github.com/fluxo/export-middleware/pkg/logger"
+)
+
+// Config returns the configuration currently in effect
+func (m *Manager) Config() *config.Config {
+	return m.config()
+}
+
+// ApplyConfig switches the manager to a reloaded configuration and resizes
+// the worker pool to the new concurrency limit. Only settings read at task
+// time take effect; the queue size and scheduling settings stay as they
+// were at startup.
+func (m *Manager) ApplyConfig(cfg *config.Config) {
+	m.cfg.Store(cfg)
+
+	m.mu.Lock()
+	previous := m.maxConcurrent
+	m.resizeWorkersLocked(cfg.Concurrency.MaxConcurrentTasks)
+	m.mu.Unlock()
+
+	if previous != cfg.Concurrency.MaxConcurrentTasks {
+		m.logger.Info("Worker pool resized", logger.Fields{
+			"previous": previous,
+			"workers":  cfg.Concurrency.MaxConcurrentTasks,
+		})
+	}
+}
+
+// resizeWorkersLocked starts or retires workers until n are running.
+// Retired workers finish the task they are running before they exit.
+// The caller must hold m.mu.
+func (m *Manager) resizeWorkersLocked(n int) {
+	for len(m.workerStops) < n {
+		stop := make(chan struct{})
+		m.workerStops = append(m.workerStops, stop)
+		m.wg.Add(1)
+		go m.worker(m.nextWorkerID, stop)
+		m.nextWorkerID++
+	}
+	for len(m.workerStops) > n {
+		last := len(m.workerStops) - 1
+		close(m.workerStops[last])
+		m.workerStops = m.workerStops[:last]
+	}
+	m.maxConcurrent = n
+}
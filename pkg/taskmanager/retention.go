@@ -0,0 +1,269 @@
+package taskmanager
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/fluxo/export-middleware/pkg/logger"
+	"github.com/google/uuid"
+)
+
+// ErrTaskRunning is returned when deleting the export of a task that has
+// not finished
+var ErrTaskRunning = errors.New("task has not finished, cancel it first")
+
+// ErrInvalidTaskID is returned for task IDs that are not UUIDs, which
+// could otherwise name files outside the state directories
+var ErrInvalidTaskID = errors.New("task ID must be a UUID")
+
+// ValidTaskID reports whether id has the form of a task ID: a UUID in its
+// canonical form
+func ValidTaskID(id string) bool {
+	parsed, err := uuid.Parse(id)
+	return err == nil && parsed.String() == id
+}
+
+// exportsDir is the directory below the temp directory holding a record of
+// every uploaded object, so objects can be expired and deleted after a
+// restart
+const exportsDir = "exports"
+
+// exportRecord is the persisted record of an uploaded object
+type exportRecord struct {
+	TaskID      string    `json:"task_id"`
+	ClientID    string    `json:"client_id,omitempty"`
+	ObjectKey   string    `json:"object_key"`
+	CompletedAt time.Time `json:"completed_at"`
+	ExpiresAt   time.Time `json:"expires_at"` // zero when kept indefinitely
+}
+
+// retentionPeriod returns how long the file of an export is kept: the
+// requested period, or retention.default_period when 0, capped at
+// retention.max_period. 0 means indefinitely.
+func (m *Manager) retentionPeriod(requestedSeconds int64) time.Duration {
+	cfg := m.config().Retention
+	period := cfg.DefaultPeriod
+	if requestedSeconds > 0 {
+		period = time.Duration(requestedSeconds) * time.Second
+	}
+	if cfg.MaxPeriod > 0 && (period == 0 || period > cfg.MaxPeriod) {
+		period = cfg.MaxPeriod
+	}
+	return period
+}
+
+// recordExport persists the record of an uploaded object
+func (m *Manager) recordExport(record *exportRecord) error {
+	path := m.recordPath(record.TaskID)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create exports directory: %w", err)
+	}
+
+	data, err := json.MarshalIndent(record, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode record: %w", err)
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// loadRecord reads the record of a task's object. It returns nil without
+// error if there is none.
+func (m *Manager) loadRecord(taskID string) (*exportRecord, error) {
+	if !ValidTaskID(taskID) {
+		return nil, ErrInvalidTaskID
+	}
+	data, err := os.ReadFile(m.recordPath(taskID))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var record exportRecord
+	if err := json.Unmarshal(data, &record); err != nil {
+		return nil, fmt.Errorf("invalid export record: %w", err)
+	}
+	return &record, nil
+}
+
+// removeRecord deletes the record of a task's object
+func (m *Manager) removeRecord(taskID string) error {
+	if err := os.Remove(m.recordPath(taskID)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// recordPath returns the record file of a task's object
+func (m *Manager) recordPath(taskID string) string {
+	return filepath.Join(m.config().Storage.TempDirectory, exportsDir, taskID+".json")
+}
+
+// janitorLoop deletes expired objects every interval
+func (m *Manager) janitorLoop(interval time.Duration) {
+	defer m.wg.Done()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-m.shutdownCtx.Done():
+			return
+		case now := <-ticker.C:
+			m.expireExports(now)
+		}
+	}
+}
+
+// expireExports deletes the objects whose retention ended before now and
+// marks their tasks as expired. Objects that cannot be deleted are retried
+// on the next run.
+func (m *Manager) expireExports(now time.Time) {
+	contextLogger := m.logger.WithContext(context.Background()).WithComponent("task_manager")
+
+	entries, err := os.ReadDir(filepath.Join(m.config().Storage.TempDirectory, exportsDir))
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			contextLogger.LogWarn("RetentionScanError", "Failed to read export records", logger.Fields{"error": err.Error()})
+		}
+		return
+	}
+
+	for _, entry := range entries {
+		taskID, ok := strings.CutSuffix(entry.Name(), ".json")
+		if !ok {
+			continue
+		}
+		record, err := m.loadRecord(taskID)
+		if err != nil {
+			contextLogger.LogWarn("RetentionScanError", "Failed to read export record", logger.Fields{"task_id": taskID, "error": err.Error()})
+			continue
+		}
+		if record == nil || record.ExpiresAt.IsZero() || record.ExpiresAt.After(now) {
+			continue
+		}
+
+		if err := m.ossUploader.DeleteObject(record.ObjectKey); err != nil {
+			contextLogger.LogWarn("RetentionDeleteError", "Failed to delete expired object", logger.Fields{
+				"task_id":    taskID,
+				"object_key": record.ObjectKey,
+				"error":      err.Error(),
+			})
+			continue
+		}
+		if err := m.removeRecord(taskID); err != nil {
+			contextLogger.LogWarn("RetentionScanError", "Failed to remove export record", logger.Fields{"task_id": taskID, "error": err.Error()})
+		}
+
+		if task, err := m.GetTask(taskID); err == nil {
+			m.endExport(task, StatusExpired, logger.EventTaskExpired)
+		}
+		contextLogger.WithTaskID(taskID).LogInfo(logger.EventTaskExpired, "Expired export deleted", logger.Fields{
+			"object_key": record.ObjectKey,
+			"expired_at": record.ExpiresAt,
+		})
+	}
+}
+
+// ExportOwner returns the client that created a task. Tasks from before a
+// restart are found through the record of their object.
+func (m *Manager) ExportOwner(taskID string) (string, error) {
+	if !ValidTaskID(taskID) {
+		return "", ErrInvalidTaskID
+	}
+	if task, err := m.GetTask(taskID); err == nil {
+		return task.ClientID, nil
+	}
+
+	record, err := m.loadRecord(taskID)
+	if err != nil {
+		return "", err
+	}
+	if record == nil {
+		return "", fmt.Errorf("task not found: %s", taskID)
+	}
+	return record.ClientID, nil
+}
+
+// DeleteExport erases the file of a finished task: the uploaded object,
+// local files and persisted state. The task is marked as deleted. Tasks
+// from before a restart are deleted through the record of their object.
+func (m *Manager) DeleteExport(taskID string) error {
+	if !ValidTaskID(taskID) {
+		return ErrInvalidTaskID
+	}
+	task, _ := m.GetTask(taskID)
+	if task != nil && !task.Finished() {
+		return ErrTaskRunning
+	}
+
+	record, err := m.loadRecord(taskID)
+	if err != nil {
+		return err
+	}
+	if task == nil && record == nil {
+		return fmt.Errorf("task not found: %s", taskID)
+	}
+
+	objectKey := ""
+	if record != nil {
+		objectKey = record.ObjectKey
+	}
+	if task != nil {
+		task.mu.RLock()
+		if task.ObjectKey != "" {
+			objectKey = task.ObjectKey
+		}
+		task.mu.RUnlock()
+	}
+	if objectKey != "" {
+		if err := m.ossUploader.DeleteObject(objectKey); err != nil {
+			return fmt.Errorf("failed to delete object: %w", err)
+		}
+	}
+	if err := m.removeRecord(taskID); err != nil {
+		return fmt.Errorf("failed to remove export record: %w", err)
+	}
+
+	// Local copies kept after a failure or interruption, and the upload
+	// kept to resume an interrupted one
+	if task != nil && task.LocalPath != "" {
+		m.ossUploader.DiscardUpload(task.LocalPath)
+	}
+	m.storage.DeleteFile(taskID)
+	if err := os.Remove(m.interruptedPath(taskID)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to remove interrupted state: %w", err)
+	}
+
+	if task != nil {
+		m.endExport(task, StatusDeleted, logger.EventTaskDeleted)
+	}
+	m.logger.WithContext(context.Background()).WithTaskID(taskID).WithComponent("task_manager").LogInfo(
+		logger.EventTaskDeleted,
+		"Export deleted",
+		logger.Fields{"object_key": objectKey},
+	)
+	return nil
+}
+
+// endExport marks a task whose file was removed as expired or deleted
+func (m *Manager) endExport(task *Task, status TaskStatus, event string) {
+	task.mu.Lock()
+	task.Status = status
+	task.OSSUrl = ""
+	task.ObjectKey = ""
+	task.LocalPath = ""
+	task.dataKey = nil
+	task.mu.Unlock()
+
+	m.notifyWatchers(task)
+	m.publishEvent(task, event)
+}
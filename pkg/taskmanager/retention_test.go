@@ -0,0 +1,112 @@
+package taskmanager
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/fluxo/export-middleware/pkg/config"
+)
+
+func TestRetentionPeriod(t *testing.T) {
+	unlimited := newTestManager(t, func(cfg *config.Config) {
+		cfg.Retention.DefaultPeriod, cfg.Retention.MaxPeriod = 0, 0
+	})
+	if period := unlimited.retentionPeriod(0); period != 0 {
+		t.Errorf("Expected files to be kept without a default, got %v", period)
+	}
+	if period := unlimited.retentionPeriod(60); period != time.Minute {
+		t.Errorf("Expected requested retention, got %v", period)
+	}
+
+	limited := newTestManager(t, func(cfg *config.Config) {
+		cfg.Retention.DefaultPeriod, cfg.Retention.MaxPeriod = time.Hour, 2*time.Hour
+	})
+	if period := limited.retentionPeriod(0); period != time.Hour {
+		t.Errorf("Expected default retention, got %v", period)
+	}
+	if period := limited.retentionPeriod(int64((24 * time.Hour).Seconds())); period != 2*time.Hour {
+		t.Errorf("Expected retention capped at the maximum, got %v", period)
+	}
+}
+
+// Task IDs of the retention tests
+const (
+	retentionTaskID = "6f1c2b4e-8d3a-4f5b-9c7e-1a2b3c4d5e6f"
+	unknownTaskID   = "0b9e8d7c-6f5a-4e3d-8c2b-1a0f9e8d7c6b"
+)
+
+func TestExportRecord_OwnerAfterRestart(t *testing.T) {
+	m := newTestManager(t)
+	record := &exportRecord{
+		TaskID:      retentionTaskID,
+		ClientID:    "client-a",
+		ObjectKey:   "exports/" + retentionTaskID + "/report.csv",
+		CompletedAt: time.Now(),
+		ExpiresAt:   time.Now().Add(time.Hour),
+	}
+	if err := m.recordExport(record); err != nil {
+		t.Fatalf("Failed to record export: %v", err)
+	}
+
+	// The task is not in memory, as after a restart
+	owner, err := m.ExportOwner(retentionTaskID)
+	if err != nil || owner != "client-a" {
+		t.Errorf("Expected owner client-a, got %q, %v", owner, err)
+	}
+	if _, err := m.ExportOwner(unknownTaskID); err == nil {
+		t.Error("Expected unknown task to fail")
+	}
+	if objectKey, err := m.ObjectKey(retentionTaskID); err != nil || objectKey != record.ObjectKey {
+		t.Errorf("Expected object key from the record, got %q, %v", objectKey, err)
+	}
+
+	// Records that have not expired are left alone
+	m.expireExports(time.Now())
+	if loaded, _ := m.loadRecord(retentionTaskID); loaded == nil || loaded.ObjectKey != record.ObjectKey {
+		t.Errorf("Expected record to be kept, got %+v", loaded)
+	}
+
+	// Expired records no longer yield download URLs, even before they are
+	// removed
+	record.ExpiresAt = time.Now().Add(-time.Minute)
+	if err := m.recordExport(record); err != nil {
+		t.Fatalf("Failed to record export: %v", err)
+	}
+	if _, err := m.ObjectKey(retentionTaskID); !errors.Is(err, ErrNotDownloadable) {
+		t.Errorf("Expected ErrNotDownloadable for an expired record, got %v", err)
+	}
+}
+
+func TestDeleteExport_RunningTask(t *testing.T) {
+	m := newTestManager(t)
+	m.tasks[retentionTaskID] = &Task{ID: retentionTaskID, Status: StatusProcessing, ready: make(chan struct{}), done: make(chan struct{})}
+
+	if err := m.DeleteExport(retentionTaskID); !errors.Is(err, ErrTaskRunning) {
+		t.Errorf("Expected ErrTaskRunning, got %v", err)
+	}
+}
+
+func TestDeleteExport_InvalidTaskID(t *testing.T) {
+	m := newTestManager(t)
+
+	// A record outside the exports directory must not be reachable
+	outside := filepath.Join(m.config().Storage.TempDirectory, "victim.json")
+	if err := os.WriteFile(outside, []byte(`{"object_key":"other/object"}`), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	for _, taskID := range []string{"../victim", "", "task-1", "{" + retentionTaskID + "}", "urn:uuid:" + retentionTaskID} {
+		if err := m.DeleteExport(taskID); !errors.Is(err, ErrInvalidTaskID) {
+			t.Errorf("Task ID %q: expected ErrInvalidTaskID, got %v", taskID, err)
+		}
+		if _, err := m.ExportOwner(taskID); !errors.Is(err, ErrInvalidTaskID) {
+			t.Errorf("Task ID %q: expected ErrInvalidTaskID from ExportOwner, got %v", taskID, err)
+		}
+	}
+	if _, err := os.Stat(outside); err != nil {
+		t.Errorf("Expected file outside the exports directory to be kept: %v", err)
+	}
+}
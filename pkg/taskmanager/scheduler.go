@@ -0,0 +1,172 @@
+package taskmanager
+
+import (
+	"sync"
+	"time"
+
+	"github.com/fluxo/export-middleware/pkg/config"
+)
+
+// scheduler is the task queue. Instead of plain FIFO order it dispatches
+// the task with the highest effective priority, breaking ties by weighted
+// fair share across clients and then by arrival time. A task's effective
+// priority grows by one for every aging interval it has waited, so low
+// priority tasks cannot starve.
+type scheduler struct {
+	config *config.SchedulingConfig
+
+	mu    sync.Mutex
+	queue []*queuedTask
+	// Weighted fair share: each client's virtual time advances by 1/weight
+	// per dispatched task, and the client with the lowest virtual time wins
+	// ties. Clients becoming active start at the current virtual time so
+	// idle periods do not build up credit.
+	vtime   map[string]float64
+	current float64
+
+	positions   map[string]int // cached dispatch order, nil when stale
+	positionsAt time.Time
+
+	slots chan struct{} // one token per free queue slot
+	ready chan struct{} // one token per queued task
+	now   func() time.Time
+}
+
+// queuedTask is a task waiting in the scheduler
+type queuedTask struct {
+	task     *Task
+	priority int
+	enqueued time.Time
+}
+
+// newScheduler creates a scheduler holding up to capacity tasks
+func newScheduler(cfg *config.SchedulingConfig, capacity int) *scheduler {
+	if capacity <= 0 {
+		// An unbuffered queue cannot be expressed without a worker waiting
+		// on the other end; hold at least one task
+		capacity = 1
+	}
+	return &scheduler{
+		config: cfg,
+		vtime:  make(map[string]float64),
+		slots:  make(chan struct{}, capacity),
+		ready:  make(chan struct{}, capacity),
+		now:    time.Now,
+	}
+}
+
+// resolvePriority returns the priority a task runs with: the requested
+// priority, or the default when none was requested, capped by the client's
+// allowed maximum
+func (s *scheduler) resolvePriority(clientID string, requested int32) int {
+	priority := int(requested)
+	if priority <= 0 {
+		priority = s.config.DefaultPriority
+	}
+
+	limit := s.config.MaxPriority
+	if client, exists := s.config.Clients[clientID]; exists && client.MaxPriority > 0 {
+		limit = client.MaxPriority
+	}
+	if priority > limit {
+		priority = limit
+	}
+	if priority < config.MinPriority {
+		priority = config.MinPriority
+	}
+	return priority
+}
+
+// weight returns the fair share weight of a client
+func (s *scheduler) weight(clientID string) float64 {
+	if client, exists := s.config.Clients[clientID]; exists && client.Weight > 0 {
+		return float64(client.Weight)
+	}
+	return 1
+}
+
+// push enqueues a task, waiting up to timeout for a free slot. It returns
+// false if the queue stayed full.
+func (s *scheduler) push(task *Task, priority int, timeout time.Duration) bool {
+	select {
+	case s.slots <- struct{}{}:
+	case <-time.After(timeout):
+		return false
+	}
+
+	s.mu.Lock()
+	if !s.hasQueuedLocked(task.ClientID) && s.vtime[task.ClientID] < s.current {
+		s.vtime[task.ClientID] = s.current
+	}
+	s.queue = append(s.queue, &queuedTask{task: task, priority: priority, enqueued: s.now()})
+	s.positions = nil
+	s.mu.Unlock()
+
+	s.ready <- struct{}{}
+	return true
+}
+
+// pop removes and returns the next task to run. It must only be called
+// after receiving a token from ready.
+func (s *scheduler) pop() *Task {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	best := 0
+	for i := 1; i < len(s.queue); i++ {
+		if s.beforeLocked(s.queue[i], s.queue[best], now) {
+			best = i
+		}
+	}
+
+	next := s.queue[best]
+	s.queue = append(s.queue[:best], s.queue[best+1:]...)
+	s.positions = nil
+
+	clientID := next.task.ClientID
+	s.current = s.vtime[clientID]
+	s.vtime[clientID] += 1 / s.weight(clientID)
+
+	<-s.slots
+	return next.task
+}
+
+// len returns the number of queued tasks
+func (s *scheduler) len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return len(s.queue)
+}
+
+// effectivePriority returns the priority of a queued task including aging
+func (s *scheduler) effectivePriority(q *queuedTask, now time.Time) int {
+	if s.config.AgingInterval <= 0 {
+		return q.priority
+	}
+	return q.priority + int(now.Sub(q.enqueued)/s.config.AgingInterval)
+}
+
+// beforeLocked reports whether a should run before b.
+// The caller must hold s.mu.
+func (s *scheduler) beforeLocked(a, b *queuedTask, now time.Time) bool {
+	if pa, pb := s.effectivePriority(a, now), s.effectivePriority(b, now); pa != pb {
+		return pa > pb
+	}
+	if va, vb := s.vtime[a.task.ClientID], s.vtime[b.task.ClientID]; va != vb {
+		return va < vb
+	}
+	return a.enqueued.Before(b.enqueued)
+}
+
+// hasQueuedLocked reports whether a client has tasks in the queue.
+// The caller must hold s.mu.
+func (s *scheduler) hasQueuedLocked(clientID string) bool {
+	for _, q := range s.queue {
+		if q.task.ClientID == clientID {
+			return true
+		}
+	}
+	return false
+}
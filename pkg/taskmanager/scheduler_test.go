@@ -0,0 +1,102 @@
+package taskmanager
+
+import (
+	"testing"
+	"time"
+
+	"github.com/fluxo/export-middleware/pkg/config"
+)
+
+func newTestScheduler(cfg *config.SchedulingConfig) (*scheduler, *time.Time) {
+	s := newScheduler(cfg, 100)
+	now := time.Now()
+	s.now = func() time.Time { return now }
+	return s, &now
+}
+
+func pushTask(t *testing.T, s *scheduler, id string, clientID string, priority int) {
+	t.Helper()
+	if !s.push(&Task{ID: id, ClientID: clientID}, priority, time.Second) {
+		t.Fatalf("Failed to queue %s", id)
+	}
+}
+
+func popIDs(s *scheduler) []string {
+	var ids []string
+	for s.len() > 0 {
+		<-s.ready
+		ids = append(ids, s.pop().ID)
+	}
+	return ids
+}
+
+func assertOrder(t *testing.T, got []string, want ...string) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("Expected %v, got %v", want, got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("Expected %v, got %v", want, got)
+		}
+	}
+}
+
+func TestScheduler_PriorityThenArrival(t *testing.T) {
+	s, _ := newTestScheduler(&config.SchedulingConfig{})
+	pushTask(t, s, "batch-1", "a", 1)
+	pushTask(t, s, "user-1", "a", 5)
+	pushTask(t, s, "batch-2", "a", 1)
+	pushTask(t, s, "user-2", "a", 5)
+
+	assertOrder(t, popIDs(s), "user-1", "user-2", "batch-1", "batch-2")
+}
+
+func TestScheduler_WeightedFairShare(t *testing.T) {
+	s, _ := newTestScheduler(&config.SchedulingConfig{
+		Clients: map[string]config.ClientScheduling{"heavy": {Weight: 2}},
+	})
+	for _, id := range []string{"b1", "b2", "b3"} {
+		pushTask(t, s, id, "bulk", 5)
+	}
+	for _, id := range []string{"h1", "h2", "h3", "h4"} {
+		pushTask(t, s, id, "heavy", 5)
+	}
+
+	// bulk arrived first, then heavy gets two dispatches per bulk dispatch
+	assertOrder(t, popIDs(s), "b1", "h1", "h2", "b2", "h3", "h4", "b3")
+}
+
+func TestScheduler_Aging(t *testing.T) {
+	s, now := newTestScheduler(&config.SchedulingConfig{AgingInterval: time.Minute})
+	pushTask(t, s, "old", "a", 1)
+	*now = now.Add(5 * time.Minute)
+	pushTask(t, s, "new", "b", 5)
+
+	// Five minutes of waiting raise the old task above the new one
+	assertOrder(t, popIDs(s), "old", "new")
+}
+
+func TestScheduler_ResolvePriority(t *testing.T) {
+	s := newScheduler(&config.SchedulingConfig{
+		DefaultPriority: 5,
+		MaxPriority:     5,
+		Clients:         map[string]config.ClientScheduling{"interactive": {MaxPriority: 9}},
+	}, 1)
+
+	tests := []struct {
+		clientID  string
+		requested int32
+		want      int
+	}{
+		{"batch", 0, 5},
+		{"batch", 2, 2},
+		{"batch", 9, 5},
+		{"interactive", 9, 9},
+	}
+	for _, tt := range tests {
+		if got := s.resolvePriority(tt.clientID, tt.requested); got != tt.want {
+			t.Errorf("resolvePriority(%q, %d) = %d, want %d", tt.clientID, tt.requested, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package taskmanager
+
+import (
+	"fmt"
+	"hash/fnv"
+
+	pb "github.com/fluxo/export-middleware/proto"
+)
+
+// Batch sequence error codes reported to clients and recorded on failed tasks
+const (
+	ErrCodeSequenceGap        = "SEQUENCE_GAP"
+	ErrCodeSequenceOutOfOrder = "SEQUENCE_OUT_OF_ORDER"
+)
+
+// SequenceError describes a batch that violates the per-task sequence ordering
+type SequenceError struct {
+	Code     string
+	Expected int64
+	Received int64
+}
+
+// Error implements the error interface
+func (e *SequenceError) Error() string {
+	switch e.Code {
+	case ErrCodeSequenceGap:
+		return fmt.Sprintf("batch sequence gap: expected %d, got %d (%d batch(es) missing)",
+			e.Expected, e.Received, e.Received-e.Expected)
+	default:
+		return fmt.Sprintf("batch sequence out of order: expected %d, got %d which does not match the batch already written",
+			e.Expected, e.Received)
+	}
+}
+
+// resendWindow is how many of the most recently written batches can be
+// re-sent and recognized as duplicates
+const resendWindow = 64
+
+// batchSequencer enforces monotonic batch sequences for a single task.
+// The first batch may start at 0 or 1; every following batch must be
+// exactly one greater than the last written batch. Only the digests of the
+// last resendWindow batches are kept, so memory stays constant however many
+// batches a task receives.
+type batchSequencer struct {
+	started bool
+	first   int64
+	last    int64
+	digests [resendWindow]uint64 // digest of written batch seq at seq % resendWindow
+}
+
+// newBatchSequencer creates an empty sequencer
+func newBatchSequencer() *batchSequencer {
+	return &batchSequencer{}
+}
+
+// check reports whether the batch is a re-send of an already written batch.
+// It returns a *SequenceError for gaps and out-of-order batches.
+func (s *batchSequencer) check(seq int64, digest uint64) (bool, error) {
+	if !s.started {
+		if seq != 0 && seq != 1 {
+			return false, &SequenceError{Code: ErrCodeSequenceGap, Expected: 1, Received: seq}
+		}
+		return false, nil
+	}
+
+	expected := s.last + 1
+	switch {
+	case seq == expected:
+		return false, nil
+	case seq > expected:
+		return false, &SequenceError{Code: ErrCodeSequenceGap, Expected: expected, Received: seq}
+	default:
+		// Re-sends older than the window can no longer be verified
+		if seq >= s.first && s.last-seq < resendWindow && s.digests[seq%resendWindow] == digest {
+			return true, nil
+		}
+		return false, &SequenceError{Code: ErrCodeSequenceOutOfOrder, Expected: expected, Received: seq}
+	}
+}
+
+// commit records a batch as written
+func (s *batchSequencer) commit(seq int64, digest uint64) {
+	if !s.started {
+		s.started = true
+		s.first = seq
+	}
+	s.last = seq
+	s.digests[seq%resendWindow] = digest
+}
+
+// batchDigest computes a fingerprint of the records in a batch so that
+// exact re-sends can be told apart from different data reusing a sequence
+func batchDigest(batch *pb.DataBatch) uint64 {
+	h := fnv.New64a()
+	sep := []byte{0}
+	for _, record := range batch.Records {
+		for _, val := range record.Values {
+			h.Write([]byte(val))
+			h.Write(sep)
+		}
+		h.Write([]byte{1})
+	}
+	return h.Sum64()
+}
@@ -0,0 +1,78 @@
+package taskmanager
+
+import (
+	"errors"
+	"testing"
+
+	pb "github.com/fluxo/export-middleware/proto"
+)
+
+func TestBatchSequencer_Ordering(t *testing.T) {
+	s := newBatchSequencer()
+
+	first := &pb.DataBatch{BatchSequence: 1, Records: []*pb.Record{{Values: []string{"1", "Alice"}}}}
+	second := &pb.DataBatch{BatchSequence: 2, Records: []*pb.Record{{Values: []string{"2", "Bob"}}}}
+
+	for _, batch := range []*pb.DataBatch{first, second} {
+		duplicate, err := s.check(batch.BatchSequence, batchDigest(batch))
+		if err != nil || duplicate {
+			t.Fatalf("Batch %d should be accepted, got duplicate=%v err=%v", batch.BatchSequence, duplicate, err)
+		}
+		s.commit(batch.BatchSequence, batchDigest(batch))
+	}
+
+	// Exact re-send is dropped
+	duplicate, err := s.check(first.BatchSequence, batchDigest(first))
+	if err != nil || !duplicate {
+		t.Errorf("Expected re-send to be reported as duplicate, got duplicate=%v err=%v", duplicate, err)
+	}
+
+	// Different data reusing a written sequence is out of order
+	changed := &pb.DataBatch{BatchSequence: 1, Records: []*pb.Record{{Values: []string{"1", "Mallory"}}}}
+	_, err = s.check(changed.BatchSequence, batchDigest(changed))
+	var seqErr *SequenceError
+	if !errors.As(err, &seqErr) || seqErr.Code != ErrCodeSequenceOutOfOrder {
+		t.Errorf("Expected out-of-order error, got %v", err)
+	}
+
+	// Skipping a sequence is a gap
+	_, err = s.check(5, 0)
+	if !errors.As(err, &seqErr) || seqErr.Code != ErrCodeSequenceGap {
+		t.Fatalf("Expected gap error, got %v", err)
+	}
+	if seqErr.Expected != 3 || seqErr.Received != 5 {
+		t.Errorf("Expected gap 3->5, got %d->%d", seqErr.Expected, seqErr.Received)
+	}
+}
+
+func TestBatchSequencer_ResendWindow(t *testing.T) {
+	s := newBatchSequencer()
+	for seq := int64(1); seq <= resendWindow+10; seq++ {
+		s.commit(seq, uint64(seq))
+	}
+
+	if duplicate, err := s.check(resendWindow+10, resendWindow+10); err != nil || !duplicate {
+		t.Errorf("Expected last batch re-send to be a duplicate, got duplicate=%v err=%v", duplicate, err)
+	}
+	if duplicate, err := s.check(11, 11); err != nil || !duplicate {
+		t.Errorf("Expected oldest batch in the window to be a duplicate, got duplicate=%v err=%v", duplicate, err)
+	}
+
+	// Re-sends older than the window cannot be verified
+	var seqErr *SequenceError
+	if _, err := s.check(10, 10); !errors.As(err, &seqErr) || seqErr.Code != ErrCodeSequenceOutOfOrder {
+		t.Errorf("Expected out-of-order error outside the window, got %v", err)
+	}
+}
+
+func TestBatchSequencer_FirstBatch(t *testing.T) {
+	for _, seq := range []int64{0, 1} {
+		if _, err := newBatchSequencer().check(seq, 0); err != nil {
+			t.Errorf("First batch %d should be accepted, got %v", seq, err)
+		}
+	}
+
+	if _, err := newBatchSequencer().check(2, 0); err == nil {
+		t.Error("First batch 2 should be rejected as a gap")
+	}
+}
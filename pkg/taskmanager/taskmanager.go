@@ -3,13 +3,18 @@ package taskmanager
 import (
 	"context"
 	"fmt"
+	"os"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/fluxo/export-middleware/pkg/config"
+	"github.com/fluxo/export-middleware/pkg/encryption"
 	"github.com/fluxo/export-middleware/pkg/logger"
+	"github.com/fluxo/export-middleware/pkg/notifier"
 	"github.com/fluxo/export-middleware/pkg/oss"
 	"github.com/fluxo/export-middleware/pkg/storage"
+	"github.com/fluxo/export-middleware/pkg/webhook"
 	"github.com/fluxo/export-middleware/pkg/writer"
 	pb "github.com/fluxo/export-middleware/proto"
 	"github.com/google/uuid"
@@ -24,6 +29,10 @@ const (
 	StatusUploading
 	StatusCompleted
 	StatusFailed
+	StatusCancelled
+	StatusInterrupted
+	StatusExpired // file deleted after its retention period
+	StatusDeleted // file deleted on request
 )
 
 // Task represents an export task
@@ -33,101 +42,199 @@ type Task struct {
 	Format           pb.ExportFormat
 	Filename         string
 	Metadata         *pb.ExportMetadata
+	ClientID         string
+	Priority         int
 	RecordsProcessed int64
+	BatchesProcessed int64
 	ProgressPercent  float32
 	OSSUrl           string
+	ObjectKey        string    // set once the file is uploaded
+	ExpiresAt        time.Time // when the file is deleted, zero to keep it
 	FileSizeBytes    int64
+	Checksum         string
 	ErrorMessage     string
 	ErrorCode        string
 	StartTime        time.Time
 	CompletionTime   time.Time
 	Writer           writer.Writer
-	LocalPath        string
+	LocalPath        string       // empty when streaming without a local file
+	stream           *oss.Stream  // nil unless the file is uploaded while written
+	dataKey          []byte       // client-side encryption key, never persisted or logged
+	writtenBytes     atomic.Int64 // file size after the latest batch, for quotas
+	sequencer        *batchSequencer
+	quota            *taskQuota // nil when quotas are disabled
+	lastNotified     time.Time
+	progress         progressTracker
+	ready            chan struct{} // closed once the writer is set up or the task ended
+	readyOnce        sync.Once
+	done             chan struct{} // closed when the task reaches a terminal status
+	doneOnce         sync.Once
 	mu               sync.RWMutex
 }
 
 // Manager coordinates export tasks with concurrency control
 type Manager struct {
-	config         *config.Config
-	logger         *logger.Logger
-	storage        *storage.Manager
-	ossUploader    *oss.Uploader
-	tasks          map[string]*Task
-	taskQueue      chan *Task
-	activeTasks    int
-	maxConcurrent  int
-	mu             sync.RWMutex
-	shutdownCtx    context.Context
-	shutdownCancel context.CancelFunc
-	wg             sync.WaitGroup
+	cfg             atomic.Pointer[config.Config] // replaced on config reload
+	logger          *logger.Logger
+	storage         *storage.Manager
+	ossUploader     *oss.Uploader
+	webhook         *webhook.Notifier
+	events          *notifier.Dispatcher
+	tasks           map[string]*Task
+	requests        map[string]string        // client + request ID -> latest task ID
+	usage           map[string]*clientUsage  // by client ID
+	listSnapshots   map[string]*listSnapshot // by ID, for paging ListTasks
+	watchHub        *watchHub
+	scheduler       *scheduler
+	draining        bool // no new tasks are accepted
+	abortUploads    context.CancelCauseFunc
+	uploadCtx       context.Context // cancelled to abort uploads at the drain deadline
+	activeTasks     int
+	running         map[string]time.Time // task ID -> time the task took its slot
+	recentDurations []time.Duration      // slot hold times of recently finished tasks
+	maxConcurrent   int
+	workerStops     []chan struct{} // one per worker, closed to retire it
+	nextWorkerID    int
+	mu              sync.RWMutex
+	shutdownCtx     context.Context
+	shutdownCancel  context.CancelFunc
+	wg              sync.WaitGroup
+	callbackCtx     context.Context // cancelled when Shutdown stops waiting for callbacks
+	stopCallbacks   context.CancelFunc
+	callbacks       sync.WaitGroup // callbacks being delivered
+	callbacksClosed bool           // set by Shutdown, no callbacks are sent after it
 }
 
 // NewManager creates a new task manager
-func NewManager(cfg *config.Config, log *logger.Logger, storageMgr *storage.Manager, ossUploader *oss.Uploader) *Manager {
+func NewManager(cfg *config.Config, log *logger.Logger, storageMgr *storage.Manager, ossUploader *oss.Uploader, webhookNotifier *webhook.Notifier, events *notifier.Dispatcher) *Manager {
 	ctx, cancel := context.WithCancel(context.Background())
 
 	m := &Manager{
-		config:         cfg,
 		logger:         log,
 		storage:        storageMgr,
 		ossUploader:    ossUploader,
+		webhook:        webhookNotifier,
+		events:         events,
 		tasks:          make(map[string]*Task),
-		taskQueue:      make(chan *Task, cfg.Concurrency.TaskQueueSize),
-		maxConcurrent:  cfg.Concurrency.MaxConcurrentTasks,
+		requests:       make(map[string]string),
+		usage:          make(map[string]*clientUsage),
+		watchHub:       newWatchHub(),
+		scheduler:      newScheduler(&cfg.Scheduling, cfg.Concurrency.TaskQueueSize),
+		running:        make(map[string]time.Time),
 		shutdownCtx:    ctx,
 		shutdownCancel: cancel,
 	}
+	m.uploadCtx, m.abortUploads = context.WithCancelCause(context.Background())
+	m.callbackCtx, m.stopCallbacks = context.WithCancel(context.Background())
+	m.cfg.Store(cfg)
 
 	// Start worker pool
-	for i := 0; i < m.maxConcurrent; i++ {
+	m.mu.Lock()
+	m.resizeWorkersLocked(cfg.Concurrency.MaxConcurrentTasks)
+	m.mu.Unlock()
+
+	m.wg.Add(1)
+	go m.evictLoop()
+
+	if cfg.Retention.JanitorInterval > 0 {
 		m.wg.Add(1)
-		go m.worker(i)
+		go m.janitorLoop(cfg.Retention.JanitorInterval)
 	}
 
 	return m
 }
 
-// CreateTask creates a new export task
-func (m *Manager) CreateTask(ctx context.Context, metadata *pb.ExportMetadata) (*Task, error) {
+// config returns the current configuration
+func (m *Manager) config() *config.Config {
+	return m.cfg.Load()
+}
+
+// CreateTask creates a new export task. Creation is idempotent per client
+// and request ID within the configured window: if a matching task exists
+// and has not failed, it is returned with created set to false.
+func (m *Manager) CreateTask(ctx context.Context, metadata *pb.ExportMetadata) (task *Task, created bool, err error) {
 	taskID := uuid.New().String()
+	clientID := ClientIDFromContext(ctx)
+	key := requestKey(clientID, metadata.RequestId)
+
+	m.mu.Lock()
+	if m.draining {
+		m.mu.Unlock()
+		return nil, false, ErrDraining
+	}
+	if existing := m.findRequestLocked(key); existing != nil && m.reusable(existing) {
+		m.mu.Unlock()
+		m.logger.WithContext(ctx).WithTaskID(existing.ID).WithComponent("task_manager").LogInfo(
+			"TaskReused",
+			"Duplicate request returned existing task",
+			logger.Fields{"request_id": metadata.RequestId, "client_id": clientID},
+		)
+		return existing, false, nil
+	}
+
+	quota, err := m.checkCreateQuotaLocked(clientID, metadata)
+	if err != nil {
+		m.mu.Unlock()
+		m.logger.WithContext(ctx).WithComponent("task_manager").LogWarn(
+			"QuotaExceeded",
+			"Task rejected by client quota",
+			logger.Fields{"client_id": clientID, "error": err.Error()},
+		)
+		return nil, false, err
+	}
 
-	task := &Task{
+	task = &Task{
 		ID:        taskID,
 		Status:    StatusQueued,
 		Format:    metadata.Format,
 		Filename:  metadata.Filename,
 		Metadata:  metadata,
+		ClientID:  clientID,
+		Priority:  m.scheduler.resolvePriority(clientID, metadata.GetPriority()),
 		StartTime: time.Now(),
+		sequencer: newBatchSequencer(),
+		quota:     quota,
+		ready:     make(chan struct{}),
+		done:      make(chan struct{}),
 	}
 
-	m.mu.Lock()
 	m.tasks[taskID] = task
+	m.requests[key] = taskID
+	m.addUsageLocked(task)
 	m.mu.Unlock()
 
 	contextLogger := m.logger.WithContext(ctx).WithTaskID(taskID).WithComponent("task_manager")
 	contextLogger.LogTaskCreated(
 		"Export task created",
 		logger.Fields{
-			"format":   metadata.Format.String(),
-			"filename": metadata.Filename,
+			"format":     metadata.Format.String(),
+			"filename":   metadata.Filename,
+			"request_id": metadata.RequestId,
+			"client_id":  task.ClientID,
+			"priority":   task.Priority,
 		},
 	)
+	m.publishEvent(task, logger.EventTaskCreated)
 
 	// Try to enqueue task
-	select {
-	case m.taskQueue <- task:
-		contextLogger.LogInfo("TaskQueued", "Task queued for processing", logger.Fields{"queue_size": len(m.taskQueue)})
-	case <-time.After(m.config.Concurrency.QueueTimeout):
+	if m.scheduler.push(task, task.Priority, m.config().Concurrency.QueueTimeout) {
+		contextLogger.LogInfo("TaskQueued", "Task queued for processing", logger.Fields{"queue_size": m.scheduler.len()})
+	} else {
 		task.mu.Lock()
 		task.Status = StatusFailed
 		task.ErrorCode = "QUEUE_TIMEOUT"
 		task.ErrorMessage = "Task queue is full, timeout waiting for slot"
+		task.CompletionTime = time.Now()
 		task.mu.Unlock()
-		contextLogger.LogWarn("TaskQueueFull", "Task queue timeout", logger.Fields{"timeout": m.config.Concurrency.QueueTimeout})
-		return nil, fmt.Errorf("task queue is full")
+		m.endTask(task)
+		m.notifyWatchers(task)
+		m.dispatchCallback(task)
+		m.publishEvent(task, logger.EventTaskFailed)
+		contextLogger.LogWarn("TaskQueueFull", "Task queue timeout", logger.Fields{"timeout": m.config().Concurrency.QueueTimeout})
+		return nil, false, fmt.Errorf("task queue is full")
 	}
 
-	return task, nil
+	return task, true, nil
 }
 
 // GetTaskStatus retrieves the status of a task
@@ -140,9 +247,12 @@ func (m *Manager) GetTaskStatus(taskID string) (*pb.TaskStatusResponse, error) {
 		return nil, fmt.Errorf("task not found: %s", taskID)
 	}
 
-	task.mu.RLock()
-	defer task.mu.RUnlock()
+	return m.buildStatus(task), nil
+}
 
+// buildStatus builds the status response of a task
+func (m *Manager) buildStatus(task *Task) *pb.TaskStatusResponse {
+	task.mu.RLock()
 	status := &pb.TaskStatusResponse{
 		TaskId:           task.ID,
 		Status:           m.convertStatus(task.Status),
@@ -152,119 +262,221 @@ func (m *Manager) GetTaskStatus(taskID string) (*pb.TaskStatusResponse, error) {
 		ProgressPercent:  task.ProgressPercent,
 		OssUrl:           task.OSSUrl,
 		FileSizeBytes:    task.FileSizeBytes,
+		ChecksumSha256:   task.Checksum,
 		ErrorMessage:     task.ErrorMessage,
 		ErrorCode:        task.ErrorCode,
 		StartTime:        task.StartTime.Unix(),
+		RequestId:        task.Metadata.GetRequestId(),
+		ClientId:         task.ClientID,
+		Priority:         int32(task.Priority),
+
+		EstimatedTimeRemaining: task.estimateTimeRemaining(),
 	}
 
 	if !task.CompletionTime.IsZero() {
 		status.CompletionTime = task.CompletionTime.Unix()
 	}
+	if !task.ExpiresAt.IsZero() {
+		status.ExpiresAt = task.ExpiresAt.Unix()
+	}
+	encrypted := task.dataKey != nil
+	task.mu.RUnlock()
 
-	// Estimate time remaining if processing
-	if task.Status == StatusProcessing && task.RecordsProcessed > 0 {
-		elapsed := time.Since(task.StartTime).Seconds()
-		recordsPerSecond := float64(task.RecordsProcessed) / elapsed
-		if recordsPerSecond > 0 && task.ProgressPercent < 100 {
-			remainingRecords := float64(task.RecordsProcessed) * (100/float64(task.ProgressPercent) - 1)
-			status.EstimatedTimeRemaining = int64(remainingRecords / recordsPerSecond)
-		}
+	status.Encryption = m.encryptionInfo(task.ClientID, encrypted)
+
+	// Queue details need the scheduler and manager locks, which must not
+	// be taken while holding the task lock
+	if status.Status == pb.TaskStatus_TASK_STATUS_QUEUED {
+		m.fillQueueInfo(status)
 	}
 
-	return status, nil
+	return status
 }
 
-// worker processes tasks from the queue
-func (m *Manager) worker(id int) {
+// worker takes tasks from the scheduler and holds a concurrency slot for
+// each task until it completes, fails or is cancelled. A retired worker
+// exits once its current task is done.
+func (m *Manager) worker(id int, stop <-chan struct{}) {
 	defer m.wg.Done()
 
 	for {
 		select {
 		case <-m.shutdownCtx.Done():
 			return
-		case task := <-m.taskQueue:
-			m.processTask(task)
+		case <-stop:
+			return
+		case <-m.scheduler.ready:
+			task := m.scheduler.pop()
+
+			started := time.Now()
+			m.mu.Lock()
+			m.activeTasks++
+			m.running[task.ID] = started
+			m.mu.Unlock()
+
+			ran := m.processTask(task)
+
+			select {
+			case <-task.done:
+			case <-m.shutdownCtx.Done():
+			}
+
+			m.mu.Lock()
+			m.activeTasks--
+			delete(m.running, task.ID)
+			if ran {
+				m.recordDurationLocked(time.Since(started))
+			}
+			m.mu.Unlock()
 		}
 	}
 }
 
-// processTask processes a single export task
-func (m *Manager) processTask(task *Task) {
+// processTask sets up the writer of a task taken from the queue. It
+// returns false if the task was cancelled while queued.
+func (m *Manager) processTask(task *Task) bool {
 	ctx := context.Background()
 	contextLogger := m.logger.WithContext(ctx).WithTaskID(task.ID).WithComponent("task_manager")
 
-	// Update status to processing
+	// Update status to processing unless the task was cancelled while queued
 	task.mu.Lock()
+	if task.Status != StatusQueued {
+		task.mu.Unlock()
+		return false
+	}
 	task.Status = StatusProcessing
 	task.mu.Unlock()
+	m.notifyWatchers(task)
 
-	m.mu.Lock()
-	m.activeTasks++
-	m.mu.Unlock()
+	contextLogger.LogInfo(logger.EventTaskStarted, "Task processing started", nil)
+	m.publishEvent(task, logger.EventTaskStarted)
 
-	defer func() {
-		m.mu.Lock()
-		m.activeTasks--
-		m.mu.Unlock()
-	}()
+	// CSV files can be uploaded while they are written
+	streamingMode := m.config().OSS.StreamingUpload
+	streaming := task.Format == pb.ExportFormat_FORMAT_CSV && streamingMode != config.StreamingUploadOff
 
-	contextLogger.LogInfo("TaskStarted", "Task processing started", nil)
+	// Client-side encryption uses a new data key per export
+	if m.config().OSS.Encryption.ClientSide {
+		dataKey, err := encryption.GenerateKey()
+		if err != nil {
+			m.failTask(task, "ENCRYPTION_ERROR", fmt.Sprintf("Failed to create data key: %v", err), contextLogger)
+			return true
+		}
+		task.mu.Lock()
+		task.dataKey = dataKey
+		task.mu.Unlock()
+	}
 
 	// Create temporary file
-	localPath, err := m.storage.CreateTempFile(task.ID, task.Filename)
-	if err != nil {
-		m.failTask(task, "STORAGE_ERROR", fmt.Sprintf("Failed to create temp file: %v", err), contextLogger)
-		return
+	var localPath string
+	if !streaming || streamingMode == config.StreamingUploadDisk {
+		var err error
+		localPath, err = m.storage.CreateTempFile(task.ID, task.Filename)
+		if err != nil {
+			m.failTask(task, "STORAGE_ERROR", fmt.Sprintf("Failed to create temp file: %v", err), contextLogger)
+			return true
+		}
+		task.mu.Lock()
+		task.LocalPath = localPath
+		task.mu.Unlock()
 	}
-	task.mu.Lock()
-	task.LocalPath = localPath
-	task.mu.Unlock()
 
 	// Initialize writer based on format
 	var w writer.Writer
 	switch task.Format {
 	case pb.ExportFormat_FORMAT_CSV:
-		w = writer.NewCSVWriter()
+		if !streaming {
+			w = writer.NewCSVWriter()
+			break
+		}
+		stream, err := m.ossUploader.NewStream(m.uploadCtx, m.objectInfo(task))
+		if err != nil {
+			m.failTask(task, oss.ErrorCode(err), fmt.Sprintf("Failed to start upload: %v", err), contextLogger)
+			return true
+		}
+		task.mu.Lock()
+		task.stream = stream
+		task.mu.Unlock()
+		w = writer.NewCSVStreamWriter(stream)
 	case pb.ExportFormat_FORMAT_EXCEL:
 		w = writer.NewExcelWriter()
 	default:
 		m.failTask(task, "INVALID_FORMAT", "Unsupported export format", contextLogger)
-		return
+		return true
 	}
 
 	if err := w.Initialize(ctx, task.Metadata, localPath); err != nil {
 		m.failTask(task, "WRITER_INIT_ERROR", fmt.Sprintf("Failed to initialize writer: %v", err), contextLogger)
-		return
+		return true
 	}
 
 	task.mu.Lock()
 	task.Writer = w
 	task.mu.Unlock()
+	task.markReady()
 
-	contextLogger.LogInfo("WriterInitialized", "Format writer initialized", logger.Fields{"format": task.Format.String()})
+	contextLogger.LogInfo("WriterInitialized", "Format writer initialized", logger.Fields{
+		"format":           task.Format.String(),
+		"streaming_upload": streaming,
+	})
+	return true
 }
 
-// UpdateTaskProgress updates the progress of a task
-func (m *Manager) UpdateTaskProgress(taskID string, recordsProcessed int64, progressPercent float32) {
-	m.mu.RLock()
-	task, exists := m.tasks[taskID]
-	m.mu.RUnlock()
+// WaitReady blocks until a worker has taken the task from the queue and set
+// up its writer. It returns an error if the task ended before that, or if
+// ctx is done first.
+func (m *Manager) WaitReady(ctx context.Context, task *Task) error {
+	select {
+	case <-task.ready:
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-m.shutdownCtx.Done():
+		return fmt.Errorf("task manager is shutting down")
+	}
 
-	if !exists {
-		return
+	task.mu.RLock()
+	defer task.mu.RUnlock()
+
+	if task.Writer == nil {
+		return fmt.Errorf("task ended before it started: %s", task.ErrorMessage)
 	}
+	return nil
+}
+
+// CheckBatchSequence validates the sequence of an incoming batch.
+// It returns true if the batch is an exact re-send of a batch that was
+// already written and should be dropped, or a *SequenceError if the batch
+// would leave a gap or arrived out of order.
+func (m *Manager) CheckBatchSequence(task *Task, batch *pb.DataBatch) (bool, error) {
+	task.mu.RLock()
+	defer task.mu.RUnlock()
+
+	return task.sequencer.check(batch.BatchSequence, batchDigest(batch))
+}
 
+// CommitBatch records a batch as written after its records were persisted
+func (m *Manager) CommitBatch(task *Task, batch *pb.DataBatch) {
 	task.mu.Lock()
-	task.RecordsProcessed = recordsProcessed
-	task.ProgressPercent = progressPercent
-	task.mu.Unlock()
+	defer task.mu.Unlock()
+
+	task.sequencer.commit(batch.BatchSequence, batchDigest(batch))
+}
+
+// FailTask marks a task as failed and releases its resources
+func (m *Manager) FailTask(task *Task, errorCode string, errorMsg string) {
+	contextLogger := m.logger.WithContext(context.Background()).WithTaskID(task.ID).WithComponent("task_manager")
+	m.failTask(task, errorCode, errorMsg, contextLogger)
 }
 
 // FinalizeTask finalizes the file and uploads to OSS
 func (m *Manager) FinalizeTask(task *Task) error {
-	ctx := context.Background()
+	ctx := m.uploadCtx
 	contextLogger := m.logger.WithContext(ctx).WithTaskID(task.ID).WithComponent("task_manager")
 
+	if task.Finished() {
+		return fmt.Errorf("task %s already finished", task.ID)
+	}
+
 	// Finalize writer
 	metadata, err := task.Writer.Finalize()
 	if err != nil {
@@ -284,24 +496,88 @@ func (m *Manager) FinalizeTask(task *Task) error {
 
 	// Update task
 	task.mu.Lock()
+	if task.finishedLocked() {
+		task.mu.Unlock()
+		return fmt.Errorf("task %s already finished", task.ID)
+	}
 	task.Status = StatusUploading
 	task.FileSizeBytes = metadata.Size
+	task.writtenBytes.Store(metadata.Size)
+	task.Checksum = metadata.Checksum
 	task.RecordsProcessed = metadata.RowCount
+	task.ProgressPercent = writePhaseWeight
 	task.mu.Unlock()
-
-	// Upload to OSS
-	result, err := m.ossUploader.Upload(ctx, task.ID, metadata.Path)
+	m.notifyWatchers(task)
+	m.publishEvent(task, logger.EventOSSUploadStarted)
+
+	// Upload to OSS, or finish the upload that ran while writing
+	var result *oss.UploadResult
+	upload := func() (*oss.UploadResult, error) {
+		return m.ossUploader.Upload(ctx, m.objectInfo(task), metadata.Path, func(uploaded int64, total int64) {
+			m.updateUploadProgress(task, uploaded, total)
+		})
+	}
+	if task.stream != nil {
+		result, err = task.stream.Close(metadata.Checksum, metadata.RowCount)
+		// A streamed upload that failed, or whose object does not match,
+		// is done again from the local copy, when there is one. The
+		// writer detached the stream and completed the copy.
+		if err != nil && metadata.Path != "" && ctx.Err() == nil {
+			contextLogger.LogWarn("OSSStreamFallback", "Streaming upload failed, uploading the local copy", logger.Fields{
+				"error":      err.Error(),
+				"error_code": oss.ErrorCode(err),
+			})
+			result, err = upload()
+		}
+	} else {
+		result, err = upload()
+	}
 	if err != nil {
-		m.failTask(task, "UPLOAD_ERROR", fmt.Sprintf("Failed to upload to OSS: %v", err), contextLogger)
+		m.failTask(task, oss.ErrorCode(err), fmt.Sprintf("Failed to upload to OSS: %v", err), contextLogger)
 		return err
 	}
 
+	return m.completeUpload(task, result, contextLogger)
+}
+
+// completeUpload marks a task whose file was uploaded as completed, records
+// its object for retention and removes the local file
+func (m *Manager) completeUpload(task *Task, result *oss.UploadResult, contextLogger *logger.ContextLogger) error {
 	// Update task as completed
 	task.mu.Lock()
+	if task.finishedLocked() {
+		task.mu.Unlock()
+		// Interrupted or cancelled during the upload: nobody will
+		// download the object
+		if err := m.ossUploader.DeleteObject(result.ObjectKey); err != nil {
+			contextLogger.LogWarn("OSSDeleteError", "Failed to delete object of finished task", logger.Fields{"object_key": result.ObjectKey, "error": err.Error()})
+		}
+		return fmt.Errorf("task %s already finished", task.ID)
+	}
 	task.Status = StatusCompleted
+	task.ProgressPercent = 100
 	task.OSSUrl = result.SignedURL
+	task.ObjectKey = result.ObjectKey
 	task.CompletionTime = time.Now()
+	if period := m.retentionPeriod(task.Metadata.GetRetentionSeconds()); period > 0 {
+		task.ExpiresAt = task.CompletionTime.Add(period)
+	}
+	record := &exportRecord{
+		TaskID:      task.ID,
+		ClientID:    task.ClientID,
+		ObjectKey:   task.ObjectKey,
+		CompletedAt: task.CompletionTime,
+		ExpiresAt:   task.ExpiresAt,
+	}
+	records := task.RecordsProcessed
 	task.mu.Unlock()
+	if err := m.recordExport(record); err != nil {
+		contextLogger.LogWarn("ExportRecordError", "Failed to record uploaded object, it will not expire", logger.Fields{"error": err.Error()})
+	}
+	m.endTask(task)
+	m.notifyWatchers(task)
+	m.dispatchCallback(task)
+	m.publishEvent(task, logger.EventTaskCompleted)
 
 	duration := time.Since(task.StartTime)
 	contextLogger.LogTaskCompleted(
@@ -310,27 +586,100 @@ func (m *Manager) FinalizeTask(task *Task) error {
 		logger.Fields{
 			"oss_url":     result.SignedURL,
 			"file_size":   result.Size,
-			"records":     metadata.RowCount,
+			"records":     records,
 			"duration_ms": duration.Milliseconds(),
 		},
 	)
 
 	// Cleanup temp file
-	if err := m.storage.DeleteFile(task.ID); err != nil {
-		contextLogger.LogWarn("TempFileCleanupError", "Failed to cleanup temp file", logger.Fields{"error": err.Error()})
+	if task.LocalPath != "" {
+		if err := m.storage.DeleteFile(task.ID); err != nil {
+			contextLogger.LogWarn("TempFileCleanupError", "Failed to cleanup temp file", logger.Fields{"error": err.Error()})
+		}
 	}
 
 	return nil
 }
 
-// failTask marks a task as failed
+// objectInfo describes the object a task's file is uploaded as
+func (m *Manager) objectInfo(task *Task) *oss.ObjectInfo {
+	extension := "csv"
+	if task.Format == pb.ExportFormat_FORMAT_EXCEL {
+		extension = "xlsx"
+	}
+
+	task.mu.RLock()
+	defer task.mu.RUnlock()
+
+	return &oss.ObjectInfo{
+		TaskID:      task.ID,
+		RequestID:   task.Metadata.GetRequestId(),
+		ClientID:    task.ClientID,
+		Filename:    task.Filename,
+		Extension:   extension,
+		RecordCount: task.RecordsProcessed,
+		Checksum:    task.Checksum,
+		DataKey:     task.dataKey,
+	}
+}
+
+// encryptionInfo describes how the exports of a client are encrypted, or
+// returns nil when they are not. The data key is never included; see
+// DataKey.
+func (m *Manager) encryptionInfo(clientID string, clientSide bool) *pb.EncryptionInfo {
+	cfg := m.config().OSS.Encryption
+	if cfg.ServerSide == "" && !clientSide {
+		return nil
+	}
+
+	info := &pb.EncryptionInfo{ServerSide: cfg.ServerSide}
+	if cfg.ServerSide == config.ServerSideEncryptionKMS {
+		info.KmsKeyId = cfg.KMSKeyFor(clientID)
+	}
+	if clientSide {
+		info.ClientSide = encryption.Algorithm
+	}
+	return info
+}
+
+// DataKey returns the client-side encryption key of a task. The key is
+// only returned to the client that created the task, not to admins: nil
+// is returned for other clients, callers without a client ID and tasks
+// without a key.
+func (m *Manager) DataKey(taskID, clientID string) []byte {
+	m.mu.RLock()
+	task, exists := m.tasks[taskID]
+	m.mu.RUnlock()
+	if !exists {
+		return nil
+	}
+
+	task.mu.RLock()
+	defer task.mu.RUnlock()
+
+	if clientID == "" || task.ClientID != clientID {
+		return nil
+	}
+	return task.dataKey
+}
+
+// failTask marks a task as failed. Tasks that already finished, for
+// example because they were interrupted, keep their status.
 func (m *Manager) failTask(task *Task, errorCode string, errorMsg string, contextLogger *logger.ContextLogger) {
 	task.mu.Lock()
+	if task.finishedLocked() {
+		task.mu.Unlock()
+		return
+	}
 	task.Status = StatusFailed
 	task.ErrorCode = errorCode
 	task.ErrorMessage = errorMsg
 	task.CompletionTime = time.Now()
 	task.mu.Unlock()
+	m.endTask(task)
+	m.notifyWatchers(task)
+	m.dispatchCallback(task)
+	m.publishEvent(task, logger.EventTaskFailed)
 
 	contextLogger.LogTaskFailed(
 		"Export task failed",
@@ -340,6 +689,45 @@ func (m *Manager) failTask(task *Task, errorCode string, errorMsg string, contex
 	)
 
 	// Cleanup
+	if task.stream != nil {
+		task.stream.Abort()
+	}
+	if task.Writer != nil {
+		task.Writer.Cleanup()
+	}
+	if task.LocalPath != "" {
+		m.ossUploader.DiscardUpload(task.LocalPath)
+		m.storage.DeleteFile(task.ID)
+	}
+}
+
+// CancelTask marks a task as cancelled, typically because its client
+// stream was aborted before all data was received
+func (m *Manager) CancelTask(task *Task, reason string) {
+	task.mu.Lock()
+	if task.finishedLocked() {
+		task.mu.Unlock()
+		return
+	}
+	task.Status = StatusCancelled
+	task.ErrorCode = "CANCELLED"
+	task.ErrorMessage = reason
+	task.CompletionTime = time.Now()
+	task.mu.Unlock()
+	m.endTask(task)
+	m.notifyWatchers(task)
+	m.dispatchCallback(task)
+	m.publishEvent(task, logger.EventTaskCancelled)
+
+	m.logger.WithContext(context.Background()).WithTaskID(task.ID).WithComponent("task_manager").LogTaskCancelled(
+		"Export task cancelled",
+		logger.Fields{"reason": reason},
+	)
+
+	// Cleanup
+	if task.stream != nil {
+		task.stream.Abort()
+	}
 	if task.Writer != nil {
 		task.Writer.Cleanup()
 	}
@@ -361,6 +749,14 @@ func (m *Manager) convertStatus(status TaskStatus) pb.TaskStatus {
 		return pb.TaskStatus_TASK_STATUS_COMPLETED
 	case StatusFailed:
 		return pb.TaskStatus_TASK_STATUS_FAILED
+	case StatusCancelled:
+		return pb.TaskStatus_TASK_STATUS_CANCELLED
+	case StatusInterrupted:
+		return pb.TaskStatus_TASK_STATUS_INTERRUPTED
+	case StatusExpired:
+		return pb.TaskStatus_TASK_STATUS_EXPIRED
+	case StatusDeleted:
+		return pb.TaskStatus_TASK_STATUS_DELETED
 	default:
 		return pb.TaskStatus_TASK_STATUS_UNSPECIFIED
 	}
@@ -378,13 +774,21 @@ func (m *Manager) Shutdown(ctx context.Context) error {
 		close(done)
 	}()
 
+	var err error
 	select {
 	case <-done:
-		m.logger.Info("Task manager shutdown complete")
-		return nil
 	case <-ctx.Done():
-		return fmt.Errorf("shutdown timeout")
+		err = fmt.Errorf("shutdown timeout")
+	}
+
+	// Deliver the callbacks of tasks that ended, including those the
+	// workers just finished
+	m.drainCallbacks(ctx)
+
+	if err == nil {
+		m.logger.Info("Task manager shutdown complete")
 	}
+	return err
 }
 
 // GetTask retrieves a task by ID
@@ -398,3 +802,76 @@ func (m *Manager) GetTask(taskID string) (*Task, error) {
 	}
 	return task, nil
 }
+
+// Finished reports whether the task reached a terminal status
+func (t *Task) Finished() bool {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	return t.finishedLocked()
+}
+
+// finishedLocked reports whether the task reached a terminal status.
+// The caller must hold t.mu.
+func (t *Task) finishedLocked() bool {
+	switch t.Status {
+	case StatusCompleted, StatusFailed, StatusCancelled, StatusInterrupted, StatusExpired, StatusDeleted:
+		return true
+	default:
+		return false
+	}
+}
+
+// markReady signals that the writer is set up or that the task ended
+// before it could be
+func (t *Task) markReady() {
+	t.readyOnce.Do(func() { close(t.ready) })
+}
+
+// endTask signals that a task reached a terminal status and releases its
+// concurrency slot and its client's count of running tasks
+func (m *Manager) endTask(task *Task) {
+	if !task.markDone() {
+		return
+	}
+	m.scheduler.invalidate()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if counters := m.usage[task.ClientID]; counters != nil && counters.active > 0 {
+		counters.active--
+	}
+}
+
+// markDone signals that the task reached a terminal status. It reports
+// whether this call ended the task.
+func (t *Task) markDone() bool {
+	t.markReady()
+	ended := false
+	t.doneOnce.Do(func() {
+		close(t.done)
+		ended = true
+	})
+	return ended
+}
+
+// BytesWritten returns the current size of the task's local file, or the
+// bytes streamed so far when there is none
+func (t *Task) BytesWritten() int64 {
+	t.mu.RLock()
+	localPath, stream := t.LocalPath, t.stream
+	t.mu.RUnlock()
+
+	if localPath == "" {
+		if stream != nil {
+			return stream.Written()
+		}
+		return 0
+	}
+	info, err := os.Stat(localPath)
+	if err != nil {
+		return 0
+	}
+	return info.Size()
+}
@@ -0,0 +1,39 @@
+package taskmanager
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/fluxo/export-middleware/pkg/config"
+	"github.com/fluxo/export-middleware/pkg/logger"
+)
+
+// newTestManager creates a manager through NewManager from the default
+// configuration changed by options. It starts no workers, so tasks pushed to
+// the scheduler stay queued, and it is shut down when the test ends.
+func newTestManager(t *testing.T, options ...func(*config.Config)) *Manager {
+	t.Helper()
+
+	log, err := logger.New("error", "json", "stderr", false)
+	if err != nil {
+		t.Fatalf("Failed to create logger: %v", err)
+	}
+
+	cfg := config.DefaultConfig()
+	cfg.Storage.TempDirectory = t.TempDir()
+	for _, option := range options {
+		option(cfg)
+	}
+	workers := cfg.Concurrency.MaxConcurrentTasks
+	cfg.Concurrency.MaxConcurrentTasks = 0
+
+	m := NewManager(cfg, log, nil, nil, nil, nil)
+	m.maxConcurrent = workers
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		m.Shutdown(ctx)
+	})
+	return m
+}
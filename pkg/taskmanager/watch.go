@@ -0,0 +1,144 @@
+package taskmanager
+
+import (
+	"sync"
+	"time"
+
+	pb "github.com/fluxo/export-middleware/proto"
+)
+
+// watchBufferSize is the number of undelivered updates kept per watcher.
+// When a watcher falls behind, the oldest update is dropped since every
+// update is a full snapshot of the task.
+const watchBufferSize = 16
+
+// watcher receives status snapshots for a single task
+type watcher struct {
+	ch     chan *pb.TaskStatusResponse
+	closed bool
+}
+
+// watchHub fans out task status snapshots to watchers
+type watchHub struct {
+	mu       sync.Mutex
+	watchers map[string]map[*watcher]struct{} // taskID -> watchers
+}
+
+// newWatchHub creates an empty hub
+func newWatchHub() *watchHub {
+	return &watchHub{
+		watchers: make(map[string]map[*watcher]struct{}),
+	}
+}
+
+// WatchTask subscribes to status updates of a task. The returned channel
+// first yields the current status, then one snapshot per status transition
+// and throttled progress updates. It is closed after a terminal status has
+// been delivered. The returned function cancels the subscription.
+func (m *Manager) WatchTask(taskID string) (<-chan *pb.TaskStatusResponse, func(), error) {
+	hub := m.watchHub
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+
+	// Read the current status under the hub lock so that no transition can
+	// slip in between the snapshot and the registration
+	current, err := m.GetTaskStatus(taskID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	w := &watcher{ch: make(chan *pb.TaskStatusResponse, watchBufferSize)}
+	w.ch <- current
+
+	if isTerminal(current.Status) {
+		w.closed = true
+		close(w.ch)
+		return w.ch, func() {}, nil
+	}
+
+	if hub.watchers[taskID] == nil {
+		hub.watchers[taskID] = make(map[*watcher]struct{})
+	}
+	hub.watchers[taskID][w] = struct{}{}
+
+	cancel := func() {
+		hub.mu.Lock()
+		defer hub.mu.Unlock()
+
+		delete(hub.watchers[taskID], w)
+		if len(hub.watchers[taskID]) == 0 {
+			delete(hub.watchers, taskID)
+		}
+		if !w.closed {
+			w.closed = true
+			close(w.ch)
+		}
+	}
+
+	return w.ch, cancel, nil
+}
+
+// notifyWatchers publishes the current status of a task to its watchers
+func (m *Manager) notifyWatchers(task *Task) {
+	task.mu.Lock()
+	task.lastNotified = time.Now()
+	task.mu.Unlock()
+
+	hub := m.watchHub
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+
+	watchers := hub.watchers[task.ID]
+	if len(watchers) == 0 {
+		return
+	}
+
+	status, err := m.GetTaskStatus(task.ID)
+	if err != nil {
+		return
+	}
+
+	for w := range watchers {
+		select {
+		case w.ch <- status:
+		default:
+			// Drop the oldest snapshot to make room for the latest one
+			select {
+			case <-w.ch:
+			default:
+			}
+			w.ch <- status
+		}
+	}
+
+	if isTerminal(status.Status) {
+		for w := range watchers {
+			w.closed = true
+			close(w.ch)
+		}
+		delete(hub.watchers, task.ID)
+	}
+}
+
+// notifyProgress publishes a progress update unless one was published
+// within the progress interval
+func (m *Manager) notifyProgress(task *Task) {
+	task.mu.RLock()
+	throttled := time.Since(task.lastNotified) < m.config().Server.ProgressInterval
+	task.mu.RUnlock()
+
+	if !throttled {
+		m.notifyWatchers(task)
+	}
+}
+
+// isTerminal reports whether a task status is final
+func isTerminal(status pb.TaskStatus) bool {
+	switch status {
+	case pb.TaskStatus_TASK_STATUS_COMPLETED, pb.TaskStatus_TASK_STATUS_FAILED, pb.TaskStatus_TASK_STATUS_CANCELLED, pb.TaskStatus_TASK_STATUS_INTERRUPTED,
+		pb.TaskStatus_TASK_STATUS_EXPIRED, pb.TaskStatus_TASK_STATUS_DELETED:
+		return true
+	default:
+		return false
+	}
+}
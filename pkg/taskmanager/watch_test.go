@@ -0,0 +1,72 @@
+package taskmanager
+
+import (
+	"testing"
+	"time"
+
+	pb "github.com/fluxo/export-middleware/proto"
+)
+
+func TestWatchTask_Transitions(t *testing.T) {
+	m := newTestManager(t)
+	task := &Task{ID: "task-1", Status: StatusQueued, StartTime: time.Now()}
+	m.tasks[task.ID] = task
+
+	updates, cancel, err := m.WatchTask(task.ID)
+	if err != nil {
+		t.Fatalf("Failed to watch task: %v", err)
+	}
+	defer cancel()
+
+	for _, status := range []TaskStatus{StatusProcessing, StatusUploading, StatusCompleted} {
+		task.mu.Lock()
+		task.Status = status
+		task.mu.Unlock()
+		m.notifyWatchers(task)
+	}
+
+	var got []pb.TaskStatus
+	for status := range updates {
+		got = append(got, status.Status)
+	}
+
+	want := []pb.TaskStatus{
+		pb.TaskStatus_TASK_STATUS_QUEUED,
+		pb.TaskStatus_TASK_STATUS_PROCESSING,
+		pb.TaskStatus_TASK_STATUS_UPLOADING,
+		pb.TaskStatus_TASK_STATUS_COMPLETED,
+	}
+	if len(got) != len(want) {
+		t.Fatalf("Expected %d updates, got %d: %v", len(want), len(got), got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("Update %d: expected %v, got %v", i, want[i], got[i])
+		}
+	}
+}
+
+func TestWatchTask_TerminalTask(t *testing.T) {
+	m := newTestManager(t)
+	m.tasks["task-1"] = &Task{ID: "task-1", Status: StatusFailed, StartTime: time.Now()}
+
+	updates, cancel, err := m.WatchTask("task-1")
+	if err != nil {
+		t.Fatalf("Failed to watch task: %v", err)
+	}
+	defer cancel()
+
+	status, ok := <-updates
+	if !ok || status.Status != pb.TaskStatus_TASK_STATUS_FAILED {
+		t.Fatalf("Expected current FAILED status, got %v", status)
+	}
+	if _, ok := <-updates; ok {
+		t.Error("Expected channel to be closed after terminal status")
+	}
+}
+
+func TestWatchTask_NotFound(t *testing.T) {
+	if _, _, err := newTestManager(t).WatchTask("missing"); err == nil {
+		t.Error("Expected error for unknown task")
+	}
+}
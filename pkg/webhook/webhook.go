@@ -0,0 +1,236 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/fluxo/export-middleware/pkg/config"
+	"github.com/fluxo/export-middleware/pkg/logger"
+)
+
+// Headers set on every callback request
+const (
+	SignatureHeader = "X-Export-Signature"
+	TimestampHeader = "X-Export-Timestamp"
+	EventHeader     = "X-Export-Event"
+)
+
+// Payload is the JSON body of a task completion callback
+type Payload struct {
+	Event          string `json:"event"`
+	TaskID         string `json:"task_id"`
+	RequestID      string `json:"request_id,omitempty"`
+	Status         string `json:"status"`
+	OSSUrl         string `json:"oss_url,omitempty"`
+	ChecksumSHA256 string `json:"checksum_sha256,omitempty"`
+	FileSizeBytes  int64  `json:"file_size_bytes,omitempty"`
+	RecordCount    int64  `json:"record_count,omitempty"`
+	ErrorCode      string `json:"error_code,omitempty"`
+	ErrorMessage   string `json:"error_message,omitempty"`
+	CompletionTime int64  `json:"completion_time,omitempty"`
+}
+
+// Notifier delivers signed task callbacks over HTTP
+type Notifier struct {
+	client *http.Client
+	config *config.WebhookConfig
+	logger *logger.Logger
+}
+
+// ErrBlockedAddress is returned when a callback would connect to a
+// loopback, private or link-local address
+var ErrBlockedAddress = errors.New("callback address is not public")
+
+// sharedAddressSpace is the carrier-grade NAT range (RFC 6598), which
+// net.IP.IsPrivate does not cover
+var sharedAddressSpace = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+
+// NewNotifier creates a new webhook notifier. Unless
+// allow_private_networks is set, callbacks cannot connect to internal
+// addresses: the check runs on every connection, after DNS resolution and
+// redirects, so a public name resolving to an internal address is refused
+// too. Callbacks do not go through HTTP proxies.
+func NewNotifier(cfg *config.WebhookConfig, log *logger.Logger) *Notifier {
+	dialer := &net.Dialer{Timeout: cfg.Timeout}
+	if !cfg.AllowPrivateNetworks {
+		dialer.Control = func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || blockedIP(ip) {
+				return fmt.Errorf("%w: %s", ErrBlockedAddress, host)
+			}
+			return nil
+		}
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+
+	return &Notifier{
+		client: &http.Client{Timeout: cfg.Timeout, Transport: transport},
+		config: cfg,
+		logger: log,
+	}
+}
+
+// blockedIP reports whether ip is an address callbacks may not reach
+func blockedIP(ip net.IP) bool {
+	return ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified() || sharedAddressSpace.Contains(ip)
+}
+
+// ValidateURL checks that a callback URL is an absolute http(s) URL whose
+// host is in allowed_hosts, when set. Literal internal IP addresses are
+// rejected unless allow_private_networks is set.
+func ValidateURL(callbackURL string, cfg *config.WebhookConfig) error {
+	u, err := url.Parse(callbackURL)
+	if err != nil {
+		return fmt.Errorf("invalid callback_url: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("callback_url must use http or https")
+	}
+	if u.Host == "" || u.Hostname() == "" {
+		return fmt.Errorf("callback_url must be absolute")
+	}
+
+	host := strings.ToLower(u.Hostname())
+	if len(cfg.AllowedHosts) > 0 && !hostAllowed(host, cfg.AllowedHosts) {
+		return fmt.Errorf("callback_url host %s is not allowed", host)
+	}
+	if ip := net.ParseIP(host); ip != nil && !cfg.AllowPrivateNetworks && blockedIP(ip) {
+		return fmt.Errorf("callback_url: %w", ErrBlockedAddress)
+	}
+	return nil
+}
+
+// hostAllowed reports whether host matches one of the allowed hosts. A
+// "*." prefix matches any subdomain.
+func hostAllowed(host string, allowed []string) bool {
+	for _, pattern := range allowed {
+		pattern = strings.ToLower(pattern)
+		if suffix, ok := strings.CutPrefix(pattern, "*."); ok {
+			if strings.HasSuffix(host, "."+suffix) {
+				return true
+			}
+			continue
+		}
+		if host == pattern {
+			return true
+		}
+	}
+	return false
+}
+
+// Sign computes the signature of a callback body. Receivers verify it by
+// computing HMAC-SHA256 over "<timestamp>.<body>" with the shared secret.
+func Sign(secret string, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Notify posts the payload to the callback URL, retrying with exponential
+// backoff on network errors, 429 and 5xx responses
+func (n *Notifier) Notify(ctx context.Context, callbackURL string, payload *Payload) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to encode callback payload: %w", err)
+	}
+
+	contextLogger := n.logger.WithContext(ctx).WithTaskID(payload.TaskID).WithComponent("webhook")
+
+	var lastErr error
+	for attempt := 0; attempt <= n.config.MaxRetries; attempt++ {
+		if attempt > 0 {
+			wait := n.backoff(attempt)
+			contextLogger.LogWarn(
+				"WebhookRetry",
+				fmt.Sprintf("Retrying callback (attempt %d/%d)", attempt+1, n.config.MaxRetries+1),
+				logger.Fields{"wait_time": wait.String(), "error": lastErr.Error()},
+			)
+			select {
+			case <-time.After(wait):
+			case <-ctx.Done():
+				return fmt.Errorf("callback cancelled: %w", ctx.Err())
+			}
+		}
+
+		retryable, err := n.post(ctx, callbackURL, payload.Event, body)
+		if err == nil {
+			contextLogger.LogInfo("WebhookDelivered", "Task callback delivered", logger.Fields{
+				"event":    payload.Event,
+				"attempts": attempt + 1,
+			})
+			return nil
+		}
+		lastErr = err
+		if !retryable {
+			break
+		}
+	}
+
+	contextLogger.LogError("WebhookFailed", "Task callback delivery failed", "WEBHOOK_ERROR", lastErr.Error(), logger.Fields{
+		"event": payload.Event,
+	})
+	return lastErr
+}
+
+// post sends a single callback request and reports whether a failure is
+// worth retrying
+func (n *Notifier) post(ctx context.Context, callbackURL string, event string, body []byte) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, callbackURL, bytes.NewReader(body))
+	if err != nil {
+		return false, fmt.Errorf("failed to create callback request: %w", err)
+	}
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(EventHeader, event)
+	req.Header.Set(TimestampHeader, timestamp)
+	req.Header.Set(SignatureHeader, Sign(n.config.Secret, timestamp, body))
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return !errors.Is(err, ErrBlockedAddress), fmt.Errorf("callback request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return false, nil
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		return true, fmt.Errorf("callback returned status %d", resp.StatusCode)
+	default:
+		return false, fmt.Errorf("callback rejected with status %d", resp.StatusCode)
+	}
+}
+
+// backoff returns the wait time before a retry attempt
+func (n *Notifier) backoff(attempt int) time.Duration {
+	wait := n.config.InitialBackoff << uint(attempt-1)
+	if wait <= 0 || wait > n.config.MaxBackoff {
+		wait = n.config.MaxBackoff
+	}
+	return wait
+}
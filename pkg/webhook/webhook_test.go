@@ -0,0 +1,150 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/fluxo/export-middleware/pkg/config"
+	"github.com/fluxo/export-middleware/pkg/logger"
+)
+
+// newTestNotifier returns a notifier for callbacks to test servers, which
+// listen on loopback addresses
+func newTestNotifier(t *testing.T) *Notifier {
+	return newNotifier(t, true)
+}
+
+func newNotifier(t *testing.T, allowPrivate bool) *Notifier {
+	log, err := logger.New("error", "json", "stderr", false)
+	if err != nil {
+		t.Fatalf("Failed to create logger: %v", err)
+	}
+	return NewNotifier(&config.WebhookConfig{
+		Enabled:              true,
+		Secret:               "test-secret",
+		Timeout:              time.Second,
+		MaxRetries:           3,
+		InitialBackoff:       time.Millisecond,
+		MaxBackoff:           5 * time.Millisecond,
+		AllowPrivateNetworks: allowPrivate,
+	}, log)
+}
+
+func TestNotify_RetriesAndSigns(t *testing.T) {
+	var calls int32
+	var received Payload
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		body, _ := io.ReadAll(r.Body)
+		expected := Sign("test-secret", r.Header.Get(TimestampHeader), body)
+		if got := r.Header.Get(SignatureHeader); got != expected {
+			t.Errorf("Signature mismatch: expected %s, got %s", expected, got)
+		}
+		if err := json.Unmarshal(body, &received); err != nil {
+			t.Errorf("Invalid payload: %v", err)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	payload := &Payload{
+		Event:          "task.completed",
+		TaskID:         "task-1",
+		Status:         "TASK_STATUS_COMPLETED",
+		OSSUrl:         "https://bucket.example.com/exports/report.csv",
+		ChecksumSHA256: "abc123",
+	}
+	if err := newTestNotifier(t).Notify(context.Background(), server.URL, payload); err != nil {
+		t.Fatalf("Notify failed: %v", err)
+	}
+
+	if calls != 3 {
+		t.Errorf("Expected 3 attempts, got %d", calls)
+	}
+	if received.TaskID != "task-1" || received.OSSUrl != payload.OSSUrl || received.ChecksumSHA256 != "abc123" {
+		t.Errorf("Unexpected payload received: %+v", received)
+	}
+}
+
+func TestNotify_PermanentFailure(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	err := newTestNotifier(t).Notify(context.Background(), server.URL, &Payload{Event: "task.failed", TaskID: "task-1"})
+	if err == nil {
+		t.Fatal("Expected error for rejected callback")
+	}
+	if calls != 1 {
+		t.Errorf("Expected no retries on 4xx, got %d attempts", calls)
+	}
+}
+
+func TestNotify_BlocksPrivateAddresses(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	// A name resolving to a loopback address is refused when connecting
+	callbackURL := strings.Replace(server.URL, "127.0.0.1", "localhost", 1)
+	err := newNotifier(t, false).Notify(context.Background(), callbackURL, &Payload{Event: "task.completed", TaskID: "task-1"})
+	if !errors.Is(err, ErrBlockedAddress) {
+		t.Fatalf("Expected ErrBlockedAddress, got %v", err)
+	}
+	if calls != 0 {
+		t.Errorf("Expected no request to reach the server, got %d", calls)
+	}
+}
+
+func TestValidateURL(t *testing.T) {
+	open := &config.WebhookConfig{}
+	for _, u := range []string{"https://example.com/hook", "http://203.0.113.10:8000/cb"} {
+		if err := ValidateURL(u, open); err != nil {
+			t.Errorf("Expected %s to be valid, got %v", u, err)
+		}
+	}
+	for _, u := range []string{
+		"ftp://example.com", "/relative", "https://",
+		"http://127.0.0.1:8000/cb", "http://169.254.169.254/latest/meta-data", "http://10.0.0.5/hook", "http://[::1]/hook",
+	} {
+		if err := ValidateURL(u, open); err == nil {
+			t.Errorf("Expected %s to be invalid", u)
+		}
+	}
+
+	if err := ValidateURL("http://127.0.0.1:8000/cb", &config.WebhookConfig{AllowPrivateNetworks: true}); err != nil {
+		t.Errorf("Expected loopback to be allowed with allow_private_networks, got %v", err)
+	}
+
+	allowlist := &config.WebhookConfig{AllowedHosts: []string{"hooks.example.com", "*.partner.example"}}
+	for u, valid := range map[string]bool{
+		"https://hooks.example.com/cb":      true,
+		"https://HOOKS.example.com/cb":      true,
+		"https://a.partner.example/cb":      true,
+		"https://partner.example/cb":        false,
+		"https://evil.example.com/cb":       false,
+		"https://hooks.example.com.evil/cb": false,
+	} {
+		if err := ValidateURL(u, allowlist); (err == nil) != valid {
+			t.Errorf("%s: expected valid=%v, got %v", u, valid, err)
+		}
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"encoding/csv"
 	"encoding/hex"
 	"fmt"
+	"hash"
 	"io"
 	"os"
 
@@ -22,6 +23,39 @@ type CSVWriter struct {
 	rowCount   int64
 	delimiter  rune
 	encoding   string
+
+	// Streaming output: the file is also written to sink, and its size and
+	// checksum are computed as it is written
+	sink   io.Writer
+	hasher hash.Hash
+	size   byteCounter
+}
+
+// detachingWriter passes writes on until one fails. The failure is kept,
+// and later writes are dropped, so the local copy next to it is still
+// written in full.
+type detachingWriter struct {
+	w   io.Writer
+	err error
+}
+
+// Write implements io.Writer
+func (d *detachingWriter) Write(p []byte) (int, error) {
+	if d.err == nil {
+		if _, err := d.w.Write(p); err != nil {
+			d.err = err
+		}
+	}
+	return len(p), nil
+}
+
+// byteCounter counts the bytes written to it
+type byteCounter int64
+
+// Write implements io.Writer
+func (c *byteCounter) Write(p []byte) (int, error) {
+	*c += byteCounter(len(p))
+	return len(p), nil
 }
 
 // NewCSVWriter creates a new CSV writer
@@ -32,6 +66,16 @@ func NewCSVWriter() *CSVWriter {
 	}
 }
 
+// NewCSVStreamWriter creates a CSV writer that also writes the file to
+// sink as it is generated. With an empty output path, the file is only
+// written to sink. Otherwise the local file is written first, and a sink
+// that fails is detached so the file is still completed locally.
+func NewCSVStreamWriter(sink io.Writer) *CSVWriter {
+	w := NewCSVWriter()
+	w.sink = sink
+	return w
+}
+
 // Initialize prepares the CSV writer with configuration
 func (w *CSVWriter) Initialize(ctx context.Context, metadata *pb.ExportMetadata, outputPath string) error {
 	w.outputPath = outputPath
@@ -50,14 +94,29 @@ func (w *CSVWriter) Initialize(ctx context.Context, metadata *pb.ExportMetadata,
 	}
 
 	// Create file
-	file, err := os.Create(outputPath)
-	if err != nil {
-		return fmt.Errorf("failed to create CSV file: %w", err)
+	var out io.Writer
+	if outputPath != "" {
+		file, err := os.Create(outputPath)
+		if err != nil {
+			return fmt.Errorf("failed to create CSV file: %w", err)
+		}
+		w.file = file
+		out = file
+	}
+	if w.sink != nil {
+		w.hasher = sha256.New()
+		outputs := []io.Writer{w.sink, w.hasher, &w.size}
+		if w.file != nil {
+			outputs = []io.Writer{w.file, w.hasher, &w.size, &detachingWriter{w: w.sink}}
+		}
+		out = io.MultiWriter(outputs...)
+	}
+	if out == nil {
+		return fmt.Errorf("no output path or sink")
 	}
-	w.file = file
 
 	// Create buffered writer for better performance
-	w.buffered = bufio.NewWriterSize(file, 64*1024) // 64KB buffer
+	w.buffered = bufio.NewWriterSize(out, 64*1024) // 64KB buffer
 
 	// Create CSV writer
 	w.writer = csv.NewWriter(w.buffered)
@@ -140,8 +199,19 @@ func (w *CSVWriter) Finalize() (*FileMetadata, error) {
 	}
 
 	// Close file
-	if err := w.file.Close(); err != nil {
-		return nil, fmt.Errorf("failed to close file: %w", err)
+	if w.file != nil {
+		if err := w.file.Close(); err != nil {
+			return nil, fmt.Errorf("failed to close file: %w", err)
+		}
+	}
+
+	if w.sink != nil {
+		return &FileMetadata{
+			Path:     w.outputPath,
+			Size:     int64(w.size),
+			Checksum: hex.EncodeToString(w.hasher.Sum(nil)),
+			RowCount: w.rowCount,
+		}, nil
 	}
 
 	// Calculate file size and checksum
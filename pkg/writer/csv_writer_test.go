@@ -1,7 +1,11 @@
 package writer
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"os"
 	"testing"
 
@@ -181,3 +185,124 @@ func TestCSVWriter_SpecialCharacters(t *testing.T) {
 		t.Fatalf("Output file does not exist")
 	}
 }
+
+func TestCSVWriter_StreamOutput(t *testing.T) {
+	metadata := &pb.ExportMetadata{
+		Format: pb.ExportFormat_FORMAT_CSV,
+		Columns: []*pb.ColumnDefinition{
+			{Name: "ID", DataType: pb.DataType_DATA_TYPE_NUMBER},
+			{Name: "Name", DataType: pb.DataType_DATA_TYPE_STRING},
+		},
+	}
+	records := []*pb.Record{
+		{Values: []string{"1", "Alice"}},
+		{Values: []string{"2", "Bob"}},
+	}
+
+	// Without a local file
+	var sink bytes.Buffer
+	writer := NewCSVStreamWriter(&sink)
+	if err := writer.Initialize(context.Background(), metadata, ""); err != nil {
+		t.Fatalf("Failed to initialize writer: %v", err)
+	}
+	if err := writer.WriteHeader(metadata.Columns); err != nil {
+		t.Fatalf("Failed to write header: %v", err)
+	}
+	if err := writer.WriteRecords(records); err != nil {
+		t.Fatalf("Failed to write records: %v", err)
+	}
+	fileMetadata, err := writer.Finalize()
+	if err != nil {
+		t.Fatalf("Failed to finalize: %v", err)
+	}
+
+	expected := "ID,Name\n1,Alice\n2,Bob\n"
+	if sink.String() != expected {
+		t.Errorf("Expected streamed content %q, got %q", expected, sink.String())
+	}
+	sum := sha256.Sum256([]byte(expected))
+	if fileMetadata.Checksum != hex.EncodeToString(sum[:]) {
+		t.Errorf("Expected checksum of streamed content, got %s", fileMetadata.Checksum)
+	}
+	if fileMetadata.Size != int64(len(expected)) || fileMetadata.Path != "" {
+		t.Errorf("Unexpected metadata: %+v", fileMetadata)
+	}
+
+	// With a local copy
+	outputPath := t.TempDir() + "/stream.csv"
+	sink.Reset()
+	writer = NewCSVStreamWriter(&sink)
+	if err := writer.Initialize(context.Background(), metadata, outputPath); err != nil {
+		t.Fatalf("Failed to initialize writer: %v", err)
+	}
+	writer.WriteHeader(metadata.Columns)
+	writer.WriteRecords(records)
+	if _, err := writer.Finalize(); err != nil {
+		t.Fatalf("Failed to finalize: %v", err)
+	}
+	content, err := os.ReadFile(outputPath)
+	if err != nil {
+		t.Fatalf("Failed to read local copy: %v", err)
+	}
+	if string(content) != expected || sink.String() != expected {
+		t.Errorf("Expected identical local and streamed content, got %q and %q", content, sink.String())
+	}
+}
+
+// failingSink accepts limit bytes, then fails every write
+type failingSink struct {
+	limit int
+}
+
+func (f *failingSink) Write(p []byte) (int, error) {
+	if len(p) > f.limit {
+		return 0, errors.New("part upload failed")
+	}
+	f.limit -= len(p)
+	return len(p), nil
+}
+
+func TestCSVWriter_StreamFailureKeepsLocalCopy(t *testing.T) {
+	metadata := &pb.ExportMetadata{
+		Format:  pb.ExportFormat_FORMAT_CSV,
+		Columns: []*pb.ColumnDefinition{{Name: "ID", DataType: pb.DataType_DATA_TYPE_NUMBER}},
+	}
+	records := make([]*pb.Record, 5000)
+	for i := range records {
+		records[i] = &pb.Record{Values: []string{"12345"}}
+	}
+
+	// The local copy is completed after the sink failed
+	outputPath := t.TempDir() + "/stream.csv"
+	writer := NewCSVStreamWriter(&failingSink{limit: 10})
+	if err := writer.Initialize(context.Background(), metadata, outputPath); err != nil {
+		t.Fatalf("Failed to initialize writer: %v", err)
+	}
+	writer.WriteHeader(metadata.Columns)
+	if err := writer.WriteRecords(records); err != nil {
+		t.Fatalf("Expected writes to go on locally, got %v", err)
+	}
+	fileMetadata, err := writer.Finalize()
+	if err != nil {
+		t.Fatalf("Failed to finalize: %v", err)
+	}
+	content, err := os.ReadFile(outputPath)
+	if err != nil {
+		t.Fatalf("Failed to read local copy: %v", err)
+	}
+	sum := sha256.Sum256(content)
+	if int64(len(content)) != fileMetadata.Size || fileMetadata.Checksum != hex.EncodeToString(sum[:]) || fileMetadata.RowCount != 5001 {
+		t.Errorf("Expected metadata of the complete local copy, got %+v", fileMetadata)
+	}
+
+	// Without a local copy the failure stops the export
+	writer = NewCSVStreamWriter(&failingSink{limit: 10})
+	if err := writer.Initialize(context.Background(), metadata, ""); err != nil {
+		t.Fatalf("Failed to initialize writer: %v", err)
+	}
+	writer.WriteHeader(metadata.Columns)
+	writer.WriteRecords(records)
+	if _, err := writer.Finalize(); err == nil {
+		t.Error("Expected the sink failure to be returned")
+	}
+}